@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/MetaDandy/Assistense-System/config"
+	"github.com/MetaDandy/Assistense-System/src/modelo"
+)
+
+// Genera las características de las fotos de referencia cargadas antes de que se guardaran al
+// subirlas, o después de cambiar de backend (FACE_MATCHER).
+//
+//	go run ./cmd/backfill_caracteristicas         solo las que faltan o están desactualizadas
+//	go run ./cmd/backfill_caracteristicas -todos  recalcula todas
+func main() {
+	todos := flag.Bool("todos", false, "recalcular también las referencias que ya tienen características")
+	flag.Parse()
+
+	config.Load()
+
+	estudianteModelo := modelo.NuevoEstudianteModelo(config.DB, config.FaceMatcher)
+
+	procesados, err := estudianteModelo.RecalcularCaracteristicas(*todos)
+	log.Printf("Características generadas para %d referencia(s)", procesados)
+	if err != nil {
+		log.Fatalf("Algunas referencias no se pudieron procesar:\n%v", err)
+	}
+}
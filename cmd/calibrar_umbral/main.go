@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+)
+
+// Calibra los umbrales de similitud con fotos de la propia población.
+// El directorio tiene una carpeta por persona con sus fotos (JPEG o PNG); se comparan todos los pares
+// de la misma persona (genuinos) y de personas distintas (impostores) con el FaceMatcher elegido,
+// y se informa FAR/FRR por umbral, el punto de igual error (EER) y los umbrales recomendados.
+//
+//	go run ./cmd/calibrar_umbral -dir ./fotos
+//	go run ./cmd/calibrar_umbral -dir ./fotos -csv calibracion.csv -far-max 0.005
+//	go run ./cmd/calibrar_umbral -dir ./fotos -backend http -url http://localhost:9000
+func main() {
+	dir := flag.String("dir", "", "directorio con una carpeta de fotos por persona")
+	backend := flag.String("backend", os.Getenv("FACE_MATCHER"), "backend de reconocimiento: histograma | http")
+	url := flag.String("url", os.Getenv("FACE_MATCHER_URL"), "URL del servicio para el backend http")
+	desde := flag.Float64("desde", 0, "primer umbral evaluado")
+	hasta := flag.Float64("hasta", 1, "último umbral evaluado")
+	paso := flag.Float64("paso", 0.01, "separación entre umbrales")
+	farMax := flag.Float64("far-max", 0.01, "FAR máxima admitida para recomendar el umbral de aceptación")
+	archivoCSV := flag.String("csv", "", "archivo donde escribir la tabla en CSV (- para la salida estándar)")
+	flag.Parse()
+
+	if *dir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *paso <= 0 || *desde > *hasta {
+		log.Fatal("rango de umbrales inválido")
+	}
+
+	matcher, err := helper.NuevoFaceMatcher(*backend, *url, 30*time.Second)
+	if err != nil {
+		log.Fatalf("Error configurando reconocimiento facial: %v", err)
+	}
+
+	personas, err := cargarPersonas(*dir, matcher)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	genuinos, impostores, err := calcularPuntajes(personas, matcher)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(genuinos) == 0 || len(impostores) == 0 {
+		log.Fatal("se necesitan al menos dos personas y una con dos fotos o más para calibrar")
+	}
+
+	filas := evaluarUmbrales(genuinos, impostores, *desde, *hasta, *paso)
+	eer := puntoIgualError(filas)
+	aceptacion, hayAceptacion := umbralParaFAR(filas, *farMax)
+
+	fmt.Printf("Matcher: %s (versión %s)\n", matcher.Algoritmo(), matcher.Version())
+	fmt.Printf("Personas: %d, pares genuinos: %d, pares impostores: %d\n\n", len(personas), len(genuinos), len(impostores))
+	escribirTabla(os.Stdout, filas)
+
+	fmt.Printf("\nPunto de igual error: umbral %.2f, FAR %.2f%%, FRR %.2f%% (EER ≈ %.2f%%)\n",
+		eer.Umbral, eer.FAR*100, eer.FRR*100, (eer.FAR+eer.FRR)/2*100)
+	fmt.Println("\nUmbrales recomendados:")
+	if hayAceptacion {
+		fmt.Printf("  UMBRAL_ACEPTACION=%.2f  (FAR %.2f%% <= %.2f%%, FRR %.2f%%)\n", aceptacion.Umbral, aceptacion.FAR*100, *farMax*100, aceptacion.FRR*100)
+	} else {
+		fmt.Printf("  UMBRAL_ACEPTACION: ningún umbral del rango llega a FAR <= %.2f%%\n", *farMax*100)
+	}
+	// El umbral de revisión no puede quedar por encima del de aceptación
+	revision := eer.Umbral
+	if hayAceptacion && aceptacion.Umbral < revision {
+		revision = aceptacion.Umbral
+	}
+	fmt.Printf("  UMBRAL_REVISION=%.2f    (punto de igual error; entre ambos la asistencia queda para revisión)\n", revision)
+
+	if *archivoCSV != "" {
+		if err := guardarCSV(*archivoCSV, filas); err != nil {
+			log.Fatalf("Error escribiendo CSV: %v", err)
+		}
+	}
+}
+
+// persona son las características de las fotos de una carpeta
+type persona struct {
+	nombre          string
+	caracteristicas [][]byte
+}
+
+// cargarPersonas extrae las características de todas las fotos del directorio
+// Las fotos que no se pueden procesar (sin rostro, formato inválido) se informan y se omiten
+func cargarPersonas(dir string, matcher helper.FaceMatcher) ([]persona, error) {
+	entradas, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer el directorio: %v", err)
+	}
+
+	var personas []persona
+	omitidas := 0
+	for _, entrada := range entradas {
+		if !entrada.IsDir() {
+			continue
+		}
+
+		archivos, err := os.ReadDir(filepath.Join(dir, entrada.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		p := persona{nombre: entrada.Name()}
+		for _, archivo := range archivos {
+			extension := strings.ToLower(filepath.Ext(archivo.Name()))
+			if archivo.IsDir() || (extension != ".jpg" && extension != ".jpeg" && extension != ".png") {
+				continue
+			}
+
+			ruta := filepath.Join(dir, entrada.Name(), archivo.Name())
+			datos, err := os.ReadFile(ruta)
+			if err != nil {
+				return nil, err
+			}
+
+			caracteristicas, err := matcher.ExtraerCaracteristicas(base64.StdEncoding.EncodeToString(datos))
+			if err != nil {
+				log.Printf("Omitida %s: %v", ruta, err)
+				omitidas++
+				continue
+			}
+			p.caracteristicas = append(p.caracteristicas, caracteristicas)
+		}
+
+		if len(p.caracteristicas) > 0 {
+			personas = append(personas, p)
+		}
+	}
+
+	if omitidas > 0 {
+		log.Printf("%d foto(s) omitida(s)", omitidas)
+	}
+	return personas, nil
+}
+
+// calcularPuntajes compara todos los pares de fotos: de la misma persona (genuinos) y de personas distintas (impostores)
+func calcularPuntajes(personas []persona, matcher helper.FaceMatcher) (genuinos, impostores []float64, err error) {
+	for i, p := range personas {
+		for a := 0; a < len(p.caracteristicas); a++ {
+			for b := a + 1; b < len(p.caracteristicas); b++ {
+				s, err := matcher.Comparar(p.caracteristicas[a], p.caracteristicas[b])
+				if err != nil {
+					return nil, nil, err
+				}
+				genuinos = append(genuinos, s)
+			}
+		}
+
+		for _, otra := range personas[i+1:] {
+			for _, a := range p.caracteristicas {
+				for _, b := range otra.caracteristicas {
+					s, err := matcher.Comparar(a, b)
+					if err != nil {
+						return nil, nil, err
+					}
+					impostores = append(impostores, s)
+				}
+			}
+		}
+	}
+	return genuinos, impostores, nil
+}
+
+// filaUmbral son las tasas de error con un umbral: se acepta cuando la similitud es >= Umbral
+type filaUmbral struct {
+	Umbral           float64
+	FAR              float64 // Impostores aceptados / impostores
+	FRR              float64 // Genuinos rechazados / genuinos
+	FalsosAceptados  int
+	FalsosRechazados int
+}
+
+func evaluarUmbrales(genuinos, impostores []float64, desde, hasta, paso float64) []filaUmbral {
+	sort.Float64s(genuinos)
+	sort.Float64s(impostores)
+
+	var filas []filaUmbral
+	pasos := int((hasta-desde)/paso + 0.5)
+	for i := 0; i <= pasos; i++ {
+		umbral := desde + float64(i)*paso
+		// Con los puntajes ordenados, los rechazados son los anteriores al primer puntaje >= umbral
+		rechazados := sort.SearchFloat64s(genuinos, umbral)
+		aceptados := len(impostores) - sort.SearchFloat64s(impostores, umbral)
+		filas = append(filas, filaUmbral{
+			Umbral:           umbral,
+			FAR:              float64(aceptados) / float64(len(impostores)),
+			FRR:              float64(rechazados) / float64(len(genuinos)),
+			FalsosAceptados:  aceptados,
+			FalsosRechazados: rechazados,
+		})
+	}
+	return filas
+}
+
+// puntoIgualError es el umbral donde FAR y FRR quedan más cerca
+func puntoIgualError(filas []filaUmbral) filaUmbral {
+	mejor := filas[0]
+	for _, f := range filas[1:] {
+		if abs(f.FAR-f.FRR) < abs(mejor.FAR-mejor.FRR) {
+			mejor = f
+		}
+	}
+	return mejor
+}
+
+// umbralParaFAR es el menor umbral con FAR <= farMax: el que menos rechaza sin pasar ese límite
+func umbralParaFAR(filas []filaUmbral, farMax float64) (filaUmbral, bool) {
+	for _, f := range filas {
+		if f.FAR <= farMax {
+			return f, true
+		}
+	}
+	return filaUmbral{}, false
+}
+
+func escribirTabla(w io.Writer, filas []filaUmbral) {
+	fmt.Fprintf(w, "%8s %9s %9s %8s %8s\n", "umbral", "FAR", "FRR", "FA", "FR")
+	for _, f := range filas {
+		fmt.Fprintf(w, "%8.2f %8.2f%% %8.2f%% %8d %8d\n", f.Umbral, f.FAR*100, f.FRR*100, f.FalsosAceptados, f.FalsosRechazados)
+	}
+}
+
+func guardarCSV(ruta string, filas []filaUmbral) error {
+	var salida io.Writer = os.Stdout
+	if ruta != "-" {
+		archivo, err := os.Create(ruta)
+		if err != nil {
+			return err
+		}
+		defer archivo.Close()
+		salida = archivo
+	}
+
+	w := csv.NewWriter(salida)
+	w.Write([]string{"umbral", "far", "frr", "falsos_aceptados", "falsos_rechazados"})
+	for _, f := range filas {
+		w.Write([]string{
+			strconv.FormatFloat(f.Umbral, 'f', 4, 64),
+			strconv.FormatFloat(f.FAR, 'f', 6, 64),
+			strconv.FormatFloat(f.FRR, 'f', 6, 64),
+			strconv.Itoa(f.FalsosAceptados),
+			strconv.Itoa(f.FalsosRechazados),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
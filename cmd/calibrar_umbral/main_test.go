@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEvaluarUmbrales(t *testing.T) {
+	genuinos := []float64{0.9, 0.6, 0.8, 0.7}
+	impostores := []float64{0.2, 0.5, 0.3, 0.6}
+
+	filas := evaluarUmbrales(genuinos, impostores, 0.5, 0.8, 0.1)
+	esperadas := []filaUmbral{
+		// Se acepta con similitud >= umbral: en 0.5 pasan los impostores de 0.5 y 0.6
+		{Umbral: 0.5, FAR: 0.5, FRR: 0, FalsosAceptados: 2, FalsosRechazados: 0},
+		{Umbral: 0.6, FAR: 0.25, FRR: 0, FalsosAceptados: 1, FalsosRechazados: 0},
+		{Umbral: 0.7, FAR: 0, FRR: 0.25, FalsosAceptados: 0, FalsosRechazados: 1},
+		{Umbral: 0.8, FAR: 0, FRR: 0.5, FalsosAceptados: 0, FalsosRechazados: 2},
+	}
+	if len(filas) != len(esperadas) {
+		t.Fatalf("%d filas, se esperaban %d", len(filas), len(esperadas))
+	}
+	for i, f := range filas {
+		e := esperadas[i]
+		if math.Abs(f.Umbral-e.Umbral) > 1e-9 || f.FAR != e.FAR || f.FRR != e.FRR ||
+			f.FalsosAceptados != e.FalsosAceptados || f.FalsosRechazados != e.FalsosRechazados {
+			t.Errorf("fila %d = %+v, se esperaba %+v", i, f, e)
+		}
+	}
+}
+
+func TestPuntoIgualErrorYUmbralParaFAR(t *testing.T) {
+	filas := []filaUmbral{
+		{Umbral: 0.4, FAR: 0.30, FRR: 0.00},
+		{Umbral: 0.5, FAR: 0.10, FRR: 0.02},
+		{Umbral: 0.6, FAR: 0.04, FRR: 0.05},
+		{Umbral: 0.7, FAR: 0.01, FRR: 0.12},
+		{Umbral: 0.8, FAR: 0.00, FRR: 0.30},
+	}
+
+	if eer := puntoIgualError(filas); eer.Umbral != 0.6 {
+		t.Errorf("EER en %.1f, se esperaba 0.6", eer.Umbral)
+	}
+
+	casos := []struct {
+		farMax     float64
+		umbral     float64
+		encontrado bool
+	}{
+		{0.5, 0.4, true},
+		{0.05, 0.6, true},
+		{0.01, 0.7, true},
+		{0, 0.8, true},
+		{-1, 0, false},
+	}
+	for _, c := range casos {
+		fila, ok := umbralParaFAR(filas, c.farMax)
+		if ok != c.encontrado || fila.Umbral != c.umbral {
+			t.Errorf("umbralParaFAR(%.2f) = %.1f, %v; se esperaba %.1f, %v", c.farMax, fila.Umbral, ok, c.umbral, c.encontrado)
+		}
+	}
+}
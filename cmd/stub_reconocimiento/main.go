@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+)
+
+// Servidor local que imita al motor de reconocimiento externo del backend HTTP (FACE_MATCHER=http).
+// Devuelve como vector los histogramas normalizados de la imagen, así se puede probar el backend
+// sin depender del servicio real.
+//
+//	go run ./cmd/stub_reconocimiento -puerto 9000
+//	FACE_MATCHER=http FACE_MATCHER_URL=http://localhost:9000 go run ./cmd
+func main() {
+	puerto := flag.String("puerto", "9000", "puerto en el que escucha el stub")
+	flag.Parse()
+
+	http.HandleFunc("GET /info", func(w http.ResponseWriter, r *http.Request) {
+		helper.EnviarJson(w, http.StatusOK, map[string]string{
+			"algoritmo": "stub-histograma",
+			"version":   "1",
+		})
+	})
+
+	http.HandleFunc("POST /extraer", func(w http.ResponseWriter, r *http.Request) {
+		var solicitud struct {
+			Imagen string `json:"imagen"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&solicitud); err != nil {
+			helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "JSON inválido"})
+			return
+		}
+
+		caracteristicas, err := helper.ExtraerCaracteristicas(solicitud.Imagen)
+		if err != nil {
+			helper.EnviarJson(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+
+		helper.EnviarJson(w, http.StatusOK, map[string][]float64{
+			"caracteristicas": vectorizar(caracteristicas),
+		})
+	})
+
+	fmt.Printf("Stub de reconocimiento facial en puerto %s\n", *puerto)
+	log.Fatal(http.ListenAndServe(":"+*puerto, nil))
+}
+
+// vectorizar concatena los histogramas normalizados y el brillo en un solo vector
+func vectorizar(c *helper.CaracteristicasImagen) []float64 {
+	total := float64(c.Ancho * c.Alto)
+	vector := make([]float64, 0, 3*256+1)
+	for _, h := range [][256]int{c.HistogramaR, c.HistogramaG, c.HistogramaB} {
+		for _, v := range h {
+			vector = append(vector, float64(v)/total)
+		}
+	}
+	return append(vector, c.BrilloPromedio/255)
+}
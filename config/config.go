@@ -4,16 +4,66 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/MetaDandy/Assistense-System/helper"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var (
-	DB   *gorm.DB
-	Port string
+	DB          *gorm.DB
+	Port        string
+	FaceMatcher helper.FaceMatcher
+
+	// Umbrales de similitud: desde UmbralAceptacion se acepta sola, desde UmbralRevision queda
+	// pendiente de revisión del docente y por debajo se rechaza
+	UmbralAceptacion float64
+	UmbralRevision   float64
+
+	// ModoMetadatos es lo que hacen las sesiones sin modo propio con las fotos cuyos metadatos no
+	// corresponden a la sesión: rechazarlas (estricto), aceptarlas con advertencia o no revisarlos
+	ModoMetadatos string
+
+	// ToleranciaTardanza son los minutos después del inicio en que la llegada cuenta como presente
+	// en las sesiones sin tolerancia propia
+	ToleranciaTardanza int
+
+	// GraciaCierre son los minutos después del fin en que las sesiones sin gracia propia todavía reciben
+	// registros, que quedan pendientes de aprobación del docente
+	GraciaCierre int
+
+	// ZonaHoraria es la zona de la institución: en ella se programan las sesiones de los docentes sin zona
+	// propia y se interpretan los horarios guardados como texto antes de migrarlos
+	ZonaHoraria *time.Location
+
+	// ModoVivacidad es qué hacer con los registros sin desafío de vivacidad: rechazarlos (obligatoria), dejarlos
+	// pendientes de revisión del docente (opcional, para los clientes anteriores al desafío) o no pedirlo
+	ModoVivacidad string
+
+	// MargenIdentificacion es la ventaja mínima de similitud del estudiante más parecido sobre el segundo
+	// para registrar una asistencia identificada 1:N (cámara en la puerta)
+	MargenIdentificacion float64
+
+	// IntervaloAusencias es cada cuánto se buscan sesiones terminadas para marcar ausentes a los
+	// estudiantes sin asistencia; 0 desactiva el marcado automático (sigue disponible el manual)
+	IntervaloAusencias time.Duration
+
+	// RetencionIntentos (RETENCION_INTENTOS, en días; por defecto 30) es cuánto se guardan las fotos de los
+	// intentos rechazados por rostro; las de apelaciones pendientes se conservan hasta que el docente las revise
+	RetencionIntentos time.Duration
+
+	// Referencias faciales automáticas (desactivadas por defecto): una foto de verificación con similitud
+	// MargenReferencias por encima del umbral de aceptación pasa a ser referencia, hasta MaximoReferencias
+	// por estudiante y por VigenciaReferencias
+	ReferenciasAdaptativas bool
+	MargenReferencias      float64
+	MaximoReferencias      int
+	VigenciaReferencias    time.Duration
 )
 
 func Load() {
@@ -27,6 +77,18 @@ func Load() {
 		Port = "8000"
 	}
 
+	cargarFaceMatcher()
+	cargarUmbrales()
+	cargarModoMetadatos()
+	cargarModoVivacidad()
+	MargenIdentificacion = leerUmbral("MARGEN_IDENTIFICACION", 0.05)
+	ToleranciaTardanza = leerMinutos("TOLERANCIA_TARDANZA", 10)
+	GraciaCierre = leerMinutos("GRACIA_CIERRE", 10)
+	cargarZonaHoraria()
+	IntervaloAusencias = time.Duration(leerMinutos("INTERVALO_AUSENCIAS", 5)) * time.Minute
+	RetencionIntentos = time.Duration(leerEntero("RETENCION_INTENTOS", 30, 1, "días")) * 24 * time.Hour
+	cargarPoliticaReferencias()
+
 	maxRetries := 10
 	for i := range maxRetries {
 		dns := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
@@ -53,3 +115,116 @@ func Load() {
 
 	log.Fatalf("Error connecting to database after %d retries", maxRetries)
 }
+
+// cargarFaceMatcher selecciona el backend de reconocimiento facial
+// FACE_MATCHER=histograma (por defecto) | http, con FACE_MATCHER_URL y FACE_MATCHER_TIMEOUT (segundos) para http
+func cargarFaceMatcher() {
+	timeout := 10 * time.Second
+	if segundos, err := strconv.Atoi(os.Getenv("FACE_MATCHER_TIMEOUT")); err == nil && segundos > 0 {
+		timeout = time.Duration(segundos) * time.Second
+	}
+
+	matcher, err := helper.NuevoFaceMatcher(os.Getenv("FACE_MATCHER"), os.Getenv("FACE_MATCHER_URL"), timeout)
+	if err != nil {
+		log.Fatalf("Error configurando reconocimiento facial: %v", err)
+	}
+
+	FaceMatcher = matcher
+	log.Printf("Reconocimiento facial: %s (versión %s)", matcher.Algoritmo(), matcher.Version())
+}
+
+// cargarUmbrales lee UMBRAL_ACEPTACION (por defecto 0.6, el umbral fijo anterior) y UMBRAL_REVISION
+// (por defecto igual al de aceptación: sin banda de revisión, nada que antes se rechazaba queda pendiente)
+func cargarUmbrales() {
+	UmbralAceptacion = leerUmbral("UMBRAL_ACEPTACION", 0.6)
+	UmbralRevision = leerUmbral("UMBRAL_REVISION", UmbralAceptacion)
+
+	if UmbralRevision > UmbralAceptacion {
+		log.Fatalf("UMBRAL_REVISION (%.2f) no puede ser mayor que UMBRAL_ACEPTACION (%.2f)", UmbralRevision, UmbralAceptacion)
+	}
+	log.Printf("Umbrales de similitud: aceptación %.2f, revisión %.2f", UmbralAceptacion, UmbralRevision)
+}
+
+func leerUmbral(variable string, porDefecto float64) float64 {
+	valor := os.Getenv(variable)
+	if valor == "" {
+		return porDefecto
+	}
+	umbral, err := strconv.ParseFloat(valor, 64)
+	if err != nil || umbral < 0 || umbral > 1 {
+		log.Fatalf("%s debe ser un número entre 0 y 1: %q", variable, valor)
+	}
+	return umbral
+}
+
+// cargarModoMetadatos lee VALIDACION_METADATOS: estricto, advertencia (por defecto) o desactivado
+func cargarModoMetadatos() {
+	ModoMetadatos = leerOpcion("VALIDACION_METADATOS", "advertencia", "estricto", "advertencia", "desactivado")
+	log.Printf("Validación de metadatos de fotos: %s", ModoMetadatos)
+}
+
+// cargarModoVivacidad lee VIVACIDAD (por defecto opcional, mientras se actualizan los clientes)
+func cargarModoVivacidad() {
+	ModoVivacidad = leerOpcion("VIVACIDAD", "opcional", "obligatoria", "opcional", "desactivada")
+	log.Printf("Prueba de vivacidad: %s", ModoVivacidad)
+}
+
+// leerOpcion lee una variable que solo admite los valores de opciones
+func leerOpcion(variable, porDefecto string, opciones ...string) string {
+	valor := os.Getenv(variable)
+	if valor == "" {
+		return porDefecto
+	}
+	if !slices.Contains(opciones, valor) {
+		log.Fatalf("%s debe ser %s: %q", variable, strings.Join(opciones, ", "), valor)
+	}
+	return valor
+}
+
+// leerMinutos lee una cantidad de minutos no negativa: TOLERANCIA_TARDANZA (por defecto 10),
+// GRACIA_CIERRE (por defecto 10; 0 cierra las sesiones a la hora de fin) e INTERVALO_AUSENCIAS
+// (por defecto 5; 0 desactiva el marcado automático)
+func leerMinutos(variable string, porDefecto int) int {
+	return leerEntero(variable, porDefecto, 0, "minutos")
+}
+
+// leerEntero lee un entero de al menos minimo, en la unidad que se indica en el mensaje de error
+func leerEntero(variable string, porDefecto, minimo int, unidad string) int {
+	valor := os.Getenv(variable)
+	if valor == "" {
+		return porDefecto
+	}
+	numero, err := strconv.Atoi(valor)
+	if err != nil || numero < minimo {
+		log.Fatalf("%s debe ser un número de %s desde %d: %q", variable, unidad, minimo, valor)
+	}
+	return numero
+}
+
+// cargarZonaHoraria lee ZONA_HORARIA, un nombre IANA como America/La_Paz (por defecto la zona del servidor)
+func cargarZonaHoraria() {
+	ZonaHoraria = time.Local
+	if valor := os.Getenv("ZONA_HORARIA"); valor != "" {
+		zona, err := time.LoadLocation(valor)
+		if err != nil {
+			log.Fatalf("ZONA_HORARIA debe ser una zona horaria IANA, como America/La_Paz: %q", valor)
+		}
+		ZonaHoraria = zona
+	}
+	log.Printf("Zona horaria de la institución: %s", ZonaHoraria)
+}
+
+// cargarPoliticaReferencias lee REFERENCIAS_ADAPTATIVAS=true para activar las referencias automáticas, con
+// REFERENCIAS_ADAPTATIVAS_MARGEN (por defecto 0.15 sobre el umbral de aceptación),
+// REFERENCIAS_ADAPTATIVAS_MAXIMO (3 por estudiante) y REFERENCIAS_ADAPTATIVAS_DIAS (120 días de vigencia)
+func cargarPoliticaReferencias() {
+	ReferenciasAdaptativas, _ = strconv.ParseBool(os.Getenv("REFERENCIAS_ADAPTATIVAS"))
+	MargenReferencias = leerUmbral("REFERENCIAS_ADAPTATIVAS_MARGEN", 0.15)
+	MaximoReferencias = leerEntero("REFERENCIAS_ADAPTATIVAS_MAXIMO", 3, 1, "referencias")
+	dias := leerEntero("REFERENCIAS_ADAPTATIVAS_DIAS", 120, 1, "días")
+	VigenciaReferencias = time.Duration(dias) * 24 * time.Hour
+
+	if ReferenciasAdaptativas {
+		log.Printf("Referencias automáticas: margen %.2f, máximo %d, vigencia %d días", MargenReferencias, MaximoReferencias, dias)
+	}
+}
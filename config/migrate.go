@@ -2,6 +2,7 @@ package config
 
 import (
 	"log"
+	"time"
 
 	"github.com/MetaDandy/Assistense-System/src/modelo"
 	"gorm.io/gorm"
@@ -10,15 +11,94 @@ import (
 func Migrate(db *gorm.DB) {
 	log.Println("Starting migration...")
 
+	// Las características de referencia antes eran una por estudiante; ahora son una por referencia facial.
+	// Son datos derivados de las fotos, así que la tabla anterior se descarta y se regeneran
+	if db.Migrator().HasTable(&modelo.CaracteristicasReferencia{}) &&
+		!db.Migrator().HasColumn(&modelo.CaracteristicasReferencia{}, "ReferenciaFacialID") {
+		if err := db.Migrator().DropTable(&modelo.CaracteristicasReferencia{}); err != nil {
+			log.Fatal("Failed to drop old reference features: " + err.Error())
+		}
+	}
+
+	// Los horarios de las sesiones y las horas de llegada eran texto en la hora del servidor (time.Local); pasan
+	// a instantes antes de AutoMigrate, que no puede agregar las columnas NOT NULL vacías y leería el texto en UTC
+	sesiones, err := modelo.MigrarHorariosSesiones(db, ZonaHoraria)
+	if err != nil {
+		log.Fatal("Failed to migrate session schedules: " + err.Error())
+	}
+	if sesiones > 0 {
+		log.Printf("Migrated schedules of %d session(s) from server time (%s) to timestamps shown in %s", sesiones, time.Local, ZonaHoraria)
+	}
+	llegadas, err := modelo.MigrarFechaHoraAsistencias(db)
+	if err != nil {
+		log.Fatal("Failed to migrate attendance times: " + err.Error())
+	}
+	if llegadas > 0 {
+		log.Printf("Migrated %d attendance time(s) from server time (%s) to timestamps", llegadas, time.Local)
+	}
+
+	// Las sesiones anteriores no tenían estudiantes inscritos; la tabla la crea AutoMigrate
+	inscripcionesNuevas := !db.Migrator().HasTable("sesion_estudiantes")
+
 	// Primero aplicar AutoMigrate para crear/actualizar tablas
 	if err := db.AutoMigrate(
 		&modelo.Docente{},
 		&modelo.Estudiante{},
+		&modelo.ReferenciaFacial{},
+		&modelo.CaracteristicasReferencia{},
 		&modelo.SesionAsistencia{},
 		&modelo.Asistencia{},
+		&modelo.IntentoFotoRepetida{},
+		&modelo.SospechaSuplantacion{},
+		&modelo.DesafioVivacidad{},
+		&modelo.RevisionAsistencia{},
+		&modelo.ActualizacionReferencia{},
+		&modelo.AjusteAsistencia{},
+		&modelo.Justificacion{},
+		&modelo.DocumentoJustificacion{},
+		&modelo.IntentoRechazado{},
+		&modelo.Apelacion{},
+		&modelo.TransicionSesion{},
 	); err != nil {
 		log.Fatal("Failed to migrate database: " + err.Error())
 	}
 
+	// Luego migrar los datos: la foto única de cada estudiante pasa a ser su referencia facial principal
+	migradas, err := modelo.MigrarFotosReferencia(db)
+	if err != nil {
+		log.Fatal("Failed to migrate reference photos: " + err.Error())
+	}
+	if migradas > 0 {
+		log.Printf("Migrated %d reference photo(s) to facial references", migradas)
+	}
+
+	// Las asistencias anteriores no tenían huellas de la foto; sin ellas no se detectaría su reutilización
+	huellas, err := modelo.MigrarHuellasAsistencias(db)
+	if err != nil {
+		log.Fatal("Failed to compute verification photo hashes: " + err.Error())
+	}
+	if huellas > 0 {
+		log.Printf("Computed hashes for %d verification photo(s)", huellas)
+	}
+
+	// Las asistencias anteriores no tenían condición (presente, tarde...)
+	condiciones, err := modelo.MigrarCondicionAsistencias(db, ToleranciaTardanza)
+	if err != nil {
+		log.Fatal("Failed to compute attendance status: " + err.Error())
+	}
+	if condiciones > 0 {
+		log.Printf("Computed status for %d attendance record(s)", condiciones)
+	}
+
+	if inscripcionesNuevas {
+		procesadas, err := modelo.MigrarInscripcionesSesiones(db, GraciaCierre)
+		if err != nil {
+			log.Fatal("Failed to enroll students in existing sessions: " + err.Error())
+		}
+		if procesadas > 0 {
+			log.Printf("Marked %d finished session(s) as processed for absences", procesadas)
+		}
+	}
+
 	log.Println("Migration completed")
 }
@@ -7,6 +7,7 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
 	golang.org/x/crypto v0.31.0
+	golang.org/x/image v0.23.0
 	gorm.io/gorm v1.30.3
 )
 
@@ -0,0 +1,142 @@
+package helper
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// Límites del control de calidad de las fotos de referencia
+const (
+	AnchoMinimoReferencia = 240
+	AltoMinimoReferencia  = 180
+	BrilloMinimo          = 50.0
+	BrilloMaximo          = 210.0
+	// NitidezMinima es la varianza mínima del laplaciano; por debajo la foto está movida o desenfocada
+	NitidezMinima = 25.0
+	// ladoTrabajoNitidez limita el tamaño sobre el que se mide la nitidez
+	ladoTrabajoNitidez = 640
+)
+
+// FallaCalidad describe un control de calidad que la foto no superó
+type FallaCalidad struct {
+	Control string `json:"control"`
+	Motivo  string `json:"motivo"`
+}
+
+// ErrorCalidadFoto agrupa todos los controles fallidos, para que el docente vea todo lo que debe corregir de una vez
+type ErrorCalidadFoto struct {
+	Fallas []FallaCalidad
+}
+
+func (e *ErrorCalidadFoto) Error() string {
+	motivos := make([]string, len(e.Fallas))
+	for i, f := range e.Fallas {
+		motivos[i] = f.Motivo
+	}
+	return "la foto de referencia no cumple los requisitos de calidad: " + strings.Join(motivos, "; ")
+}
+
+// ValidarCalidadFotoReferencia rechaza fotos de referencia que van a producir malas comparaciones
+// Devuelve *ErrorCalidadFoto con todos los controles fallidos, o un error común si la imagen no se puede leer
+func ValidarCalidadFotoReferencia(base64Data string) error {
+	fallas, err := EvaluarCalidadFoto(base64Data)
+	if err != nil {
+		return err
+	}
+	if len(fallas) > 0 {
+		return &ErrorCalidadFoto{Fallas: fallas}
+	}
+	return nil
+}
+
+// EvaluarCalidadFoto ejecuta todos los controles de calidad y devuelve los que fallaron
+// Controla resolución mínima, brillo promedio, nitidez y que haya exactamente un rostro
+func EvaluarCalidadFoto(base64Data string) ([]FallaCalidad, error) {
+	if err := ValidarImagenBase64(base64Data); err != nil {
+		return nil, err
+	}
+
+	img, err := decodificarImagenBase64(base64Data)
+	if err != nil {
+		return nil, err
+	}
+
+	var fallas []FallaCalidad
+	bounds := img.Bounds()
+
+	if bounds.Dx() < AnchoMinimoReferencia || bounds.Dy() < AltoMinimoReferencia {
+		fallas = append(fallas, FallaCalidad{
+			Control: "resolucion",
+			Motivo: fmt.Sprintf("la resolución %dx%d es menor que la mínima de %dx%d",
+				bounds.Dx(), bounds.Dy(), AnchoMinimoReferencia, AltoMinimoReferencia),
+		})
+	}
+
+	brillo := caracteristicasDeImagen(img).BrilloPromedio
+	if brillo < BrilloMinimo {
+		fallas = append(fallas, FallaCalidad{
+			Control: "brillo",
+			Motivo:  fmt.Sprintf("la foto está muy oscura (brillo %.0f, mínimo %.0f)", brillo, BrilloMinimo),
+		})
+	} else if brillo > BrilloMaximo {
+		fallas = append(fallas, FallaCalidad{
+			Control: "brillo",
+			Motivo:  fmt.Sprintf("la foto está sobreexpuesta (brillo %.0f, máximo %.0f)", brillo, BrilloMaximo),
+		})
+	}
+
+	if nitidez := medirNitidez(img); nitidez < NitidezMinima {
+		fallas = append(fallas, FallaCalidad{
+			Control: "nitidez",
+			Motivo:  fmt.Sprintf("la foto está borrosa o movida (nitidez %.1f, mínimo %.1f)", nitidez, NitidezMinima),
+		})
+	}
+
+	switch rostros := DetectarRostros(img); {
+	case len(rostros) == 0:
+		fallas = append(fallas, FallaCalidad{Control: "rostro", Motivo: "no se detectó ningún rostro"})
+	case len(rostros) > 1:
+		fallas = append(fallas, FallaCalidad{
+			Control: "rostro",
+			Motivo:  fmt.Sprintf("se detectaron %d rostros, debe aparecer solo el estudiante", len(rostros)),
+		})
+	}
+
+	return fallas, nil
+}
+
+// medirNitidez calcula la varianza del laplaciano de la imagen en escala de grises
+// Los bordes marcados dan valores altos; una foto desenfocada tiene un laplaciano casi plano
+func medirNitidez(img image.Image) float64 {
+	bounds := img.Bounds()
+	paso := max(1, max(bounds.Dx(), bounds.Dy())/ladoTrabajoNitidez)
+	w := bounds.Dx() / paso
+	h := bounds.Dy() / paso
+	if w < 3 || h < 3 {
+		return 0
+	}
+
+	gris := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x*paso, bounds.Min.Y+y*paso).RGBA()
+			gris[y*w+x] = 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+		}
+	}
+
+	var suma, sumaCuadrados float64
+	n := 0
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			lap := gris[i-1] + gris[i+1] + gris[i-w] + gris[i+w] - 4*gris[i]
+			suma += lap
+			sumaCuadrados += lap * lap
+			n++
+		}
+	}
+
+	media := suma / float64(n)
+	return sumaCuadrados/float64(n) - media*media
+}
@@ -0,0 +1,260 @@
+package helper
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// Errores de la detección de rostros, para que los llamadores puedan informar el motivo exacto
+var (
+	ErrSinRostro     = errors.New("no se detectó ningún rostro en la imagen")
+	ErrVariosRostros = errors.New("se detectó más de un rostro en la imagen")
+)
+
+const (
+	// ladoTrabajoDeteccion es el lado mayor de la imagen reducida sobre la que se busca piel
+	ladoTrabajoDeteccion = 160
+	// areaMinimaRostro es la fracción mínima de la imagen que debe ocupar una región para ser un rostro
+	areaMinimaRostro = 0.02
+	// areaRelativaSegundoRostro es qué tan grande (respecto de la mayor) debe ser otra región para contarla como rostro
+	areaRelativaSegundoRostro = 0.4
+	// margenRecorte amplía el recuadro detectado para no cortar frente ni mentón
+	margenRecorte = 0.1
+	// franjaOjosInicio y franjaOjosFin delimitan (como fracción del alto del recuadro) dónde se buscan los ojos;
+	// el recuadro puede incluir cuello, por eso la franja queda en la mitad superior
+	franjaOjosInicio = 0.1
+	franjaOjosFin    = 0.55
+	// contrasteOjos es cuánto más oscuro que la piel promedio debe ser un píxel para contar como ojo o ceja
+	contrasteOjos = 0.75
+	// fraccionMinimaOjos es la fracción de cada mitad de la franja que debe ser oscura
+	fraccionMinimaOjos = 0.02
+)
+
+// regionPiel es un componente conexo de píxeles de piel en la imagen reducida
+type regionPiel struct {
+	caja image.Rectangle
+	area int
+}
+
+// DetectarRostros busca regiones con forma de rostro usando segmentación de piel en YCbCr
+// Es una heurística: además de la forma, exige zonas oscuras a ambos lados de la franja de los ojos,
+// lo que descarta manos, brazos o muebles color piel, pero no reemplaza a un detector entrenado
+// Devuelve los recuadros en coordenadas de la imagen original, del más grande al más chico
+func DetectarRostros(img image.Image) []image.Rectangle {
+	bounds := img.Bounds()
+	ancho, alto := bounds.Dx(), bounds.Dy()
+	if ancho == 0 || alto == 0 {
+		return nil
+	}
+
+	// Trabajar sobre una versión reducida: la detección no necesita resolución completa
+	escala := float64(max(ancho, alto)) / ladoTrabajoDeteccion
+	if escala < 1 {
+		escala = 1
+	}
+	w := int(float64(ancho) / escala)
+	h := int(float64(alto) / escala)
+
+	mascara := make([]bool, w*h)
+	luminancia := make([]uint8, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			px := bounds.Min.X + int(float64(x)*escala)
+			py := bounds.Min.Y + int(float64(y)*escala)
+			r, g, b, _ := img.At(px, py).RGBA()
+			yy, cb, cr := color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+			mascara[y*w+x] = esPiel(cb, cr)
+			luminancia[y*w+x] = yy
+		}
+	}
+
+	// Una apertura (erosión + dilatación) elimina puntos sueltos del fondo
+	abierta := dilatar(erosionar(mascara, w, h), w, h)
+
+	regiones := regionesConexas(abierta, w, h)
+	areaMinima := int(areaMinimaRostro * float64(w*h))
+
+	var candidatas []regionPiel
+	for _, reg := range regiones {
+		if reg.area < areaMinima {
+			continue
+		}
+		rw, rh := reg.caja.Dx(), reg.caja.Dy()
+		proporcion := float64(rh) / float64(rw)
+		relleno := float64(reg.area) / float64(rw*rh)
+		// Un rostro (con algo de cuello) es más alto que ancho y llena buena parte de su recuadro
+		if proporcion < 0.7 || proporcion > 2.6 || relleno < 0.35 {
+			continue
+		}
+		if !tieneOjos(reg.caja, mascara, luminancia, w) {
+			continue
+		}
+		candidatas = append(candidatas, reg)
+	}
+
+	sort.Slice(candidatas, func(i, j int) bool { return candidatas[i].area > candidatas[j].area })
+
+	var rostros []image.Rectangle
+	for _, reg := range candidatas {
+		if reg.area < int(areaRelativaSegundoRostro*float64(candidatas[0].area)) {
+			break
+		}
+		rostros = append(rostros, image.Rect(
+			bounds.Min.X+int(float64(reg.caja.Min.X)*escala),
+			bounds.Min.Y+int(float64(reg.caja.Min.Y)*escala),
+			bounds.Min.X+int(float64(reg.caja.Max.X)*escala),
+			bounds.Min.Y+int(float64(reg.caja.Max.Y)*escala),
+		))
+	}
+
+	return rostros
+}
+
+// RecortarRostro detecta el único rostro de la imagen y devuelve solo esa región (con un pequeño margen)
+// Devuelve ErrSinRostro o ErrVariosRostros si no hay exactamente un rostro
+func RecortarRostro(img image.Image) (image.Image, error) {
+	rostros := DetectarRostros(img)
+	if len(rostros) == 0 {
+		return nil, ErrSinRostro
+	}
+	if len(rostros) > 1 {
+		return nil, ErrVariosRostros
+	}
+
+	caja := rostros[0]
+	mx := int(float64(caja.Dx()) * margenRecorte)
+	my := int(float64(caja.Dy()) * margenRecorte)
+	caja = image.Rect(caja.Min.X-mx, caja.Min.Y-my, caja.Max.X+mx, caja.Max.Y+my).Intersect(img.Bounds())
+
+	recorte := image.NewRGBA(image.Rect(0, 0, caja.Dx(), caja.Dy()))
+	draw.Draw(recorte, recorte.Bounds(), img, caja.Min, draw.Src)
+	return recorte, nil
+}
+
+// tieneOjos busca, en la franja superior del recuadro, píxeles bastante más oscuros que la piel y rodeados
+// de piel en su fila (huecos dentro de la cara: ojos y cejas), tanto en la mitad izquierda como en la derecha
+// Una mano o una pared color piel no tienen esas dos zonas oscuras simétricas
+func tieneOjos(caja image.Rectangle, mascara []bool, luminancia []uint8, w int) bool {
+	var sumaPiel, pielContada int
+	for y := caja.Min.Y; y < caja.Max.Y; y++ {
+		for x := caja.Min.X; x < caja.Max.X; x++ {
+			if mascara[y*w+x] {
+				sumaPiel += int(luminancia[y*w+x])
+				pielContada++
+			}
+		}
+	}
+	if pielContada == 0 {
+		return false
+	}
+	umbral := int(contrasteOjos * float64(sumaPiel) / float64(pielContada))
+
+	y0 := caja.Min.Y + int(float64(caja.Dy())*franjaOjosInicio)
+	y1 := caja.Min.Y + int(float64(caja.Dy())*franjaOjosFin)
+	medio := (caja.Min.X + caja.Max.X) / 2
+
+	var oscurosIzq, oscurosDer int
+	for y := y0; y < y1; y++ {
+		// Solo entre la primera y la última piel de la fila: el fondo de los costados no cuenta
+		desde, hasta := caja.Max.X, caja.Min.X
+		for x := caja.Min.X; x < caja.Max.X; x++ {
+			if mascara[y*w+x] {
+				desde = min(desde, x)
+				hasta = max(hasta, x)
+			}
+		}
+		for x := desde + 1; x < hasta; x++ {
+			if int(luminancia[y*w+x]) >= umbral {
+				continue
+			}
+			if x < medio {
+				oscurosIzq++
+			} else {
+				oscurosDer++
+			}
+		}
+	}
+
+	minimo := max(2, int(fraccionMinimaOjos*float64((y1-y0)*(medio-caja.Min.X))))
+	return oscurosIzq >= minimo && oscurosDer >= minimo
+}
+
+// esPiel aplica el rango clásico de crominancia de piel humana, independiente del brillo
+func esPiel(cb, cr uint8) bool {
+	return cb >= 77 && cb <= 127 && cr >= 133 && cr <= 173
+}
+
+// erosionar deja en true solo los píxeles cuyos 4 vecinos también lo son
+func erosionar(m []bool, w, h int) []bool {
+	res := make([]bool, len(m))
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			res[i] = m[i] && m[i-1] && m[i+1] && m[i-w] && m[i+w]
+		}
+	}
+	return res
+}
+
+// dilatar pone en true los píxeles con algún vecino (4-conexo) en true
+func dilatar(m []bool, w, h int) []bool {
+	res := make([]bool, len(m))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			res[i] = m[i] ||
+				(x > 0 && m[i-1]) || (x < w-1 && m[i+1]) ||
+				(y > 0 && m[i-w]) || (y < h-1 && m[i+w])
+		}
+	}
+	return res
+}
+
+// regionesConexas agrupa los píxeles en true en componentes 4-conexos
+func regionesConexas(m []bool, w, h int) []regionPiel {
+	visitado := make([]bool, len(m))
+	var regiones []regionPiel
+	pila := make([]int, 0, 256)
+
+	for inicio := range m {
+		if !m[inicio] || visitado[inicio] {
+			continue
+		}
+
+		// Se construye a mano porque image.Rect normalizaría Min y Max
+		reg := regionPiel{caja: image.Rectangle{Min: image.Pt(w, h), Max: image.Pt(0, 0)}}
+		visitado[inicio] = true
+		pila = append(pila[:0], inicio)
+
+		for len(pila) > 0 {
+			i := pila[len(pila)-1]
+			pila = pila[:len(pila)-1]
+			x, y := i%w, i/w
+
+			reg.area++
+			reg.caja.Min.X = min(reg.caja.Min.X, x)
+			reg.caja.Min.Y = min(reg.caja.Min.Y, y)
+			reg.caja.Max.X = max(reg.caja.Max.X, x+1)
+			reg.caja.Max.Y = max(reg.caja.Max.Y, y+1)
+
+			for _, v := range [4]int{i - 1, i + 1, i - w, i + w} {
+				if v < 0 || v >= len(m) || visitado[v] || !m[v] {
+					continue
+				}
+				// Evitar que i-1 / i+1 salten de fila
+				if (v == i-1 && x == 0) || (v == i+1 && x == w-1) {
+					continue
+				}
+				visitado[v] = true
+				pila = append(pila, v)
+			}
+		}
+
+		regiones = append(regiones, reg)
+	}
+
+	return regiones
+}
@@ -0,0 +1,42 @@
+package helper
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestRecortarRostro(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		dibujar func(img *image.RGBA)
+		err     error
+	}{
+		{"un rostro", func(img *image.RGBA) { dibujarRostro(img, 320, 240, 100, 160, true) }, nil},
+		{"sin rostro", func(img *image.RGBA) {}, ErrSinRostro},
+		// Una mano o un mueble color piel tiene la forma pero no los ojos
+		{"óvalo de piel sin rasgos", func(img *image.RGBA) { dibujarRostro(img, 320, 240, 100, 160, false) }, ErrSinRostro},
+		{"dos rostros", func(img *image.RGBA) {
+			dibujarRostro(img, 170, 240, 90, 140, true)
+			dibujarRostro(img, 470, 240, 90, 140, true)
+		}, ErrVariosRostros},
+		{"rostro y óvalo sin rasgos", func(img *image.RGBA) {
+			dibujarRostro(img, 170, 240, 90, 140, true)
+			dibujarRostro(img, 470, 240, 90, 140, false)
+		}, nil},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			img := fondoSintetico(640, 480)
+			c.dibujar(img)
+
+			recorte, err := RecortarRostro(img)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("error %v, se esperaba %v", err, c.err)
+			}
+			if err == nil && (recorte.Bounds().Dx() >= 640 || recorte.Bounds().Dy() >= 480) {
+				t.Errorf("el recorte %v no es más chico que la imagen", recorte.Bounds())
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// Límites de los documentos de respaldo (certificados médicos, constancias...)
+const (
+	TamanoMaximoDocumento = 10 << 20 // Bytes de cada archivo
+	MaximoDocumentos      = 5        // Archivos por envío
+)
+
+// TiposDocumento son los tipos de contenido aceptados, detectados por el contenido y no por la extensión
+var TiposDocumento = []string{"application/pdf", "image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// ErrDocumentoDemasiadoGrande indica que un documento supera TamanoMaximoDocumento
+var ErrDocumentoDemasiadoGrande = errors.New("el documento supera el tamaño máximo permitido")
+
+// Documento es un archivo subido ya leído y validado
+type Documento struct {
+	Nombre string
+	Tipo   string
+	Datos  []byte
+}
+
+// LimitarCuerpoDocumentos limita el cuerpo de una solicitud multipart con hasta MaximoDocumentos documentos
+func LimitarCuerpoDocumentos(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(TamanoMaximoDocumento)*MaximoDocumentos+1<<20)
+}
+
+// DocumentosFormulario lee y valida los archivos del campo de un formulario multipart
+func DocumentosFormulario(r *http.Request, campo string) ([]Documento, error) {
+	if r.MultipartForm == nil {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+	}
+
+	archivos := r.MultipartForm.File[campo]
+	if len(archivos) > MaximoDocumentos {
+		return nil, fmt.Errorf("se permiten hasta %d documentos", MaximoDocumentos)
+	}
+
+	documentos := make([]Documento, 0, len(archivos))
+	for _, archivo := range archivos {
+		documento, err := leerDocumento(archivo)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", archivo.Filename, err)
+		}
+		documentos = append(documentos, documento)
+	}
+	return documentos, nil
+}
+
+func leerDocumento(archivo *multipart.FileHeader) (Documento, error) {
+	if archivo.Size > TamanoMaximoDocumento {
+		return Documento{}, ErrDocumentoDemasiadoGrande
+	}
+	f, err := archivo.Open()
+	if err != nil {
+		return Documento{}, err
+	}
+	defer f.Close()
+
+	datos, err := io.ReadAll(io.LimitReader(f, TamanoMaximoDocumento+1))
+	if err != nil {
+		return Documento{}, err
+	}
+	if len(datos) > TamanoMaximoDocumento {
+		return Documento{}, ErrDocumentoDemasiadoGrande
+	}
+
+	tipo := http.DetectContentType(datos)
+	soportado := false
+	for _, t := range TiposDocumento {
+		soportado = soportado || t == tipo
+	}
+	if !soportado {
+		return Documento{}, fmt.Errorf("tipo de archivo no soportado: %s (solo PDF e imágenes)", tipo)
+	}
+
+	// El nombre solo se muestra y se usa al descargar; se quitan rutas y comillas
+	nombre := archivo.Filename
+	if i := strings.LastIndexAny(nombre, `/\`); i >= 0 {
+		nombre = nombre[i+1:]
+	}
+	nombre = strings.ReplaceAll(nombre, `"`, "")
+	if nombre == "" {
+		nombre = "documento"
+	}
+	return Documento{Nombre: nombre, Tipo: tipo, Datos: datos}, nil
+}
@@ -0,0 +1,54 @@
+package helper
+
+import (
+	"fmt"
+	"time"
+)
+
+// Backends de reconocimiento facial disponibles para NuevoFaceMatcher
+const (
+	BackendHistograma = "histograma"
+	BackendHTTP       = "http"
+)
+
+// FaceMatcher abstrae el motor de reconocimiento facial
+// Las características son opacas: solo el mismo algoritmo y versión que las generó puede compararlas
+type FaceMatcher interface {
+	// ExtraerCaracteristicas procesa una imagen base64 y devuelve sus características serializadas
+	ExtraerCaracteristicas(foto string) ([]byte, error)
+
+	// Comparar devuelve la similitud entre dos características, en el rango [0, 1]
+	Comparar(referencia, actual []byte) (float64, error)
+
+	// Algoritmo identifica el motor que generó las características
+	Algoritmo() string
+
+	// Version cambia cada vez que las características dejan de ser comparables con las anteriores
+	Version() string
+}
+
+// ComponenteSimilitud es una parte de la similitud calculada por un backend
+type ComponenteSimilitud struct {
+	Nombre string  `json:"nombre"`
+	Valor  float64 `json:"valor"`          // En el rango [0, 1]
+	Peso   float64 `json:"peso,omitempty"` // Peso en la similitud total; 0 si es solo informativo
+}
+
+// MatcherDesglosable lo implementan los backends que pueden explicar una similitud por componentes
+// El desglose se guarda con cada asistencia para que el docente vea por qué una foto pasó o no
+type MatcherDesglosable interface {
+	DesglosarComparacion(referencia, actual []byte) ([]ComponenteSimilitud, error)
+}
+
+// NuevoFaceMatcher crea el backend de reconocimiento indicado por la configuración
+// Para el backend HTTP, url es la dirección base del motor externo
+func NuevoFaceMatcher(backend, url string, timeout time.Duration) (FaceMatcher, error) {
+	switch backend {
+	case "", BackendHistograma:
+		return NuevoMatcherHistograma(), nil
+	case BackendHTTP:
+		return NuevoMatcherHTTP(url, timeout)
+	default:
+		return nil, fmt.Errorf("backend de reconocimiento facial desconocido: %s", backend)
+	}
+}
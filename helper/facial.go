@@ -1,45 +1,52 @@
 package helper
 
 import (
-	"crypto/md5"
+	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"image"
-	_ "image/jpeg"
+	_ "image/gif"
+	"image/jpeg"
 	_ "image/png"
 	"math"
 	"strings"
+
+	_ "golang.org/x/image/webp"
 )
 
-// CompararRostros compara dos imágenes usando análisis de histograma de color y características básicas
-func CompararRostros(fotoReferencia, fotoActual string) (bool, float64, error) {
-	// Validar que ambas imágenes sean válidas
-	if err := ValidarImagenBase64(fotoReferencia); err != nil {
-		return false, 0, fmt.Errorf("foto de referencia inválida: %v", err)
-	}
+// CompararCaracteristicas compara características ya extraídas, sin volver a decodificar las imágenes
+// Devuelve la similitud entre 0 y 1; la decisión según los umbrales la toma ValidadorSimilitud
+func CompararCaracteristicas(referencia, actual *CaracteristicasImagen) float64 {
+	return calcularSimilitudCaracteristicas(referencia, actual)
+}
 
-	if err := ValidarImagenBase64(fotoActual); err != nil {
-		return false, 0, fmt.Errorf("foto actual inválida: %v", err)
+// ExtraerCaracteristicas valida una imagen base64 y extrae sus características para comparación
+func ExtraerCaracteristicas(base64Data string) (*CaracteristicasImagen, error) {
+	if err := ValidarImagenBase64(base64Data); err != nil {
+		return nil, err
 	}
 
-	// Obtener características de las imágenes
-	caracteristicasRef, err := obtenerCaracteristicasImagen(fotoReferencia)
+	caracteristicas, err := obtenerCaracteristicasImagen(base64Data)
 	if err != nil {
-		return false, 0, fmt.Errorf("error procesando foto de referencia: %v", err)
+		return nil, fmt.Errorf("error procesando imagen: %w", err)
 	}
 
-	caracteristicasActual, err := obtenerCaracteristicasImagen(fotoActual)
-	if err != nil {
-		return false, 0, fmt.Errorf("error procesando foto actual: %v", err)
-	}
-
-	// Calcular similitud basada en características
-	similitud := calcularSimilitudCaracteristicas(caracteristicasRef, caracteristicasActual)
+	return caracteristicas, nil
+}
 
-	// Considerar que son la misma persona si la similitud es > 60% (más permisivo)
-	esIgual := similitud > 0.6
+// SerializarCaracteristicas convierte las características a bytes para guardarlas en la base de datos
+func SerializarCaracteristicas(c *CaracteristicasImagen) ([]byte, error) {
+	return json.Marshal(c)
+}
 
-	return esIgual, similitud, nil
+// DeserializarCaracteristicas reconstruye las características guardadas con SerializarCaracteristicas
+func DeserializarCaracteristicas(datos []byte) (*CaracteristicasImagen, error) {
+	var c CaracteristicasImagen
+	if err := json.Unmarshal(datos, &c); err != nil {
+		return nil, fmt.Errorf("características inválidas: %v", err)
+	}
+	return &c, nil
 }
 
 // CaracteristicasImagen contiene características básicas de una imagen para comparación
@@ -52,8 +59,28 @@ type CaracteristicasImagen struct {
 	BrilloPromedio float64  // Brillo promedio
 }
 
-// obtenerCaracteristicasImagen extrae características básicas de una imagen base64
+// obtenerCaracteristicasImagen extrae características básicas del rostro de una imagen base64
+// Antes de calcular los histogramas se recorta el rostro, para que el fondo y la ropa no pesen en la comparación
+// Trabaja sobre la imagen reducida a LadoMaximoTrabajo: el recorte de un rostro no necesita 12 MP
 func obtenerCaracteristicasImagen(base64Data string) (*CaracteristicasImagen, error) {
+	img, err := imagenTrabajoBase64(base64Data)
+	if err != nil {
+		return nil, err
+	}
+	return caracteristicasRostro(img)
+}
+
+// caracteristicasRostro recorta el rostro de la imagen de trabajo y calcula sus características
+func caracteristicasRostro(img *image.RGBA) (*CaracteristicasImagen, error) {
+	rostro, err := RecortarRostro(img)
+	if err != nil {
+		return nil, err
+	}
+	return caracteristicasDeImagen(rostro), nil
+}
+
+// decodificarImagenBase64 decodifica una imagen base64, con o sin prefijo data:
+func decodificarImagenBase64(base64Data string) (image.Image, error) {
 	// Remover prefijo si existe
 	if strings.Contains(base64Data, ",") {
 		parts := strings.Split(base64Data, ",")
@@ -69,11 +96,38 @@ func obtenerCaracteristicasImagen(base64Data string) (*CaracteristicasImagen, er
 	}
 
 	// Decodificar imagen
-	img, _, err := image.Decode(strings.NewReader(string(data)))
+	img, _, err := image.Decode(bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("error decodificando imagen: %v", err)
 	}
 
+	return img, nil
+}
+
+// RecortarRostroBase64 recorta el rostro de una imagen base64 y lo devuelve como JPEG en base64
+// Lo usan los backends que envían la imagen a otro servicio, para que reciban el mismo recorte
+func RecortarRostroBase64(base64Data string) (string, error) {
+	img, err := decodificarImagenBase64(base64Data)
+	if err != nil {
+		return "", err
+	}
+
+	rostro, err := RecortarRostro(img)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, rostro, &jpeg.Options{Quality: 90}); err != nil {
+		return "", fmt.Errorf("error codificando rostro: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// caracteristicasDeImagen calcula histogramas, brillo y dimensiones de una imagen ya decodificada
+// RecortarRostro devuelve *image.RGBA, que se recorre directamente sobre su buffer
+func caracteristicasDeImagen(img image.Image) *CaracteristicasImagen {
 	bounds := img.Bounds()
 	ancho := bounds.Dx()
 	alto := bounds.Dy()
@@ -83,59 +137,105 @@ func obtenerCaracteristicasImagen(base64Data string) (*CaracteristicasImagen, er
 		Alto:  alto,
 	}
 
-	var sumaBrillo float64
+	// Brillo (luminancia) acumulado en milésimas para no convertir a float en cada píxel
+	var sumaBrillo uint64
 	totalPixeles := ancho * alto
 
 	// Procesar cada pixel para obtener histogramas y brillo
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			pixel := img.At(x, y)
-			r, g, b, _ := pixel.RGBA()
-
-			// Convertir de 16-bit a 8-bit
-			r8 := uint8(r >> 8)
-			g8 := uint8(g >> 8)
-			b8 := uint8(b >> 8)
-
-			// Actualizar histogramas
-			caracteristicas.HistogramaR[r8]++
-			caracteristicas.HistogramaG[g8]++
-			caracteristicas.HistogramaB[b8]++
-
-			// Calcular brillo (luminancia)
-			brillo := 0.299*float64(r8) + 0.587*float64(g8) + 0.114*float64(b8)
-			sumaBrillo += brillo
+	if rgba, ok := img.(*image.RGBA); ok {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			fila := rgba.Pix[rgba.PixOffset(bounds.Min.X, y):]
+			for x := 0; x < ancho; x++ {
+				r8, g8, b8 := fila[x*4], fila[x*4+1], fila[x*4+2]
+				caracteristicas.HistogramaR[r8]++
+				caracteristicas.HistogramaG[g8]++
+				caracteristicas.HistogramaB[b8]++
+				sumaBrillo += 299*uint64(r8) + 587*uint64(g8) + 114*uint64(b8)
+			}
+		}
+	} else {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+
+				// Convertir de 16-bit a 8-bit
+				r8 := uint8(r >> 8)
+				g8 := uint8(g >> 8)
+				b8 := uint8(b >> 8)
+
+				caracteristicas.HistogramaR[r8]++
+				caracteristicas.HistogramaG[g8]++
+				caracteristicas.HistogramaB[b8]++
+				sumaBrillo += 299*uint64(r8) + 587*uint64(g8) + 114*uint64(b8)
+			}
 		}
 	}
 
-	caracteristicas.BrilloPromedio = sumaBrillo / float64(totalPixeles)
+	caracteristicas.BrilloPromedio = float64(sumaBrillo) / 1000 / float64(totalPixeles)
 
-	return caracteristicas, nil
+	return caracteristicas
 }
 
-// calcularSimilitudCaracteristicas calcula la similitud entre dos conjuntos de características
-func calcularSimilitudCaracteristicas(c1, c2 *CaracteristicasImagen) float64 {
+// Pesos de cada componente en la similitud total del backend de histogramas
+const (
+	pesoColor       = 0.7
+	pesoBrillo      = 0.2
+	pesoDimensiones = 0.1
+)
+
+// DesgloseSimilitud son los componentes con que se arma la similitud total entre dos imágenes
+type DesgloseSimilitud struct {
+	CanalRojo   float64 // Correlación de los histogramas de cada canal
+	CanalVerde  float64
+	CanalAzul   float64
+	Color       float64 // Promedio de los tres canales
+	Brillo      float64
+	Dimensiones float64
+	Total       float64
+}
+
+// Componentes lista el desglose en el formato genérico que se guarda con cada asistencia
+func (d DesgloseSimilitud) Componentes() []ComponenteSimilitud {
+	return []ComponenteSimilitud{
+		{Nombre: "color", Valor: d.Color, Peso: pesoColor},
+		{Nombre: "brillo", Valor: d.Brillo, Peso: pesoBrillo},
+		{Nombre: "dimensiones", Valor: d.Dimensiones, Peso: pesoDimensiones},
+		{Nombre: "canal_rojo", Valor: d.CanalRojo},
+		{Nombre: "canal_verde", Valor: d.CanalVerde},
+		{Nombre: "canal_azul", Valor: d.CanalAzul},
+	}
+}
+
+// DesglosarSimilitud calcula la similitud entre dos conjuntos de características junto con sus componentes
+func DesglosarSimilitud(c1, c2 *CaracteristicasImagen) DesgloseSimilitud {
+	var d DesgloseSimilitud
+
 	// Calcular similitud de histogramas (correlación)
-	similitudR := calcularCorrelacionHistograma(c1.HistogramaR[:], c2.HistogramaR[:])
-	similitudG := calcularCorrelacionHistograma(c1.HistogramaG[:], c2.HistogramaG[:])
-	similitudB := calcularCorrelacionHistograma(c1.HistogramaB[:], c2.HistogramaB[:])
+	d.CanalRojo = calcularCorrelacionHistograma(c1.HistogramaR[:], c2.HistogramaR[:])
+	d.CanalVerde = calcularCorrelacionHistograma(c1.HistogramaG[:], c2.HistogramaG[:])
+	d.CanalAzul = calcularCorrelacionHistograma(c1.HistogramaB[:], c2.HistogramaB[:])
 
 	// Promedio de similitud de canales de color
-	similitudColor := (similitudR + similitudG + similitudB) / 3.0
+	d.Color = (d.CanalRojo + d.CanalVerde + d.CanalAzul) / 3.0
 
 	// Similitud de brillo (más tolerante)
 	diferenciaBrillo := math.Abs(c1.BrilloPromedio - c2.BrilloPromedio)
-	similitudBrillo := math.Max(0, 1.0-diferenciaBrillo/255.0)
+	d.Brillo = math.Max(0, 1.0-diferenciaBrillo/255.0)
 
 	// Similitud de dimensiones (más tolerante)
 	ratioAncho := float64(min(c1.Ancho, c2.Ancho)) / float64(max(c1.Ancho, c2.Ancho))
 	ratioAlto := float64(min(c1.Alto, c2.Alto)) / float64(max(c1.Alto, c2.Alto))
-	similitudDimensiones := (ratioAncho + ratioAlto) / 2.0
+	d.Dimensiones = (ratioAncho + ratioAlto) / 2.0
 
 	// Combinar todas las similitudes con pesos
-	similitudTotal := (similitudColor * 0.7) + (similitudBrillo * 0.2) + (similitudDimensiones * 0.1)
+	d.Total = (d.Color * pesoColor) + (d.Brillo * pesoBrillo) + (d.Dimensiones * pesoDimensiones)
 
-	return similitudTotal
+	return d
+}
+
+// calcularSimilitudCaracteristicas calcula la similitud entre dos conjuntos de características
+func calcularSimilitudCaracteristicas(c1, c2 *CaracteristicasImagen) float64 {
+	return DesglosarSimilitud(c1, c2).Total
 }
 
 // calcularCorrelacionHistograma calcula la correlación entre dos histogramas
@@ -186,27 +286,6 @@ func max(a, b int) int {
 	return b
 }
 
-// obtenerHashImagen mantiene la implementación anterior como fallback
-func obtenerHashImagen(base64Data string) (string, error) {
-	// Remover prefijo si existe
-	if strings.Contains(base64Data, ",") {
-		parts := strings.Split(base64Data, ",")
-		if len(parts) > 1 {
-			base64Data = parts[1]
-		}
-	}
-
-	// Decodificar base64
-	data, err := base64.StdEncoding.DecodeString(base64Data)
-	if err != nil {
-		return "", fmt.Errorf("error decodificando base64: %v", err)
-	}
-
-	// Calcular hash MD5 de los datos de la imagen
-	hash := md5.Sum(data)
-	return fmt.Sprintf("%x", hash), nil
-}
-
 // ValidarImagenBase64 valida que una cadena base64 sea una imagen válida
 func ValidarImagenBase64(base64Data string) error {
 	// Remover prefijo si existe
@@ -217,22 +296,18 @@ func ValidarImagenBase64(base64Data string) error {
 		}
 	}
 
+	// Verificar el tamaño antes de decodificar
+	if len(base64Data) > base64.StdEncoding.EncodedLen(TamanoMaximoFoto) {
+		return ErrFotoDemasiadoGrande
+	}
+
 	// Decodificar base64
 	data, err := base64.StdEncoding.DecodeString(base64Data)
 	if err != nil {
 		return fmt.Errorf("base64 inválido: %v", err)
 	}
 
-	// Verificar que sea una imagen válida
-	_, format, err := image.DecodeConfig(strings.NewReader(string(data)))
-	if err != nil {
-		return fmt.Errorf("no es una imagen válida: %v", err)
-	}
-
-	// Verificar formatos soportados
-	if format != "jpeg" && format != "png" {
-		return fmt.Errorf("formato no soportado: %s (solo JPEG y PNG)", format)
-	}
-
-	return nil
+	// Verificar que sea una imagen válida, de un formato soportado y de dimensiones razonables
+	_, err = validarCabeceraFoto(data)
+	return err
 }
@@ -0,0 +1,89 @@
+package helper
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"math/bits"
+	"strings"
+)
+
+// Tamaño de la miniatura sobre la que se calcula el hash perceptual (dHash de 64 bits)
+const (
+	anchoHashPerceptual = 9
+	altoHashPerceptual  = 8
+)
+
+// HashExactoImagen calcula el SHA-256 de los bytes de la imagen (sin el prefijo data:)
+// Dos envíos del mismo archivo producen el mismo hash
+func HashExactoImagen(base64Data string) (string, error) {
+	// Remover prefijo si existe
+	if strings.Contains(base64Data, ",") {
+		parts := strings.Split(base64Data, ",")
+		if len(parts) > 1 {
+			base64Data = parts[1]
+		}
+	}
+
+	// Decodificar base64
+	data, err := base64.StdEncoding.DecodeString(base64Data)
+	if err != nil {
+		return "", fmt.Errorf("error decodificando base64: %v", err)
+	}
+
+	hash := sha256.Sum256(data)
+	return fmt.Sprintf("%x", hash), nil
+}
+
+// HashPerceptualImagen calcula un hash de diferencias (dHash) de la imagen completa
+// A diferencia del hash exacto, sobrevive a recompresión, cambio de tamaño y pequeños retoques:
+// dos versiones de la misma foto quedan a pocos bits de distancia (ver DistanciaHamming)
+func HashPerceptualImagen(base64Data string) (uint64, error) {
+	img, err := imagenTrabajoBase64(base64Data)
+	if err != nil {
+		return 0, err
+	}
+	return hashPerceptual(img), nil
+}
+
+// DistanciaHamming cuenta los bits distintos entre dos hashes perceptuales
+func DistanciaHamming(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// hashPerceptual reduce la imagen a 9x8 en escala de grises promediando bloques
+// y arma un bit por cada par de píxeles vecinos: 1 si el de la izquierda es más claro
+func hashPerceptual(img image.Image) uint64 {
+	bounds := img.Bounds()
+	var gris [altoHashPerceptual][anchoHashPerceptual]float64
+
+	for y := 0; y < altoHashPerceptual; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/altoHashPerceptual
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/altoHashPerceptual)
+		for x := 0; x < anchoHashPerceptual; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/anchoHashPerceptual
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/anchoHashPerceptual)
+
+			var suma float64
+			for py := y0; py < y1; py++ {
+				for px := x0; px < x1; px++ {
+					r, g, b, _ := img.At(px, py).RGBA()
+					suma += 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+				}
+			}
+			gris[y][x] = suma / float64((y1-y0)*(x1-x0))
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < altoHashPerceptual; y++ {
+		for x := 0; x < anchoHashPerceptual-1; x++ {
+			hash <<= 1
+			if gris[y][x] > gris[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
@@ -0,0 +1,88 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/jpeg"
+	"math"
+	"testing"
+)
+
+func TestDistanciaHamming(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		a, b      uint64
+		distancia int
+	}{
+		{"iguales", 0xF0F0F0F0F0F0F0F0, 0xF0F0F0F0F0F0F0F0, 0},
+		{"un bit", 0, 1, 1},
+		{"bit más alto", 0, 1 << 63, 1},
+		{"complementarios", 0, ^uint64(0), 64},
+		{"mitad", 0x00000000FFFFFFFF, 0xFFFFFFFFFFFFFFFF, 32},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if d := DistanciaHamming(c.a, c.b); d != c.distancia {
+				t.Errorf("DistanciaHamming(%x, %x) = %d, se esperaba %d", c.a, c.b, d, c.distancia)
+			}
+			if d := DistanciaHamming(c.b, c.a); d != c.distancia {
+				t.Errorf("no es simétrica: %d", d)
+			}
+		})
+	}
+}
+
+// TestHashPerceptualImagen comprueba que el hash sobrevive a recompresión y cambio de tamaño,
+// y que separa fotos distintas
+func TestHashPerceptualImagen(t *testing.T) {
+	original := imagenOndas(800, 600, 90, 120)
+	base := hashDeImagen(t, original, 90)
+
+	casos := []struct {
+		nombre       string
+		img          image.Image
+		calidad      int
+		distanciaMin int
+		distanciaMax int
+	}{
+		{"misma foto", original, 90, 0, 0},
+		{"recomprimida", original, 40, 0, 6},
+		{"reducida", reducirImagen(original, 400), 90, 0, 6},
+		{"otra foto", imagenOndas(800, 600, 60, 45), 90, 10, 64},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			d := DistanciaHamming(base, hashDeImagen(t, c.img, c.calidad))
+			if d < c.distanciaMin || d > c.distanciaMax {
+				t.Errorf("distancia %d, se esperaba entre %d y %d", d, c.distanciaMin, c.distanciaMax)
+			}
+		})
+	}
+}
+
+// imagenOndas es una imagen en grises con ondas de los períodos indicados: sin zonas planas,
+// donde el dHash dependería del ruido
+func imagenOndas(ancho, alto int, periodoX, periodoY float64) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, ancho, alto))
+	for y := 0; y < alto; y++ {
+		for x := 0; x < ancho; x++ {
+			v := 128 + 60*math.Sin(float64(x)/periodoX) + 60*math.Cos(float64(y)/periodoY)
+			img.Pix[img.PixOffset(x, y)] = uint8(v)
+		}
+	}
+	return img
+}
+
+func hashDeImagen(t *testing.T, img image.Image, calidad int) uint64 {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: calidad}); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := HashPerceptualImagen(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
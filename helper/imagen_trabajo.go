@@ -0,0 +1,199 @@
+package helper
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"runtime"
+	"sync"
+)
+
+const (
+	// LadoMaximoTrabajo es el lado mayor de la imagen sobre la que se extraen características
+	// Las fotos de celular (12 MP o más) se reducen a este tamaño antes de recorrer sus píxeles
+	LadoMaximoTrabajo = 640
+	// pixelesMinimosParalelo es el tamaño desde el que la reducción se reparte entre goroutines
+	pixelesMinimosParalelo = 1 << 20
+)
+
+// imagenTrabajoBase64 decodifica una imagen base64 y la reduce a LadoMaximoTrabajo
+// No se guarda entre llamadas: las fotos de una asistencia vienen de la cámara a baja resolución y
+// decodificarlas de nuevo cuesta menos que retenerlas en memoria
+func imagenTrabajoBase64(base64Data string) (*image.RGBA, error) {
+	original, err := decodificarImagenBase64(base64Data)
+	if err != nil {
+		return nil, err
+	}
+	return reducirImagen(original, LadoMaximoTrabajo), nil
+}
+
+// reducirImagen devuelve la imagen como RGBA con su lado mayor limitado a ladoMaximo
+// Cada píxel de destino es el promedio del bloque de origen que cubre (cada píxel de origen se lee una vez)
+// Lee directamente los buffers de *image.YCbCr (JPEG), *image.RGBA, *image.NRGBA e *image.Gray;
+// en imágenes grandes reparte las filas de destino entre goroutines
+func reducirImagen(img image.Image, ladoMaximo int) *image.RGBA {
+	bounds := img.Bounds()
+	ancho, alto := bounds.Dx(), bounds.Dy()
+
+	if max(ancho, alto) <= ladoMaximo {
+		destino := image.NewRGBA(image.Rect(0, 0, ancho, alto))
+		draw.Draw(destino, destino.Bounds(), img, bounds.Min, draw.Src)
+		return destino
+	}
+
+	w := max(1, ancho*ladoMaximo/max(ancho, alto))
+	h := max(1, alto*ladoMaximo/max(ancho, alto))
+	destino := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	// Columna de destino de cada columna de origen
+	columnas := make([]int, ancho)
+	for x := range columnas {
+		columnas[x] = x * w / ancho
+	}
+
+	trabajadores := 1
+	if ancho*alto >= pixelesMinimosParalelo {
+		trabajadores = min(runtime.GOMAXPROCS(0), h)
+	}
+
+	var wg sync.WaitGroup
+	for t := 0; t < trabajadores; t++ {
+		desde, hasta := t*h/trabajadores, (t+1)*h/trabajadores
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ycbcr, ok := img.(*image.YCbCr); ok {
+				reducirFilasYCbCr(ycbcr, destino, columnas, desde, hasta)
+			} else {
+				reducirFilas(img, destino, columnas, desde, hasta)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return destino
+}
+
+// reducirFilasYCbCr es reducirFilas para JPEG: promedia Y, Cb y Cr y convierte a RGB una vez por píxel
+// de destino, en lugar de convertir cada píxel de origen
+func reducirFilasYCbCr(src *image.YCbCr, destino *image.RGBA, columnas []int, desde, hasta int) {
+	alto := src.Rect.Dy()
+	w, h := destino.Rect.Dx(), destino.Rect.Dy()
+	sx, sy := desplazamientoCroma(src.SubsampleRatio)
+	x0, y0 := src.Rect.Min.X, src.Rect.Min.Y
+
+	suma := make([]uint32, w*3)
+	cuenta := make([]uint32, w)
+
+	for oy := desde; oy < hasta; oy++ {
+		desdeY := oy * alto / h
+		hastaY := max(desdeY+1, (oy+1)*alto/h)
+
+		clear(suma)
+		clear(cuenta)
+		for y := y0 + desdeY; y < y0+hastaY; y++ {
+			filaY := src.Y[(y-y0)*src.YStride:]
+			ci := ((y >> sy) - (y0 >> sy)) * src.CStride
+			for x, ox := range columnas {
+				cx := ci + ((x0 + x) >> sx) - (x0 >> sx)
+				suma[ox*3] += uint32(filaY[x])
+				suma[ox*3+1] += uint32(src.Cb[cx])
+				suma[ox*3+2] += uint32(src.Cr[cx])
+				cuenta[ox]++
+			}
+		}
+
+		salida := destino.Pix[oy*destino.Stride:]
+		for ox := 0; ox < w; ox++ {
+			n := cuenta[ox]
+			r, g, b := color.YCbCrToRGB(uint8(suma[ox*3]/n), uint8(suma[ox*3+1]/n), uint8(suma[ox*3+2]/n))
+			salida[ox*4], salida[ox*4+1], salida[ox*4+2], salida[ox*4+3] = r, g, b, 255
+		}
+	}
+}
+
+// reducirFilas calcula las filas [desde, hasta) del destino acumulando las filas de origen que les corresponden
+func reducirFilas(img image.Image, destino *image.RGBA, columnas []int, desde, hasta int) {
+	bounds := img.Bounds()
+	ancho, alto := bounds.Dx(), bounds.Dy()
+	w, h := destino.Rect.Dx(), destino.Rect.Dy()
+
+	fila := make([]uint8, ancho*3)
+	suma := make([]uint32, w*3)
+	cuenta := make([]uint32, w)
+
+	for oy := desde; oy < hasta; oy++ {
+		y0 := oy * alto / h
+		y1 := max(y0+1, (oy+1)*alto/h)
+
+		clear(suma)
+		clear(cuenta)
+		for y := y0; y < y1; y++ {
+			leerFilaRGB(img, bounds.Min.Y+y, fila)
+			for x, ox := range columnas {
+				suma[ox*3] += uint32(fila[x*3])
+				suma[ox*3+1] += uint32(fila[x*3+1])
+				suma[ox*3+2] += uint32(fila[x*3+2])
+				cuenta[ox]++
+			}
+		}
+
+		salida := destino.Pix[oy*destino.Stride:]
+		for ox := 0; ox < w; ox++ {
+			n := cuenta[ox]
+			salida[ox*4] = uint8(suma[ox*3] / n)
+			salida[ox*4+1] = uint8(suma[ox*3+1] / n)
+			salida[ox*4+2] = uint8(suma[ox*3+2] / n)
+			salida[ox*4+3] = 255
+		}
+	}
+}
+
+// leerFilaRGB copia la fila y de la imagen en fila (3 bytes por píxel)
+// Los tipos que produce el decodificador PNG se leen sin pasar por img.At (YCbCr va por reducirFilasYCbCr)
+func leerFilaRGB(img image.Image, y int, fila []uint8) {
+	bounds := img.Bounds()
+
+	switch src := img.(type) {
+	case *image.RGBA:
+		copiarFilaRGBA(src.Pix[src.PixOffset(bounds.Min.X, y):], fila)
+	case *image.NRGBA:
+		copiarFilaRGBA(src.Pix[src.PixOffset(bounds.Min.X, y):], fila)
+	case *image.Gray:
+		pix := src.Pix[src.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < bounds.Dx(); x++ {
+			fila[x*3], fila[x*3+1], fila[x*3+2] = pix[x], pix[x], pix[x]
+		}
+	default:
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			i := (x - bounds.Min.X) * 3
+			fila[i], fila[i+1], fila[i+2] = uint8(r>>8), uint8(g>>8), uint8(b>>8)
+		}
+	}
+}
+
+// copiarFilaRGBA descarta el canal alfa de una fila RGBA/NRGBA
+func copiarFilaRGBA(pix []uint8, fila []uint8) {
+	for x := 0; x < len(fila)/3; x++ {
+		fila[x*3], fila[x*3+1], fila[x*3+2] = pix[x*4], pix[x*4+1], pix[x*4+2]
+	}
+}
+
+// desplazamientoCroma indica cuántos bits se desplazan x e y para llegar a la muestra de croma
+func desplazamientoCroma(ratio image.YCbCrSubsampleRatio) (sx, sy int) {
+	switch ratio {
+	case image.YCbCrSubsampleRatio422:
+		return 1, 0
+	case image.YCbCrSubsampleRatio420:
+		return 1, 1
+	case image.YCbCrSubsampleRatio440:
+		return 0, 1
+	case image.YCbCrSubsampleRatio411:
+		return 2, 0
+	case image.YCbCrSubsampleRatio410:
+		return 2, 1
+	default:
+		return 0, 0
+	}
+}
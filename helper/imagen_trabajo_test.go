@@ -0,0 +1,235 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"math/rand"
+	"testing"
+)
+
+// Miden la extracción de características sobre fotos sintéticas y la comparan con la implementación anterior
+// (img.At sobre la imagen completa). La decodificación JPEG es común a ambas: es el piso del total
+//
+//	go test ./helper -run '^$' -bench . -benchmem
+//	go test ./helper -run '^$' -bench Proceso -cpu 1,4     reducción en una y en varias goroutines
+
+// tamanosBenchmark son una foto de celular de 12 MP y una de webcam
+var tamanosBenchmark = []struct {
+	nombre      string
+	ancho, alto int
+}{
+	{"12MP", 4000, 3000},
+	{"webcam", 1280, 720},
+}
+
+func BenchmarkDecodificacionJPEG(b *testing.B) {
+	for _, t := range tamanosBenchmark {
+		b.Run(t.nombre, func(b *testing.B) {
+			datos := jpegSintetico(b, t.ancho, t.alto)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := jpeg.Decode(bytes.NewReader(datos)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkProcesoAnterior(b *testing.B) {
+	for _, t := range tamanosBenchmark {
+		b.Run(t.nombre, func(b *testing.B) {
+			img := decodificarSintetico(b, t.ancho, t.alto)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := procesarAnterior(img); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkProcesoActual(b *testing.B) {
+	for _, t := range tamanosBenchmark {
+		b.Run(t.nombre, func(b *testing.B) {
+			img := decodificarSintetico(b, t.ancho, t.alto)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := caracteristicasRostro(reducirImagen(img, LadoMaximoTrabajo)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkExtraerCaracteristicas mide el total desde el base64, como en una solicitud
+func BenchmarkExtraerCaracteristicas(b *testing.B) {
+	for _, t := range tamanosBenchmark {
+		b.Run(t.nombre, func(b *testing.B) {
+			foto := base64.StdEncoding.EncodeToString(jpegSintetico(b, t.ancho, t.alto))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := ExtraerCaracteristicas(foto); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// procesarAnterior reproduce la extracción previa a la imagen de trabajo reducida:
+// recorta el rostro de la imagen completa y la recorre con img.At
+func procesarAnterior(img image.Image) (*CaracteristicasImagen, error) {
+	rostro, err := RecortarRostro(img)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := rostro.Bounds()
+	c := &CaracteristicasImagen{Ancho: bounds.Dx(), Alto: bounds.Dy()}
+	var sumaBrillo float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := rostro.At(x, y).RGBA()
+			r8, g8, b8 := uint8(r>>8), uint8(g>>8), uint8(b>>8)
+			c.HistogramaR[r8]++
+			c.HistogramaG[g8]++
+			c.HistogramaB[b8]++
+			sumaBrillo += 0.299*float64(r8) + 0.587*float64(g8) + 0.114*float64(b8)
+		}
+	}
+	c.BrilloPromedio = sumaBrillo / float64(bounds.Dx()*bounds.Dy())
+	return c, nil
+}
+
+func decodificarSintetico(tb testing.TB, ancho, alto int) image.Image {
+	tb.Helper()
+	img, err := jpeg.Decode(bytes.NewReader(jpegSintetico(tb, ancho, alto)))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return img
+}
+
+// jpegSintetico genera un JPEG con fondo azulado y un rostro sintético (óvalo color piel con ojos y boca),
+// suficiente para que el detector lo encuentre
+func jpegSintetico(tb testing.TB, ancho, alto int) []byte {
+	tb.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, imagenSintetica(ancho, alto), &jpeg.Options{Quality: 90}); err != nil {
+		tb.Fatal(fmt.Errorf("codificando JPEG sintético: %w", err))
+	}
+	return buf.Bytes()
+}
+
+func imagenSintetica(ancho, alto int) *image.RGBA {
+	img := fondoSintetico(ancho, alto)
+	dibujarRostro(img, ancho/2, alto/2, ancho/6, alto/3, true)
+	return img
+}
+
+// fondoSintetico es un fondo azulado con ruido, sin ningún tono de piel
+func fondoSintetico(ancho, alto int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, ancho, alto))
+	azar := rand.New(rand.NewSource(1))
+	for y := 0; y < alto; y++ {
+		for x := 0; x < ancho; x++ {
+			i := img.PixOffset(x, y)
+			ruido := uint8(azar.Intn(12))
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 40+ruido, 70+ruido, uint8(110+y*60/alto), 255
+		}
+	}
+	return img
+}
+
+// dibujarRostro pinta un óvalo color piel con centro (cx, cy) y radios (rx, ry); con rasgos, también ojos y boca
+func dibujarRostro(img *image.RGBA, cx, cy, rx, ry int, rasgos bool) {
+	azar := rand.New(rand.NewSource(2))
+	for y := max(0, cy-ry); y <= min(img.Rect.Dy()-1, cy+ry); y++ {
+		for x := max(0, cx-rx); x <= min(img.Rect.Dx()-1, cx+rx); x++ {
+			dx := float64(x-cx) / float64(rx)
+			dy := float64(y-cy) / float64(ry)
+			if dx*dx+dy*dy > 1 {
+				continue
+			}
+			i := img.PixOffset(x, y)
+			ruido := uint8(azar.Intn(12))
+			if rasgos && esRasgoSintetico(dx, dy) {
+				img.Pix[i], img.Pix[i+1], img.Pix[i+2] = 60+ruido, 40+ruido, 30+ruido
+			} else {
+				img.Pix[i], img.Pix[i+1], img.Pix[i+2] = 200+ruido, 150+ruido, 120+ruido
+			}
+		}
+	}
+}
+
+// esRasgoSintetico indica si el punto (relativo al centro y radios del óvalo) cae en un ojo o en la boca
+func esRasgoSintetico(dx, dy float64) bool {
+	for _, ojo := range [2]float64{-0.4, 0.4} {
+		ex, ey := (dx-ojo)/0.15, (dy+0.3)/0.06
+		if ex*ex+ey*ey <= 1 {
+			return true
+		}
+	}
+	bx, by := dx/0.3, (dy-0.45)/0.05
+	return bx*bx+by*by <= 1
+}
+
+func TestReducirImagen(t *testing.T) {
+	casos := []struct {
+		nombre                string
+		ancho, alto, maximo   int
+		anchoFinal, altoFinal int
+	}{
+		{"menor que el máximo", 320, 240, 640, 320, 240},
+		{"igual al máximo", 640, 480, 640, 640, 480},
+		{"horizontal", 4000, 3000, 640, 640, 480},
+		{"vertical", 3000, 4000, 640, 480, 640},
+		{"muy angosta", 5000, 4, 640, 640, 1},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			img := image.NewRGBA(image.Rect(0, 0, c.ancho, c.alto))
+			for i := 0; i < len(img.Pix); i += 4 {
+				img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 200, 100, 50, 255
+			}
+
+			reducida := reducirImagen(img, c.maximo)
+			if reducida.Rect.Dx() != c.anchoFinal || reducida.Rect.Dy() != c.altoFinal {
+				t.Fatalf("tamaño %dx%d, se esperaba %dx%d", reducida.Rect.Dx(), reducida.Rect.Dy(), c.anchoFinal, c.altoFinal)
+			}
+			// Promediar bloques de un color uniforme no debe cambiarlo
+			if r, g, b, _ := reducida.At(reducida.Rect.Dx()/2, reducida.Rect.Dy()/2).RGBA(); r>>8 != 200 || g>>8 != 100 || b>>8 != 50 {
+				t.Errorf("color (%d, %d, %d), se esperaba (200, 100, 50)", r>>8, g>>8, b>>8)
+			}
+		})
+	}
+}
+
+// TestReducirImagenYCbCr compara el camino directo sobre JPEG con el genérico: deben dar casi el mismo color
+func TestReducirImagenYCbCr(t *testing.T) {
+	img := decodificarSintetico(t, 1600, 1200)
+	ycbcr, ok := img.(*image.YCbCr)
+	if !ok {
+		t.Fatalf("el JPEG se decodificó como %T", img)
+	}
+
+	directa := reducirImagen(ycbcr, 400)
+	generica := image.NewRGBA(directa.Rect)
+	columnas := make([]int, ycbcr.Rect.Dx())
+	for x := range columnas {
+		columnas[x] = x * directa.Rect.Dx() / ycbcr.Rect.Dx()
+	}
+	reducirFilas(ycbcr, generica, columnas, 0, directa.Rect.Dy())
+
+	for i := range directa.Pix {
+		if d := int(directa.Pix[i]) - int(generica.Pix[i]); d > 3 || d < -3 {
+			t.Fatalf("byte %d: %d contra %d", i, directa.Pix[i], generica.Pix[i])
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package helper
+
+// MatcherHistograma implementa FaceMatcher comparando histogramas de color, brillo y dimensiones
+type MatcherHistograma struct{}
+
+// NuevoMatcherHistograma crea el backend de histogramas (el que se usa por defecto)
+func NuevoMatcherHistograma() *MatcherHistograma {
+	return &MatcherHistograma{}
+}
+
+// ExtraerCaracteristicas recorta el rostro, obtiene sus histogramas y los serializa
+func (m *MatcherHistograma) ExtraerCaracteristicas(foto string) ([]byte, error) {
+	caracteristicas, err := ExtraerCaracteristicas(foto)
+	if err != nil {
+		return nil, err
+	}
+	return SerializarCaracteristicas(caracteristicas)
+}
+
+// Comparar deserializa ambas características y calcula su similitud
+func (m *MatcherHistograma) Comparar(referencia, actual []byte) (float64, error) {
+	c1, err := DeserializarCaracteristicas(referencia)
+	if err != nil {
+		return 0, err
+	}
+	c2, err := DeserializarCaracteristicas(actual)
+	if err != nil {
+		return 0, err
+	}
+	similitud := CompararCaracteristicas(c1, c2)
+	return similitud, nil
+}
+
+// DesglosarComparacion devuelve los componentes de la similitud: color, brillo y dimensiones con sus pesos,
+// y la correlación de cada canal como información
+func (m *MatcherHistograma) DesglosarComparacion(referencia, actual []byte) ([]ComponenteSimilitud, error) {
+	c1, err := DeserializarCaracteristicas(referencia)
+	if err != nil {
+		return nil, err
+	}
+	c2, err := DeserializarCaracteristicas(actual)
+	if err != nil {
+		return nil, err
+	}
+	return DesglosarSimilitud(c1, c2).Componentes(), nil
+}
+
+// Algoritmo devuelve el nombre del backend
+func (m *MatcherHistograma) Algoritmo() string {
+	return "histograma-rgb"
+}
+
+// Version debe cambiar cada vez que cambie la extracción o el formato, para que se recalculen
+// v2: los histogramas se calculan sobre el rostro recortado, no sobre la imagen completa
+// v3: el rostro se recorta de la imagen reducida a LadoMaximoTrabajo
+func (m *MatcherHistograma) Version() string {
+	return "3"
+}
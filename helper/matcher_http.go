@@ -0,0 +1,152 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MatcherHTTP implementa FaceMatcher delegando la extracción a un motor de reconocimiento externo
+//
+// El motor debe exponer:
+//
+//	GET  /info     → {"algoritmo": "...", "version": "..."}
+//	POST /extraer  {"imagen": "<base64>"} → {"caracteristicas": [0.12, -0.4, ...]}
+//
+// La comparación de los vectores se hace localmente con similitud coseno,
+// así la verificación de una asistencia solo hace una llamada al motor.
+type MatcherHTTP struct {
+	url       string
+	cliente   *http.Client
+	algoritmo string
+	version   string
+}
+
+type infoMotorHTTP struct {
+	Algoritmo string `json:"algoritmo"`
+	Version   string `json:"version"`
+}
+
+type solicitudExtraerHTTP struct {
+	Imagen string `json:"imagen"`
+}
+
+type respuestaExtraerHTTP struct {
+	Caracteristicas []float64 `json:"caracteristicas"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// NuevoMatcherHTTP crea el backend HTTP y consulta al motor qué algoritmo y versión usa
+func NuevoMatcherHTTP(url string, timeout time.Duration) (*MatcherHTTP, error) {
+	if url == "" {
+		return nil, fmt.Errorf("falta la URL del motor de reconocimiento facial")
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	m := &MatcherHTTP{
+		url:     strings.TrimRight(url, "/"),
+		cliente: &http.Client{Timeout: timeout},
+	}
+
+	resp, err := m.cliente.Get(m.url + "/info")
+	if err != nil {
+		return nil, fmt.Errorf("error al contactar el motor de reconocimiento: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("el motor de reconocimiento respondió %s", resp.Status)
+	}
+
+	var info infoMotorHTTP
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("respuesta inválida del motor de reconocimiento: %v", err)
+	}
+	if info.Algoritmo == "" || info.Version == "" {
+		return nil, fmt.Errorf("el motor de reconocimiento no informó algoritmo y versión")
+	}
+
+	m.algoritmo = "http:" + info.Algoritmo
+	m.version = info.Version
+	return m, nil
+}
+
+// ExtraerCaracteristicas recorta el rostro, lo envía al motor y guarda el vector que devuelve
+func (m *MatcherHTTP) ExtraerCaracteristicas(foto string) ([]byte, error) {
+	if err := ValidarImagenBase64(foto); err != nil {
+		return nil, err
+	}
+
+	// El mismo recorte que usa el backend de histogramas, en el registro y en la verificación
+	rostro, err := RecortarRostroBase64(foto)
+	if err != nil {
+		return nil, err
+	}
+
+	cuerpo, err := json.Marshal(solicitudExtraerHTTP{Imagen: rostro})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := m.cliente.Post(m.url+"/extraer", "application/json", bytes.NewReader(cuerpo))
+	if err != nil {
+		return nil, fmt.Errorf("error al contactar el motor de reconocimiento: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var respuesta respuestaExtraerHTTP
+	if err := json.NewDecoder(resp.Body).Decode(&respuesta); err != nil {
+		return nil, fmt.Errorf("respuesta inválida del motor de reconocimiento: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("el motor de reconocimiento rechazó la imagen: %s", respuesta.Error)
+	}
+	if len(respuesta.Caracteristicas) == 0 {
+		return nil, fmt.Errorf("el motor de reconocimiento no devolvió características")
+	}
+
+	return json.Marshal(respuesta.Caracteristicas)
+}
+
+// Comparar calcula la similitud coseno entre los vectores, llevada al rango [0, 1]
+func (m *MatcherHTTP) Comparar(referencia, actual []byte) (float64, error) {
+	var v1, v2 []float64
+	if err := json.Unmarshal(referencia, &v1); err != nil {
+		return 0, fmt.Errorf("características de referencia inválidas: %v", err)
+	}
+	if err := json.Unmarshal(actual, &v2); err != nil {
+		return 0, fmt.Errorf("características actuales inválidas: %v", err)
+	}
+	if len(v1) != len(v2) || len(v1) == 0 {
+		return 0, fmt.Errorf("las características no tienen la misma dimensión (%d y %d)", len(v1), len(v2))
+	}
+
+	var producto, norma1, norma2 float64
+	for i := range v1 {
+		producto += v1[i] * v2[i]
+		norma1 += v1[i] * v1[i]
+		norma2 += v2[i] * v2[i]
+	}
+	if norma1 == 0 || norma2 == 0 {
+		return 0, nil
+	}
+
+	coseno := producto / (math.Sqrt(norma1) * math.Sqrt(norma2))
+	return (coseno + 1) / 2, nil
+}
+
+// Algoritmo devuelve el algoritmo informado por el motor
+func (m *MatcherHTTP) Algoritmo() string {
+	return m.algoritmo
+}
+
+// Version devuelve la versión informada por el motor
+func (m *MatcherHTTP) Version() string {
+	return m.version
+}
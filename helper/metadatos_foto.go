@@ -0,0 +1,214 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"strings"
+	"time"
+)
+
+// Etiquetas EXIF que se leen
+const (
+	etiquetaSoftware          = 0x0131
+	etiquetaFechaHora         = 0x0132
+	etiquetaPunteroExif       = 0x8769
+	etiquetaFechaOriginal     = 0x9003
+	etiquetaZonaFechaOriginal = 0x9011
+	etiquetaComentario        = 0x9286
+)
+
+// marcadoresEdicion son fragmentos del campo Software que delatan un programa de edición
+var marcadoresEdicion = []string{
+	"photoshop", "lightroom", "gimp", "snapseed", "picsart", "canva", "pixelmator",
+	"affinity", "paint.net", "facetune", "meitu", "vsco", "faceapp", "remini",
+}
+
+// proporcionMinimaPantalla es la relación entre lados desde la que una imagen tiene forma de pantalla de
+// celular (19.5:9, 20:9...); las cámaras y las webcams sacan fotos 4:3 o 16:9
+const proporcionMinimaPantalla = 2.0
+
+// MetadatosFoto son los datos de una foto que sirven para juzgar si es una captura reciente
+type MetadatosFoto struct {
+	Formato string
+	Ancho   int
+	Alto    int
+
+	// TieneExif es falso en las fotos sin metadatos, como las que arma el navegador desde la cámara
+	TieneExif bool
+	// FechaCaptura es DateTimeOriginal (o DateTime si falta); sin zona horaria en EXIF se asume la local
+	// y FechaSinZona lo indica, para que quien sepa en qué zona se tomó la foto la reinterprete
+	FechaCaptura *time.Time
+	FechaSinZona bool
+	Software     string
+	Comentario   string
+}
+
+// LeerMetadatosFoto decodifica la cabecera de una imagen base64 y, si es JPEG, su bloque EXIF
+// Un EXIF mal formado no es un error: se informa como foto sin EXIF
+func LeerMetadatosFoto(base64Data string) (*MetadatosFoto, error) {
+	if i := strings.Index(base64Data, ","); i >= 0 {
+		base64Data = base64Data[i+1:]
+	}
+	data, err := base64.StdEncoding.DecodeString(base64Data)
+	if err != nil {
+		return nil, fmt.Errorf("base64 inválido: %v", err)
+	}
+
+	config, formato, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("no es una imagen válida: %v", err)
+	}
+
+	metadatos := &MetadatosFoto{Formato: formato, Ancho: config.Width, Alto: config.Height}
+	if formato == "jpeg" {
+		if tiff := bloqueExif(data); tiff != nil {
+			leerExif(tiff, metadatos)
+		}
+	}
+	return metadatos, nil
+}
+
+// SoftwareEdicion devuelve el programa de edición que figura en los metadatos, o "" si no hay
+func (m *MetadatosFoto) SoftwareEdicion() string {
+	software := strings.ToLower(m.Software)
+	for _, marcador := range marcadoresEdicion {
+		if strings.Contains(software, marcador) {
+			return m.Software
+		}
+	}
+	return ""
+}
+
+// EsCapturaPantalla indica si la foto parece una captura de pantalla: así lo dice su comentario EXIF
+// (iOS escribe "Screenshot"), es un PNG (las cámaras y el formulario de captura generan JPEG) o tiene
+// la forma alargada de una pantalla de celular
+func (m *MetadatosFoto) EsCapturaPantalla() bool {
+	if strings.Contains(strings.ToLower(m.Comentario), "screenshot") || m.Formato == "png" {
+		return true
+	}
+	lado, otro := max(m.Ancho, m.Alto), min(m.Ancho, m.Alto)
+	return otro > 0 && float64(lado)/float64(otro) >= proporcionMinimaPantalla
+}
+
+// bloqueExif busca el segmento APP1 "Exif" de un JPEG y devuelve su contenido TIFF
+func bloqueExif(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return nil
+		}
+		marcador := data[i+1]
+		// Inicio de los datos de la imagen: ya no hay más segmentos de metadatos
+		if marcador == 0xDA || marcador == 0xD9 {
+			return nil
+		}
+		largo := int(binary.BigEndian.Uint16(data[i+2:]))
+		if largo < 2 || i+2+largo > len(data) {
+			return nil
+		}
+		segmento := data[i+4 : i+2+largo]
+		if marcador == 0xE1 && bytes.HasPrefix(segmento, []byte("Exif\x00\x00")) {
+			return segmento[6:]
+		}
+		i += 2 + largo
+	}
+	return nil
+}
+
+// leerExif recorre IFD0 y la sub-IFD Exif y completa los campos que se usan
+func leerExif(tiff []byte, m *MetadatosFoto) {
+	if len(tiff) < 8 {
+		return
+	}
+	var orden binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		orden = binary.LittleEndian
+	case "MM":
+		orden = binary.BigEndian
+	default:
+		return
+	}
+	if orden.Uint16(tiff[2:]) != 42 {
+		return
+	}
+	m.TieneExif = true
+
+	valores := make(map[uint16]string)
+	punteroExif := leerIFD(tiff, orden, orden.Uint32(tiff[4:]), valores)
+	if punteroExif != 0 {
+		leerIFD(tiff, orden, punteroExif, valores)
+	}
+
+	m.Software = valores[etiquetaSoftware]
+	m.Comentario = valores[etiquetaComentario]
+
+	fecha := valores[etiquetaFechaOriginal]
+	if fecha == "" {
+		fecha = valores[etiquetaFechaHora]
+	}
+	if fecha == "" {
+		return
+	}
+	zona := time.Local
+	if desplazamiento, err := time.Parse("-07:00", valores[etiquetaZonaFechaOriginal]); err == nil {
+		zona = desplazamiento.Location()
+	}
+	if t, err := time.ParseInLocation("2006:01:02 15:04:05", fecha, zona); err == nil {
+		m.FechaCaptura = &t
+		m.FechaSinZona = zona == time.Local
+	}
+}
+
+// leerIFD guarda en valores las etiquetas de texto de una IFD y devuelve el puntero a la sub-IFD Exif, si está
+func leerIFD(tiff []byte, orden binary.ByteOrder, offset uint32, valores map[uint16]string) uint32 {
+	if int(offset)+2 > len(tiff) {
+		return 0
+	}
+	entradas := int(orden.Uint16(tiff[offset:]))
+	var punteroExif uint32
+
+	for e := 0; e < entradas; e++ {
+		inicio := int(offset) + 2 + e*12
+		if inicio+12 > len(tiff) {
+			break
+		}
+		etiqueta := orden.Uint16(tiff[inicio:])
+		tipo := orden.Uint16(tiff[inicio+2:])
+		cantidad := int(orden.Uint32(tiff[inicio+4:]))
+
+		if etiqueta == etiquetaPunteroExif {
+			punteroExif = orden.Uint32(tiff[inicio+8:])
+			continue
+		}
+		// Solo texto: ASCII (2) y UNDEFINED (7, el comentario)
+		if tipo != 2 && tipo != 7 {
+			continue
+		}
+
+		valor := tiff[inicio+8 : inicio+12]
+		if cantidad > 4 {
+			desde := int(orden.Uint32(tiff[inicio+8:]))
+			if cantidad < 0 || desde+cantidad > len(tiff) {
+				continue
+			}
+			valor = tiff[desde : desde+cantidad]
+		} else {
+			valor = valor[:cantidad]
+		}
+
+		texto := string(valor)
+		// UserComment empieza con 8 bytes que indican la codificación
+		if etiqueta == etiquetaComentario && len(texto) >= 8 {
+			texto = texto[8:]
+		}
+		valores[etiqueta] = strings.TrimSpace(strings.TrimRight(texto, "\x00"))
+	}
+	return punteroExif
+}
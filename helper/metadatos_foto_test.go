@@ -0,0 +1,222 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLeerMetadatosFoto(t *testing.T) {
+	casos := []struct {
+		nombre     string
+		exif       []byte
+		tieneExif  bool
+		fecha      time.Time // Cero si no debe haber fecha
+		sinZona    bool
+		software   string
+		comentario string
+	}{
+		{nombre: "sin EXIF"},
+		{
+			nombre: "little endian con zona",
+			exif: tiffExif(binary.LittleEndian,
+				map[uint16]string{etiquetaSoftware: "iOS 17.2"},
+				map[uint16]string{etiquetaFechaOriginal: "2026:03:10 08:15:00", etiquetaZonaFechaOriginal: "-04:00"}),
+			tieneExif: true,
+			fecha:     time.Date(2026, 3, 10, 12, 15, 0, 0, time.UTC),
+			software:  "iOS 17.2",
+		},
+		{
+			nombre: "big endian sin zona, con DateTime",
+			exif: tiffExif(binary.BigEndian,
+				map[uint16]string{etiquetaFechaHora: "2025:12:31 23:59:59", etiquetaSoftware: "Adobe Photoshop 25.0"},
+				nil),
+			tieneExif: true,
+			fecha:     time.Date(2025, 12, 31, 23, 59, 59, 0, time.Local),
+			sinZona:   true,
+			software:  "Adobe Photoshop 25.0",
+		},
+		{
+			nombre: "comentario de captura de pantalla",
+			exif: tiffExif(binary.LittleEndian, nil,
+				map[uint16]string{etiquetaComentario: "ASCII\x00\x00\x00Screenshot"}),
+			tieneExif:  true,
+			comentario: "Screenshot",
+		},
+		{
+			nombre:    "fecha mal escrita",
+			exif:      tiffExif(binary.LittleEndian, map[uint16]string{etiquetaFechaHora: "ayer"}, nil),
+			tieneExif: true,
+		},
+		{nombre: "cabecera TIFF inválida", exif: []byte("XX\x2a\x00\x08\x00\x00\x00")},
+		{nombre: "bloque truncado", exif: []byte("II")},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			m, err := LeerMetadatosFoto(jpegConExif(t, c.exif))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Formato != "jpeg" || m.Ancho != 64 || m.Alto != 48 {
+				t.Errorf("formato %s %dx%d, se esperaba jpeg 64x48", m.Formato, m.Ancho, m.Alto)
+			}
+			if m.TieneExif != c.tieneExif {
+				t.Errorf("TieneExif = %v, se esperaba %v", m.TieneExif, c.tieneExif)
+			}
+			switch {
+			case c.fecha.IsZero() && m.FechaCaptura != nil:
+				t.Errorf("fecha %v, se esperaba ninguna", m.FechaCaptura)
+			case !c.fecha.IsZero() && (m.FechaCaptura == nil || !m.FechaCaptura.Equal(c.fecha)):
+				t.Errorf("fecha %v, se esperaba %v", m.FechaCaptura, c.fecha)
+			}
+			if m.FechaSinZona != c.sinZona {
+				t.Errorf("FechaSinZona = %v, se esperaba %v", m.FechaSinZona, c.sinZona)
+			}
+			if m.Software != c.software || m.Comentario != c.comentario {
+				t.Errorf("software %q y comentario %q, se esperaban %q y %q", m.Software, m.Comentario, c.software, c.comentario)
+			}
+		})
+	}
+}
+
+func TestSoftwareEdicion(t *testing.T) {
+	casos := map[string]bool{
+		"":                     false,
+		"iOS 17.2":             false,
+		"Adobe Photoshop 25.0": true,
+		"GIMP 2.10.36":         true,
+		"Snapseed 2.0":         true,
+	}
+	for software, editada := range casos {
+		m := &MetadatosFoto{Software: software}
+		if got := m.SoftwareEdicion() != ""; got != editada {
+			t.Errorf("SoftwareEdicion con %q: editada = %v, se esperaba %v", software, got, editada)
+		}
+	}
+}
+
+func TestEsCapturaPantalla(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		m       MetadatosFoto
+		captura bool
+	}{
+		{"foto de webcam", MetadatosFoto{Formato: "jpeg", Ancho: 1280, Alto: 720}, false},
+		{"foto de celular 4:3", MetadatosFoto{Formato: "jpeg", Ancho: 3000, Alto: 4000}, false},
+		{"formulario de captura", MetadatosFoto{Formato: "jpeg", Ancho: 400, Alto: 300}, false},
+		{"PNG", MetadatosFoto{Formato: "png", Ancho: 640, Alto: 480}, true},
+		{"pantalla de celular en JPEG", MetadatosFoto{Formato: "jpeg", Ancho: 1170, Alto: 2532}, true},
+		{"comentario de iOS", MetadatosFoto{Formato: "jpeg", Ancho: 3000, Alto: 4000, Comentario: "Screenshot"}, true},
+		{"sin tamaño", MetadatosFoto{Formato: "jpeg"}, false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if got := c.m.EsCapturaPantalla(); got != c.captura {
+				t.Errorf("EsCapturaPantalla() = %v, se esperaba %v", got, c.captura)
+			}
+		})
+	}
+}
+
+func TestLeerMetadatosFotoPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 30, 20))); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LeerMetadatosFoto("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Formato != "png" || m.TieneExif || !m.EsCapturaPantalla() {
+		t.Errorf("metadatos %+v: se esperaba un PNG sin EXIF tomado como captura", m)
+	}
+}
+
+// jpegConExif genera un JPEG de 64x48 en base64 con el bloque TIFF en un segmento APP1 "Exif" (nil: sin EXIF)
+func jpegConExif(t *testing.T, tiff []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 64, 48)), nil); err != nil {
+		t.Fatal(err)
+	}
+	datos := buf.Bytes()
+	if tiff == nil {
+		return base64.StdEncoding.EncodeToString(datos)
+	}
+
+	contenido := append([]byte("Exif\x00\x00"), tiff...)
+	segmento := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segmento[2:], uint16(len(contenido)+2))
+	segmento = append(segmento, contenido...)
+
+	conExif := append(append(append([]byte{}, datos[:2]...), segmento...), datos[2:]...)
+	return base64.StdEncoding.EncodeToString(conExif)
+}
+
+// tiffExif arma un bloque TIFF con las etiquetas de texto de IFD0 y, si hay, de la sub-IFD Exif
+func tiffExif(orden binary.AppendByteOrder, ifd0, exif map[uint16]string) []byte {
+	cabecera := []byte("II\x2a\x00\x08\x00\x00\x00")
+	if orden == binary.AppendByteOrder(binary.BigEndian) {
+		cabecera = []byte("MM\x00\x2a\x00\x00\x00\x08")
+	}
+
+	entradas0 := len(ifd0)
+	if len(exif) > 0 {
+		entradas0++
+	}
+	offsetExif := 8 + 2 + 12*entradas0 + 4
+	offsetDatos := offsetExif
+	if len(exif) > 0 {
+		offsetDatos += 2 + 12*len(exif) + 4
+	}
+
+	var datos []byte
+	ifd := func(valores map[uint16]string, punteroExif bool) []byte {
+		etiquetas := make([]int, 0, len(valores))
+		for e := range valores {
+			etiquetas = append(etiquetas, int(e))
+		}
+		sort.Ints(etiquetas)
+
+		cantidad := len(etiquetas)
+		if punteroExif {
+			cantidad++
+		}
+		b := orden.AppendUint16(nil, uint16(cantidad))
+		for _, e := range etiquetas {
+			texto := append([]byte(valores[uint16(e)]), 0)
+			tipo := uint16(2)
+			if uint16(e) == etiquetaComentario {
+				tipo, texto = 7, texto[:len(texto)-1]
+			}
+			b = orden.AppendUint16(b, uint16(e))
+			b = orden.AppendUint16(b, tipo)
+			b = orden.AppendUint32(b, uint32(len(texto)))
+			if len(texto) <= 4 {
+				b = append(b, append(texto, make([]byte, 4-len(texto))...)...)
+				continue
+			}
+			b = orden.AppendUint32(b, uint32(offsetDatos+len(datos)))
+			datos = append(datos, texto...)
+		}
+		if punteroExif {
+			b = orden.AppendUint16(b, etiquetaPunteroExif)
+			b = orden.AppendUint16(b, 4)
+			b = orden.AppendUint32(b, 1)
+			b = orden.AppendUint32(b, uint32(offsetExif))
+		}
+		return orden.AppendUint32(b, 0)
+	}
+
+	tiff := append(cabecera, ifd(ifd0, len(exif) > 0)...)
+	if len(exif) > 0 {
+		tiff = append(tiff, ifd(exif, false)...)
+	}
+	return append(tiff, datos...)
+}
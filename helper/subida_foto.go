@@ -0,0 +1,147 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Límites de las fotos subidas; se controlan antes de decodificar la imagen completa
+const (
+	TamanoMaximoFoto   = 10 << 20   // Bytes del archivo
+	PixelesMaximosFoto = 16_000_000 // Ancho por alto (la foto de un teléfono), para no decodificar imágenes desproporcionadas
+	// TamanoMaximoCaptura son los bytes de cada foto de una asistencia (la de verificación y los fotogramas),
+	// que la página toma de la cámara a baja resolución y pesan unas decenas de KB
+	TamanoMaximoCaptura = 1 << 20
+)
+
+// FormatosFoto son los formatos de imagen aceptados (los nombres que usa image.DecodeConfig)
+var FormatosFoto = []string{"jpeg", "png", "gif", "webp"}
+
+// ErrFotoDemasiadoGrande indica que una foto o la solicitud que la trae supera los límites
+var ErrFotoDemasiadoGrande = errors.New("la foto supera el tamaño máximo permitido")
+
+// LimitarCuerpoFotos limita el cuerpo de una solicitud que trae hasta cantidad fotos, en binario o en base64
+// Al superarlo, la lectura del cuerpo falla con *http.MaxBytesError
+func LimitarCuerpoFotos(w http.ResponseWriter, r *http.Request, cantidad int) {
+	r.Body = http.MaxBytesReader(w, r.Body, limiteCuerpo(cantidad, TamanoMaximoFoto))
+}
+
+// LimitarCuerpoCapturas limita el cuerpo de un registro de asistencia: la foto de verificación y los
+// fotogramas del desafío, de hasta TamanoMaximoCaptura cada uno
+func LimitarCuerpoCapturas(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, limiteCuerpo(FotogramasMaximos+1, TamanoMaximoCaptura))
+}
+
+// limiteCuerpo son los bytes de cantidad fotos de hasta tamano bytes en base64 (4/3 del binario),
+// más un margen para los demás campos
+func limiteCuerpo(cantidad, tamano int) int64 {
+	return int64(base64.StdEncoding.EncodedLen(tamano))*int64(cantidad) + 1<<20
+}
+
+// EsErrorTamano indica si err se debe a una foto o a un cuerpo de solicitud demasiado grandes
+func EsErrorTamano(err error) bool {
+	var errMaximo *http.MaxBytesError
+	return errors.Is(err, ErrFotoDemasiadoGrande) || errors.As(err, &errMaximo)
+}
+
+// TipoContenido devuelve el tipo MIME de la solicitud sin parámetros ("multipart/form-data", "image/webp"...)
+func TipoContenido(r *http.Request) string {
+	tipo, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return tipo
+}
+
+// EsCuerpoImagen indica si el cuerpo de la solicitud es directamente una imagen (Content-Type image/*)
+func EsCuerpoImagen(r *http.Request) bool {
+	return strings.HasPrefix(TipoContenido(r), "image/")
+}
+
+// LeerFotoBinaria lee una foto en binario (una parte de multipart o un cuerpo image/*) y la devuelve
+// en base64 con prefijo data:, igual que las que llegan en JSON
+func LeerFotoBinaria(r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, TamanoMaximoFoto+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > TamanoMaximoFoto {
+		return "", ErrFotoDemasiadoGrande
+	}
+
+	formato, err := validarCabeceraFoto(data)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/" + formato + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// FotosFormulario devuelve las fotos del campo de un formulario multipart, en el orden en que llegaron
+// Acepta tanto archivos como texto en base64; la solicitud ya debe tener el cuerpo limitado
+func FotosFormulario(r *http.Request, campo string) ([]string, error) {
+	if r.MultipartForm == nil {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+	}
+
+	var fotos []string
+	for _, archivo := range r.MultipartForm.File[campo] {
+		if archivo.Size > TamanoMaximoFoto {
+			return nil, ErrFotoDemasiadoGrande
+		}
+		f, err := archivo.Open()
+		if err != nil {
+			return nil, err
+		}
+		foto, err := LeerFotoBinaria(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", archivo.Filename, err)
+		}
+		fotos = append(fotos, foto)
+	}
+	for _, valor := range r.MultipartForm.Value[campo] {
+		if valor != "" {
+			fotos = append(fotos, valor)
+		}
+	}
+	return fotos, nil
+}
+
+// FotoFormulario devuelve la primera foto del campo de un formulario multipart, o "" si no hay
+func FotoFormulario(r *http.Request, campo string) (string, error) {
+	fotos, err := FotosFormulario(r, campo)
+	if err != nil || len(fotos) == 0 {
+		return "", err
+	}
+	return fotos[0], nil
+}
+
+// validarCabeceraFoto lee solo la cabecera de la imagen y verifica formato y dimensiones
+func validarCabeceraFoto(data []byte) (string, error) {
+	config, formato, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("no es una imagen válida: %v", err)
+	}
+
+	soportado := false
+	for _, f := range FormatosFoto {
+		soportado = soportado || f == formato
+	}
+	if !soportado {
+		return "", fmt.Errorf("formato no soportado: %s (solo %s)", formato, strings.ToUpper(strings.Join(FormatosFoto, ", ")))
+	}
+
+	if config.Width*config.Height > PixelesMaximosFoto {
+		return "", fmt.Errorf("%w: %dx%d píxeles", ErrFotoDemasiadoGrande, config.Width, config.Height)
+	}
+	return formato, nil
+}
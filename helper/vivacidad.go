@@ -0,0 +1,123 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+// Acciones que puede pedir un desafío de vivacidad
+// Las direcciones son las del estudiante: la cámara frontal no espeja la foto capturada,
+// así que girar a su izquierda desplaza el rostro hacia la derecha de la imagen
+const (
+	AccionGirarIzquierda = "girar_izquierda"
+	AccionGirarDerecha   = "girar_derecha"
+	AccionAcercarse      = "acercarse"
+	AccionAlejarse       = "alejarse"
+)
+
+// AccionesVivacidad son las acciones entre las que se sortea cada desafío
+var AccionesVivacidad = []string{AccionGirarIzquierda, AccionGirarDerecha, AccionAcercarse, AccionAlejarse}
+
+// Umbrales del movimiento exigido entre el primer fotograma y el de mayor movimiento
+const (
+	// DesplazamientoMinimoGiro es el corrimiento horizontal del rostro, relativo a su ancho inicial
+	DesplazamientoMinimoGiro = 0.12
+	// CambioMinimoEscala es el cambio de área del rostro al acercarse (o su inversa al alejarse)
+	CambioMinimoEscala = 1.25
+	// FotogramasMinimos y FotogramasMaximos incluyen el fotograma inicial
+	FotogramasMinimos = 2
+	FotogramasMaximos = 8
+)
+
+// ErrMovimientoNoDetectado indica que los fotogramas no muestran el movimiento pedido
+var ErrMovimientoNoDetectado = errors.New("no se detectó el movimiento solicitado")
+
+// InstruccionAccion devuelve el texto que se muestra al estudiante para cada acción
+func InstruccionAccion(accion string) string {
+	switch accion {
+	case AccionGirarIzquierda:
+		return "Gire la cabeza hacia su izquierda"
+	case AccionGirarDerecha:
+		return "Gire la cabeza hacia su derecha"
+	case AccionAcercarse:
+		return "Acerque el rostro a la cámara"
+	case AccionAlejarse:
+		return "Aleje el rostro de la cámara"
+	}
+	return ""
+}
+
+// VerificarMovimiento comprueba que la secuencia de fotogramas muestre la acción pedida
+// Solo compara la posición y el tamaño del recuadro del rostro: una foto movida frente a la cámara también pasa
+// El primer fotograma es la posición inicial; se compara con el que más se movió en la dirección pedida
+// Cada fotograma debe tener exactamente un rostro (ErrSinRostro / ErrVariosRostros)
+func VerificarMovimiento(accion string, fotogramas []string) error {
+	if InstruccionAccion(accion) == "" {
+		return fmt.Errorf("acción de vivacidad desconocida: %s", accion)
+	}
+	if len(fotogramas) < FotogramasMinimos {
+		return fmt.Errorf("%w: se requieren al menos %d fotogramas", ErrMovimientoNoDetectado, FotogramasMinimos)
+	}
+	if len(fotogramas) > FotogramasMaximos {
+		return fmt.Errorf("demasiados fotogramas: %d (máximo %d)", len(fotogramas), FotogramasMaximos)
+	}
+
+	rostros := make([]image.Rectangle, len(fotogramas))
+	for i, fotograma := range fotogramas {
+		// Formato y tamaño se controlan antes de decodificar el fotograma completo
+		if err := ValidarImagenBase64(fotograma); err != nil {
+			return fmt.Errorf("fotograma %d inválido: %w", i+1, err)
+		}
+		img, err := imagenTrabajoBase64(fotograma)
+		if err != nil {
+			return fmt.Errorf("fotograma %d inválido: %v", i+1, err)
+		}
+		detectados := DetectarRostros(img)
+		switch {
+		case len(detectados) == 0:
+			return fmt.Errorf("fotograma %d: %w", i+1, ErrSinRostro)
+		case len(detectados) > 1:
+			return fmt.Errorf("fotograma %d: %w", i+1, ErrVariosRostros)
+		}
+		rostros[i] = detectados[0]
+	}
+
+	inicial := rostros[0]
+	mejor := 0.0
+	for _, r := range rostros[1:] {
+		mejor = math.Max(mejor, movimiento(accion, inicial, r))
+	}
+
+	requerido := DesplazamientoMinimoGiro
+	if accion == AccionAcercarse || accion == AccionAlejarse {
+		requerido = CambioMinimoEscala
+	}
+	if mejor < requerido {
+		return fmt.Errorf("%w (%s: %.2f < %.2f requerido)", ErrMovimientoNoDetectado, accion, mejor, requerido)
+	}
+	return nil
+}
+
+// movimiento mide cuánto se movió el rostro desde la posición inicial en la dirección de la acción
+// Para los giros es el corrimiento del centro relativo al ancho inicial; para acercarse/alejarse, la razón de áreas
+func movimiento(accion string, inicial, actual image.Rectangle) float64 {
+	centroInicial := float64(inicial.Min.X+inicial.Max.X) / 2
+	centroActual := float64(actual.Min.X+actual.Max.X) / 2
+	desplazamiento := (centroActual - centroInicial) / float64(inicial.Dx())
+	areaInicial := float64(inicial.Dx() * inicial.Dy())
+	areaActual := float64(actual.Dx() * actual.Dy())
+
+	switch accion {
+	case AccionGirarIzquierda:
+		return desplazamiento
+	case AccionGirarDerecha:
+		return -desplazamiento
+	case AccionAcercarse:
+		return areaActual / areaInicial
+	case AccionAlejarse:
+		return areaInicial / areaActual
+	}
+	return 0
+}
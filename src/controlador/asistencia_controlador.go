@@ -2,11 +2,16 @@ package controlador
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/MetaDandy/Assistense-System/helper"
+	"github.com/MetaDandy/Assistense-System/src/controlador/sesion_estado"
 	"github.com/MetaDandy/Assistense-System/src/modelo"
+	"github.com/MetaDandy/Assistense-System/src/modelo/cadena_responsabilidad"
 	"github.com/MetaDandy/Assistense-System/src/vista"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -16,7 +21,21 @@ type AsistenciaControladorInterfaz interface {
 	MostrarConfirmarAsistencia(w http.ResponseWriter, r *http.Request)
 	MostrarCapturarFoto(w http.ResponseWriter, r *http.Request)
 	ProcesarRegistrarAsistencia(w http.ResponseWriter, r *http.Request)
+	IdentificarAsistencia(w http.ResponseWriter, r *http.Request)
+	CrearDesafioVivacidad(w http.ResponseWriter, r *http.Request)
 	MostrarListarAsistencias(w http.ResponseWriter, r *http.Request)
+	MarcarIntentoFotoRepetidaRevisado(w http.ResponseWriter, r *http.Request)
+	ListarSospechasSuplantacion(w http.ResponseWriter, r *http.Request)
+	MostrarRevisionAsistencias(w http.ResponseWriter, r *http.Request)
+	ProcesarRevisionAsistencias(w http.ResponseWriter, r *http.Request)
+	MarcarAusencias(w http.ResponseWriter, r *http.Request)
+	CrearAsistenciaManual(w http.ResponseWriter, r *http.Request)
+	ModificarAsistenciaManual(w http.ResponseWriter, r *http.Request)
+	EliminarAsistenciaManual(w http.ResponseWriter, r *http.Request)
+	ApelarIntento(w http.ResponseWriter, r *http.Request)
+	MostrarApelaciones(w http.ResponseWriter, r *http.Request)
+	RevisarApelacion(w http.ResponseWriter, r *http.Request)
+	ObtenerAsistencia(w http.ResponseWriter, r *http.Request)
 }
 
 type AsistenciaControlador struct {
@@ -123,14 +142,22 @@ func (c *AsistenciaControlador) ProcesarRegistrarAsistencia(w http.ResponseWrite
 		return
 	}
 
-	// Parsear JSON del request
-	var request struct {
-		FotoVerificacion string `json:"foto_verificacion"`
-		SesionID         string `json:"sesion_id"`
-		EstudianteID     string `json:"estudiante_id"`
-	}
+	// La foto de verificación y los fotogramas del desafío, como mucho
+	helper.LimitarCuerpoCapturas(w, r)
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+	// Parsear el request: JSON con fotos en base64 o multipart con archivos
+	request, err := leerSolicitudAsistencia(r)
+	if err != nil {
+		if helper.EsErrorTamano(err) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			json.NewEncoder(w).Encode(map[string]string{"error": errorTamanoCapturas()})
+			return
+		}
+		if errors.Is(err, errCuerpoImagen) {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Error al procesar datos"})
 		return
@@ -143,13 +170,6 @@ func (c *AsistenciaControlador) ProcesarRegistrarAsistencia(w http.ResponseWrite
 		return
 	}
 
-	// Validar formato de la imagen
-	if err := helper.ValidarImagenBase64(request.FotoVerificacion); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Formato de imagen inválido: " + err.Error()})
-		return
-	}
-
 	// Validar UUIDs
 	sesionUUID, err := uuid.Parse(request.SesionID)
 	if err != nil {
@@ -165,66 +185,273 @@ func (c *AsistenciaControlador) ProcesarRegistrarAsistencia(w http.ResponseWrite
 		return
 	}
 
-	// Obtener la foto de referencia del estudiante
-	estudiante, err := c.estudianteModelo.ObtenerEstudiantePorID(estudianteUUID)
+	// Solo se registra mientras la sesión recibe registros (patrón State): ni antes de abrirla, ni suspendida,
+	// ni cerrada. En la gracia después del fin, el registro queda pendiente del docente
+	sesion, err := c.sesionAsistenciaModelo.ObtenerSesionAsistencia(sesionUUID)
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Estudiante no encontrado"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sesión no encontrada"})
 		return
 	}
-
-	if estudiante.FotoReferencia == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "El estudiante no tiene foto de referencia registrada"})
-		return
+	ctx := &sesion_estado.Sesion{
+		Estado: sesion.Estado,
 	}
-
-	// Comparar rostros
-	esIgual, similitud, err := helper.CompararRostros(estudiante.FotoReferencia, request.FotoVerificacion)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Error al procesar reconocimiento facial: " + err.Error()})
+	if !ctx.CanRegistrarAsistencia() {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "La sesión no está recibiendo asistencias"})
 		return
 	}
 
-	// Verificar si es la misma persona (usando umbral más permisivo)
-	if !esIgual || similitud < 0.6 {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": fmt.Sprintf("El rostro no coincide con el registrado (similitud: %.1f%%)", similitud*100),
-		})
-		return
-	}
+	// Sin desafío queda uuid.Nil: el validador de vivacidad lo rechaza en modo obligatoria, lo deja pendiente
+	// de revisión del docente en modo opcional (el de por defecto) y no lo mira en modo desactivada
+	desafioUUID, _ := uuid.Parse(request.DesafioID)
+
+	// La validación de la imagen y la comparación de rostros las hace la cadena de validadores del modelo,
+	// usando las características precalculadas de la foto de referencia
 
 	// Crear DTO para registrar asistencia
 	dto := &modelo.RegistrarAsistenciaDto{
 		FotoVerificacion:   request.FotoVerificacion,
-		Similitud:          similitud,
+		Fotogramas:         request.Fotogramas,
+		DesafioID:          desafioUUID,
 		EstudianteID:       estudianteUUID,
 		SesionAsistenciaID: sesionUUID,
+		RequiereAprobacion: ctx.RequiereAprobacionDocente(),
 	}
 
 	// Registrar asistencia
 	asistencia, err := c.modelo.RegistrarAsistencia(dto)
 	if err != nil {
-		// Si es error de duplicado
-		if err.Error() == "UNIQUE constraint failed" || err.Error() == "duplicate key value" {
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Ya se registró asistencia para esta sesión"})
+		responderErrorRegistro(w, err)
+		return
+	}
+
+	// Respuesta exitosa; en la banda de revisión la asistencia queda pendiente del docente
+	mensaje := "Asistencia registrada exitosamente"
+	if asistencia.Estado == modelo.EstadoPendienteRevision {
+		mensaje = "Asistencia registrada, pendiente de revisión del docente"
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":       true,
+		"message":       mensaje,
+		"id":            asistencia.ID.String(),
+		"estado":        asistencia.Estado,
+		"condicion":     asistencia.Condicion,
+		"similitud":     asistencia.Similitud,
+		"referencia_id": asistencia.ReferenciaFacialID,
+	})
+}
+
+// POST /api/sesion-asistencia/{id}/identificar
+// Identifica al estudiante solo con la foto (1:N) y le registra la asistencia si la identificación es clara
+// Acepta los mismos formatos que /api/registrar-asistencia; el desafío debe ser de la sesión, sin estudiante
+func (c *AsistenciaControlador) IdentificarAsistencia(w http.ResponseWriter, r *http.Request) {
+	sesionUUID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de sesión inválido"})
+		return
+	}
+
+	sesion, err := c.sesionAsistenciaModelo.ObtenerSesionAsistencia(sesionUUID)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusNotFound, map[string]string{"error": "Sesión no encontrada"})
+		return
+	}
+	// La cámara de la puerta funciona sin supervisión: solo registra mientras la sesión recibe registros, y en
+	// la gracia después del fin el registro queda pendiente del docente
+	ctx := &sesion_estado.Sesion{
+		Estado: sesion.Estado,
+	}
+	if !ctx.CanRegistrarAsistencia() {
+		helper.EnviarJson(w, http.StatusForbidden, map[string]string{"error": "La sesión no está recibiendo asistencias"})
+		return
+	}
+
+	helper.LimitarCuerpoCapturas(w, r)
+	request, err := leerSolicitudAsistencia(r)
+	if err != nil {
+		if helper.EsErrorTamano(err) {
+			helper.EnviarJson(w, http.StatusRequestEntityTooLarge, map[string]string{"error": errorTamanoCapturas()})
+			return
+		}
+		if errors.Is(err, errCuerpoImagen) {
+			helper.EnviarJson(w, http.StatusUnsupportedMediaType, map[string]string{"error": err.Error()})
+			return
+		}
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error al procesar datos"})
+		return
+	}
+	if request.FotoVerificacion == "" {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Datos requeridos faltantes"})
+		return
+	}
+	desafioUUID, _ := uuid.Parse(request.DesafioID)
+
+	resultado, err := c.modelo.IdentificarYRegistrarAsistencia(&modelo.IdentificarAsistenciaDto{
+		FotoVerificacion:   request.FotoVerificacion,
+		Fotogramas:         request.Fotogramas,
+		DesafioID:          desafioUUID,
+		SesionAsistenciaID: sesionUUID,
+		RequiereAprobacion: ctx.RequiereAprobacionDocente(),
+	})
+	if resultado == nil {
+		if errors.Is(err, modelo.ErrSinCandidatos) {
+			helper.EnviarJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Error al registrar asistencia: " + err.Error()})
+		responderErrorRegistro(w, err)
 		return
 	}
 
-	// Respuesta exitosa
+	// El endpoint no requiere sesión iniciada: a quién se parece la foto solo se informa si se registró su asistencia
+	switch {
+	case errors.Is(err, modelo.ErrIdentificacionAmbigua), errors.Is(err, modelo.ErrIdentificacionSinMatch):
+		// No se registra nada: el cliente puede reintentar con otra foto y el mismo desafío
+		helper.EnviarJson(w, http.StatusUnprocessableEntity, map[string]string{"error": "No se pudo identificar al estudiante. Vuelva a intentarlo mirando a la cámara"})
+	case err != nil:
+		// La identificación fue clara pero la cadena de validadores rechazó la asistencia
+		responderErrorRegistro(w, err)
+	default:
+		respuesta := c.describirIdentificacion(resultado)
+		respuesta["success"] = true
+		respuesta["id"] = resultado.Asistencia.ID.String()
+		respuesta["estado"] = resultado.Asistencia.Estado
+		respuesta["condicion"] = resultado.Asistencia.Condicion
+		codigo := http.StatusOK
+		if resultado.Asistencia.Estado == modelo.EstadoPendienteRevision {
+			codigo = http.StatusAccepted
+		}
+		helper.EnviarJson(w, codigo, respuesta)
+	}
+}
+
+// describirIdentificacion arma la respuesta de IdentificarAsistencia cuando se registró la asistencia: el
+// estudiante identificado, para que lo vea en la pantalla de la puerta. No incluye el ranking ni las similitudes
+func (c *AsistenciaControlador) describirIdentificacion(resultado *modelo.ResultadoIdentificacion) map[string]interface{} {
+	respuesta := map[string]interface{}{}
+
+	estudiante := map[string]interface{}{"id": resultado.Mejor.EstudianteID}
+	if e, err := c.estudianteModelo.ObtenerEstudiantePorID(resultado.Mejor.EstudianteID); err == nil {
+		estudiante["nombre"] = e.Nombre
+		estudiante["apellidos"] = e.Apellidos
+		estudiante["registro"] = e.Registro
+	}
+	respuesta["estudiante"] = estudiante
+	return respuesta
+}
+
+// responderErrorRegistro responde con el código HTTP y el mensaje que corresponden al error de RegistrarAsistencia
+func responderErrorRegistro(w http.ResponseWriter, err error) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success":   true,
-		"message":   "Asistencia registrada exitosamente",
-		"id":        asistencia.ID.String(),
-		"similitud": similitud,
+	switch {
+	case errors.Is(err, cadena_responsabilidad.ErrAsistenciaDuplicada):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Ya se registró asistencia para esta sesión"})
+		return
+	case errors.Is(err, modelo.ErrDemasiadosIntentos):
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("Demasiados intentos rechazados seguidos. Espere %d minutos o pida al docente que registre su asistencia", int(modelo.VentanaIntentosRechazados.Minutes()))})
+		return
+	case errors.Is(err, modelo.ErrEstudianteNoInscrito):
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "El estudiante no está inscrito en esta sesión"})
+		return
+	case errors.Is(err, cadena_responsabilidad.ErrRostroNoCoincide):
+		// Sin similitud, umbral ni desglose (tampoco en el mensaje): el endpoint no requiere sesión iniciada y
+		// servirían para ajustar una foto ajena hasta pasar. El docente los ve al revisar el intento o la apelación
+		respuesta := map[string]interface{}{"error": "El rostro no coincide con las fotos de referencia del estudiante"}
+		var errSimilitud *cadena_responsabilidad.ErrorSimilitud
+		if errors.As(err, &errSimilitud) {
+			// El intento quedó guardado: con su ID y el código el estudiante puede apelar el rechazo
+			if errSimilitud.IntentoID != uuid.Nil {
+				respuesta["intento_id"] = errSimilitud.IntentoID
+				respuesta["token_apelacion"] = errSimilitud.TokenApelacion
+			}
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(respuesta)
+		return
+	case errors.Is(err, helper.ErrSinRostro):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{"error": "No se detectó ningún rostro en la foto. Ubíquese frente a la cámara con buena luz"})
+		return
+	case errors.Is(err, helper.ErrVariosRostros):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Se detectó más de un rostro en la foto. Solo el estudiante debe aparecer en la imagen"})
+		return
+	case errors.Is(err, cadena_responsabilidad.ErrDesafioInvalido):
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "El desafío de verificación venció o ya se usó. Vuelva a iniciar la captura"})
+		return
+	case errors.Is(err, helper.ErrMovimientoNoDetectado):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{"error": "No se detectó el movimiento solicitado. Vuelva a intentarlo siguiendo la instrucción en pantalla"})
+		return
+	case errors.Is(err, cadena_responsabilidad.ErrFotoRepetida):
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Esta foto ya se usó en una asistencia anterior. Tome una foto nueva; el intento quedó registrado para revisión del docente"})
+		return
+	case errors.Is(err, cadena_responsabilidad.ErrMetadatosFoto):
+		respuesta := map[string]interface{}{"error": "La foto no parece tomada en esta sesión. Tome una foto nueva con la cámara"}
+		var errMetadatos *cadena_responsabilidad.ErrorMetadatos
+		if errors.As(err, &errMetadatos) {
+			respuesta["motivos"] = errMetadatos.Motivos
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(respuesta)
+		return
+	case errors.Is(err, helper.ErrFotoDemasiadoGrande):
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, cadena_responsabilidad.ErrSinFotoReferencia):
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "El estudiante no tiene foto de referencia registrada"})
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]string{"error": "Error al registrar asistencia: " + err.Error()})
+}
+
+// POST /api/desafio-vivacidad
+// Emite el desafío que el estudiante debe cumplir antes de enviar sus fotogramas
+func (c *AsistenciaControlador) CrearDesafioVivacidad(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		SesionID     string `json:"sesion_id"`
+		EstudianteID string `json:"estudiante_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error al procesar datos"})
+		return
+	}
+
+	sesionUUID, err := uuid.Parse(request.SesionID)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de sesión inválido"})
+		return
+	}
+	// Sin estudiante el desafío es de la sesión, para la identificación 1:N
+	estudianteUUID := uuid.Nil
+	if request.EstudianteID != "" {
+		estudianteUUID, err = uuid.Parse(request.EstudianteID)
+		if err != nil {
+			helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de estudiante inválido"})
+			return
+		}
+	}
+
+	desafio, err := c.modelo.CrearDesafioVivacidad(estudianteUUID, sesionUUID)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]interface{}{
+		"desafio_id":  desafio.ID,
+		"accion":      desafio.Accion,
+		"instruccion": helper.InstruccionAccion(desafio.Accion),
+		"expira_en":   desafio.ExpiraEn,
 	})
 }
 
@@ -271,6 +498,13 @@ func (c *AsistenciaControlador) MostrarListarAsistencias(w http.ResponseWriter,
 		return
 	}
 
+	// Filtro opcional por condición (?condicion=tarde); los totales por condición son siempre de toda la sesión
+	filtro := r.URL.Query().Get("condicion")
+	if filtro != "" && !modelo.EsCondicionValida(filtro) {
+		http.Error(w, "Condición inválida", http.StatusBadRequest)
+		return
+	}
+
 	// Obtener asistencias reales de la base de datos
 	asistenciasReales, err := c.modelo.ObtenerAsistenciasPorSesion(id)
 	if err != nil {
@@ -278,6 +512,66 @@ func (c *AsistenciaControlador) MostrarListarAsistencias(w http.ResponseWriter,
 		return
 	}
 
+	// Sospechas de suplantación de la sesión, agrupadas por asistencia
+	// Cada sospecha se muestra en la asistencia sospechosa y, si aplica, en la otra asistencia involucrada
+	sospechas, err := c.modelo.ObtenerSospechasPorSesion(id)
+	if err != nil {
+		http.Error(w, "Error al obtener sospechas de suplantación: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sospechasPorAsistencia := make(map[uuid.UUID][]string)
+	for _, s := range sospechas {
+		parecido := s.EstudianteSimilar.Nombre + " " + s.EstudianteSimilar.Apellidos
+		if s.Origen == modelo.OrigenSospechaAsistencia {
+			sospechasPorAsistencia[s.AsistenciaID] = append(sospechasPorAsistencia[s.AsistenciaID],
+				fmt.Sprintf("Se parece más a la foto de asistencia de %s (%.1f%% vs %.1f%% propia)", parecido, s.Similitud*100, s.SimilitudPropia*100))
+			if s.AsistenciaRelacionadaID != nil {
+				otro := s.Asistencia.Estudiante.Nombre + " " + s.Asistencia.Estudiante.Apellidos
+				sospechasPorAsistencia[*s.AsistenciaRelacionadaID] = append(sospechasPorAsistencia[*s.AsistenciaRelacionadaID],
+					fmt.Sprintf("La foto de asistencia de %s se parece a esta (%.1f%%)", otro, s.Similitud*100))
+			}
+		} else {
+			sospechasPorAsistencia[s.AsistenciaID] = append(sospechasPorAsistencia[s.AsistenciaID],
+				fmt.Sprintf("Se parece más a las fotos de referencia de %s (%.1f%% vs %.1f%% propia)", parecido, s.Similitud*100, s.SimilitudPropia*100))
+		}
+	}
+
+	// Las horas se muestran en la zona horaria de la sesión
+	zona := sesion.Zona()
+
+	// Ajustes manuales del docente; en cada asistencia se muestra el último (vienen del más reciente al más antiguo)
+	ajustes, err := c.modelo.ObtenerAjustesPorSesion(id)
+	if err != nil {
+		http.Error(w, "Error al obtener ajustes manuales: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ultimoAjuste := make(map[uuid.UUID]string)
+	for _, a := range ajustes {
+		if _, ok := ultimoAjuste[a.AsistenciaID]; !ok {
+			ultimoAjuste[a.AsistenciaID] = describirAjuste(&a, zona)
+		}
+	}
+
+	// Apelaciones de intentos rechazados que esperan la decisión del docente
+	apelaciones, err := c.modelo.ObtenerApelacionesPorSesion(id)
+	if err != nil {
+		http.Error(w, "Error al obtener apelaciones: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	apelacionesPendientes := 0
+	for _, a := range apelaciones {
+		if a.Estado == modelo.ApelacionPendiente {
+			apelacionesPendientes++
+		}
+	}
+
+	// Estudiantes inscritos, para registrar una asistencia manual
+	estudiantes, err := c.sesionAsistenciaModelo.ObtenerEstudiantesInscritos(id)
+	if err != nil {
+		http.Error(w, "Error al obtener estudiantes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// Convertir a formato para la vista
 	asistencias := []struct {
 		ID               string
@@ -285,9 +579,32 @@ func (c *AsistenciaControlador) MostrarListarAsistencias(w http.ResponseWriter,
 		FechaHora        string
 		Similitud        float64
 		FotoVerificacion string
+		Estado           string
+		Condicion        string
+		Sospechas        []string
+		Desglose         string
+		Umbrales         string
+		Metadatos        []string
+		Ajuste           string
+		Apelada          bool
 	}{}
 
+	presentes, pendientesAsistencia := 0, 0
+	porCondicion := make(map[string]int)
 	for _, a := range asistenciasReales {
+		// Las asistencias manuales cuentan como presentes aunque no tengan verificación facial
+		switch {
+		case a.Estado == modelo.EstadoAceptada,
+			a.Estado == modelo.EstadoManual && (a.Condicion == modelo.CondicionPresente || a.Condicion == modelo.CondicionTarde):
+			presentes++
+		case a.Estado == modelo.EstadoPendienteRevision:
+			pendientesAsistencia++
+		}
+		porCondicion[a.Condicion]++
+		if filtro != "" && a.Condicion != filtro {
+			continue
+		}
+
 		estudianteNombre := "Estudiante Desconocido"
 		if a.Estudiante.Nombre != "" {
 			estudianteNombre = a.Estudiante.Nombre + " " + a.Estudiante.Apellidos
@@ -299,20 +616,822 @@ func (c *AsistenciaControlador) MostrarListarAsistencias(w http.ResponseWriter,
 			FechaHora        string
 			Similitud        float64
 			FotoVerificacion string
+			Estado           string
+			Condicion        string
+			Sospechas        []string
+			Desglose         string
+			Umbrales         string
+			Metadatos        []string
+			Ajuste           string
+			Apelada          bool
 		}{
 			ID:               a.ID.String(),
 			EstudianteNombre: estudianteNombre,
-			FechaHora:        a.FechaHora,
+			FechaHora:        a.FechaHora.In(zona).Format("2006-01-02 15:04:05"),
 			Similitud:        a.Similitud * 100, // Convertir a porcentaje
 			FotoVerificacion: a.FotoVerificacion,
+			Estado:           a.Estado,
+			Condicion:        a.Condicion,
+			Sospechas:        sospechasPorAsistencia[a.ID],
+			Desglose:         describirDesglose(a.Desglose()),
+			Umbrales:         describirUmbrales(&a),
+			Metadatos:        advertenciasMetadatos(&a),
+			Ajuste:           ultimoAjuste[a.ID],
+			Apelada:          a.ApelacionID != nil,
+		})
+	}
+
+	// Pestañas de filtro por condición, con sus totales
+	type FiltroCondicion struct {
+		Condicion string
+		Etiqueta  string
+		Cantidad  int
+		Activo    bool
+	}
+	filtros := []FiltroCondicion{{Etiqueta: "Todas", Cantidad: len(asistenciasReales), Activo: filtro == ""}}
+	for _, condicion := range modelo.Condiciones {
+		filtros = append(filtros, FiltroCondicion{
+			Condicion: condicion,
+			Etiqueta:  etiquetasCondiciones[condicion],
+			Cantidad:  porCondicion[condicion],
+			Activo:    filtro == condicion,
 		})
 	}
 
+	// Intentos rechazados por reutilizar una foto, para revisión del docente
+	intentosRepetidos, err := c.modelo.ObtenerIntentosFotoRepetida(id)
+	if err != nil {
+		http.Error(w, "Error al obtener intentos con foto repetida: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pendientesRevision := 0
+	for _, intento := range intentosRepetidos {
+		if !intento.Revisado {
+			pendientesRevision++
+		}
+	}
+
+	// Las ausencias se pueden marcar a mano una vez cerrada la sesión
+	terminada := sesion.Estado == modelo.EstadoSesionCerrada
+
 	data := map[string]interface{}{
-		"Sesion":           sesion,
-		"Asistencias":      asistencias,
-		"TotalAsistencias": len(asistencias),
+		"Sesion":                sesion,
+		"Zona":                  zona,
+		"Asistencias":           asistencias,
+		"TotalAsistencias":      len(asistenciasReales),
+		"Filtros":               filtros,
+		"Filtro":                filtro,
+		"IntentosRepetidos":     intentosRepetidos,
+		"PendientesRevision":    pendientesRevision,
+		"TotalSospechas":        len(sospechas),
+		"Presentes":             presentes,
+		"PendientesAsistencia":  pendientesAsistencia,
+		"Terminada":             terminada,
+		"ApelacionesPendientes": apelacionesPendientes,
+		"Ajustes":               ajustes,
+		"Estudiantes":           estudiantes,
+		"Condiciones":           condicionesManuales(),
 	}
 
 	c.vista.RenderizarListarAsistencias(w, data)
 }
+
+// POST /api/intentos-foto-repetida/{id}/revisado
+func (c *AsistenciaControlador) MarcarIntentoFotoRepetidaRevisado(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de intento inválido"})
+		return
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusUnauthorized, map[string]string{"error": "No autorizado"})
+		return
+	}
+
+	intento, err := c.modelo.ObtenerIntentoFotoRepetida(id)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusNotFound, map[string]string{"error": "Intento no encontrado"})
+		return
+	}
+	if intento.SesionAsistencia.DocenteID != docenteID {
+		helper.EnviarJson(w, http.StatusForbidden, map[string]string{"error": "No tiene acceso a esta sesión"})
+		return
+	}
+
+	if err := c.modelo.MarcarIntentoFotoRepetidaRevisado(id); err != nil {
+		helper.EnviarJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// GET /api/sesion-asistencia/{id}/sospechas
+func (c *AsistenciaControlador) ListarSospechasSuplantacion(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de sesión inválido"})
+		return
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusUnauthorized, map[string]string{"error": "No autorizado"})
+		return
+	}
+
+	sesion, err := c.sesionAsistenciaModelo.ObtenerSesionAsistencia(id)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusNotFound, map[string]string{"error": "Sesión no encontrada"})
+		return
+	}
+	if sesion.DocenteID != docenteID {
+		helper.EnviarJson(w, http.StatusForbidden, map[string]string{"error": "No tiene acceso a esta sesión"})
+		return
+	}
+
+	sospechas, err := c.modelo.ObtenerSospechasPorSesion(id)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	resultado := make([]map[string]interface{}, len(sospechas))
+	for i, s := range sospechas {
+		resultado[i] = map[string]interface{}{
+			"id":                        s.ID,
+			"asistencia_id":             s.AsistenciaID,
+			"estudiante_id":             s.Asistencia.EstudianteID,
+			"origen":                    s.Origen,
+			"estudiante_similar_id":     s.EstudianteSimilarID,
+			"estudiante_similar":        s.EstudianteSimilar.Nombre + " " + s.EstudianteSimilar.Apellidos,
+			"asistencia_relacionada_id": s.AsistenciaRelacionadaID,
+			"similitud":                 s.Similitud,
+			"similitud_propia":          s.SimilitudPropia,
+			"creado_en":                 s.CreadoEn,
+		}
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]interface{}{"sospechas": resultado})
+}
+
+// GET /sesion-asistencia/{id}/revision
+// Cola de revisión: asistencias en la banda intermedia de similitud, con la foto de verificación y la de referencia
+func (c *AsistenciaControlador) MostrarRevisionAsistencias(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	sesion, err := c.sesionAsistenciaModelo.ObtenerSesionAsistencia(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if sesion.DocenteID != docenteID {
+		http.Error(w, "No tiene acceso a esta sesión", http.StatusForbidden)
+		return
+	}
+
+	pendientes, err := c.modelo.ObtenerAsistenciasPendientes(id)
+	if err != nil {
+		http.Error(w, "Error al obtener asistencias pendientes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Convertir a formato para la vista, con la referencia que mejor coincidió
+	// (o la foto de registro si la asistencia es anterior a las referencias múltiples)
+	type pendienteVista struct {
+		ID               string
+		EstudianteNombre string
+		Registro         string
+		FechaHora        string
+		Similitud        float64
+		FotoVerificacion string
+		FotoReferencia   string
+	}
+	vistaPendientes := make([]pendienteVista, len(pendientes))
+	for i, a := range pendientes {
+		fotoReferencia := a.Estudiante.FotoReferencia
+		if a.ReferenciaFacial != nil {
+			fotoReferencia = a.ReferenciaFacial.Foto
+		}
+		vistaPendientes[i] = pendienteVista{
+			ID:               a.ID.String(),
+			EstudianteNombre: a.Estudiante.Nombre + " " + a.Estudiante.Apellidos,
+			Registro:         a.Estudiante.Registro,
+			FechaHora:        a.FechaHora.In(sesion.Zona()).Format("2006-01-02 15:04:05"),
+			Similitud:        a.Similitud * 100, // Convertir a porcentaje
+			FotoVerificacion: a.FotoVerificacion,
+			FotoReferencia:   fotoReferencia,
+		}
+	}
+
+	data := map[string]interface{}{
+		"Sesion":     sesion,
+		"Pendientes": vistaPendientes,
+	}
+
+	c.vista.RenderizarRevisionAsistencias(w, data)
+}
+
+// POST /api/sesion-asistencia/{id}/revision
+// Aprueba o rechaza en bloque asistencias pendientes; cada decisión queda registrada con el docente
+func (c *AsistenciaControlador) ProcesarRevisionAsistencias(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de sesión inválido"})
+		return
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusUnauthorized, map[string]string{"error": "No autorizado"})
+		return
+	}
+
+	sesion, err := c.sesionAsistenciaModelo.ObtenerSesionAsistencia(id)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusNotFound, map[string]string{"error": "Sesión no encontrada"})
+		return
+	}
+	if sesion.DocenteID != docenteID {
+		helper.EnviarJson(w, http.StatusForbidden, map[string]string{"error": "No tiene acceso a esta sesión"})
+		return
+	}
+
+	var dto modelo.RevisarAsistenciasDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error al procesar datos"})
+		return
+	}
+
+	revisadas, err := c.modelo.RevisarAsistencias(id, docenteID, &dto)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"revisadas": revisadas,
+	})
+}
+
+// POST /api/sesion-asistencia/{id}/marcar-ausencias
+// Marca ausentes a los estudiantes sin asistencia sin esperar al marcado automático; se puede repetir,
+// por ejemplo tras registrar estudiantes nuevos, y solo crea las ausencias que falten
+func (c *AsistenciaControlador) MarcarAusencias(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de sesión inválido"})
+		return
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusUnauthorized, map[string]string{"error": "No autorizado"})
+		return
+	}
+
+	sesion, err := c.sesionAsistenciaModelo.ObtenerSesionAsistencia(id)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusNotFound, map[string]string{"error": "Sesión no encontrada"})
+		return
+	}
+	if sesion.DocenteID != docenteID {
+		helper.EnviarJson(w, http.StatusForbidden, map[string]string{"error": "No tiene acceso a esta sesión"})
+		return
+	}
+
+	marcadas, err := c.modelo.MarcarAusencias(id)
+	if errors.Is(err, modelo.ErrSesionNoFinalizada) {
+		helper.EnviarJson(w, http.StatusConflict, map[string]string{"error": "La sesión todavía no terminó"})
+		return
+	}
+	if errors.Is(err, modelo.ErrSesionCancelada) {
+		helper.EnviarJson(w, http.StatusConflict, map[string]string{"error": "La sesión fue cancelada"})
+		return
+	}
+	if err != nil {
+		helper.EnviarJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"marcadas": marcadas,
+	})
+}
+
+// POST /api/sesion-asistencia/{id}/asistencias-manuales
+// Registra sin foto la asistencia de un estudiante cuando la verificación facial no es posible
+func (c *AsistenciaControlador) CrearAsistenciaManual(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de sesión inválido"})
+		return
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusUnauthorized, map[string]string{"error": "No autorizado"})
+		return
+	}
+
+	sesion, err := c.sesionAsistenciaModelo.ObtenerSesionAsistencia(id)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusNotFound, map[string]string{"error": "Sesión no encontrada"})
+		return
+	}
+	if sesion.DocenteID != docenteID {
+		helper.EnviarJson(w, http.StatusForbidden, map[string]string{"error": "No tiene acceso a esta sesión"})
+		return
+	}
+
+	var dto modelo.AjusteAsistenciaDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error al procesar datos"})
+		return
+	}
+
+	asistencia, err := c.modelo.CrearAsistenciaManual(id, docenteID, &dto)
+	if err != nil {
+		responderErrorAjuste(w, err)
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusCreated, map[string]interface{}{
+		"success":       true,
+		"asistencia_id": asistencia.ID,
+		"estado":        asistencia.Estado,
+		"condicion":     asistencia.Condicion,
+	})
+}
+
+// PUT /api/asistencia/{id}
+// Cambia la condición de una asistencia; queda como manual con el motivo y el docente que la cambió
+func (c *AsistenciaControlador) ModificarAsistenciaManual(w http.ResponseWriter, r *http.Request) {
+	id, docenteID, ok := c.asistenciaDelDocente(w, r)
+	if !ok {
+		return
+	}
+
+	var dto modelo.AjusteAsistenciaDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error al procesar datos"})
+		return
+	}
+
+	asistencia, err := c.modelo.ModificarAsistenciaManual(id, docenteID, &dto)
+	if err != nil {
+		responderErrorAjuste(w, err)
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]interface{}{
+		"success":       true,
+		"asistencia_id": asistencia.ID,
+		"estado":        asistencia.Estado,
+		"condicion":     asistencia.Condicion,
+	})
+}
+
+// DELETE /api/asistencia/{id}
+// Elimina una asistencia sin foto; el motivo va en el cuerpo y el ajuste queda registrado
+func (c *AsistenciaControlador) EliminarAsistenciaManual(w http.ResponseWriter, r *http.Request) {
+	id, docenteID, ok := c.asistenciaDelDocente(w, r)
+	if !ok {
+		return
+	}
+
+	var dto modelo.AjusteAsistenciaDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error al procesar datos"})
+		return
+	}
+
+	if err := c.modelo.EliminarAsistenciaManual(id, docenteID, &dto); err != nil {
+		responderErrorAjuste(w, err)
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// POST /api/intentos-rechazados/{id}/apelacion
+// El estudiante apela un intento rechazado por rostro no coincidente; el ID y el código de apelación le llegan
+// en la respuesta del rechazo, y sin ellos (o con otro estudiante) la apelación no se acepta
+func (c *AsistenciaControlador) ApelarIntento(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de intento inválido"})
+		return
+	}
+
+	var dto modelo.ApelarIntentoDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error al procesar datos"})
+		return
+	}
+
+	apelacion, err := c.modelo.ApelarIntento(id, &dto)
+	if errors.Is(err, modelo.ErrApelacionExistente) {
+		helper.EnviarJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, modelo.ErrApelacionNoAutorizada) {
+		helper.EnviarJson(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusCreated, map[string]interface{}{
+		"success":      true,
+		"apelacion_id": apelacion.ID,
+		"estado":       apelacion.Estado,
+	})
+}
+
+// GET /sesion-asistencia/{id}/apelaciones
+// Apelaciones de la sesión con la foto del intento y la referencia lado a lado
+func (c *AsistenciaControlador) MostrarApelaciones(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	sesion, err := c.sesionAsistenciaModelo.ObtenerSesionAsistencia(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if sesion.DocenteID != docenteID {
+		http.Error(w, "No tiene acceso a esta sesión", http.StatusForbidden)
+		return
+	}
+
+	apelaciones, err := c.modelo.ObtenerApelacionesPorSesion(id)
+	if err != nil {
+		http.Error(w, "Error al obtener apelaciones: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type apelacionVista struct {
+		ID               string
+		Estado           string
+		Motivo           string
+		Comentario       string
+		EstudianteNombre string
+		Registro         string
+		FechaHora        string
+		Similitud        float64
+		Umbral           float64
+		Desglose         string
+		FotoVerificacion string
+		FotoReferencia   string
+	}
+	vistaApelaciones := make([]apelacionVista, len(apelaciones))
+	for i, a := range apelaciones {
+		intento := a.IntentoRechazado
+		fotoReferencia := intento.Estudiante.FotoReferencia
+		if intento.ReferenciaFacial != nil {
+			fotoReferencia = intento.ReferenciaFacial.Foto
+		}
+		vistaApelaciones[i] = apelacionVista{
+			ID:               a.ID.String(),
+			Estado:           a.Estado,
+			Motivo:           a.Motivo,
+			Comentario:       a.Comentario,
+			EstudianteNombre: intento.Estudiante.Nombre + " " + intento.Estudiante.Apellidos,
+			Registro:         intento.Estudiante.Registro,
+			FechaHora:        intento.FechaHora.In(sesion.Zona()).Format("2006-01-02 15:04:05"),
+			Similitud:        intento.Similitud * 100, // Convertir a porcentaje
+			Umbral:           intento.UmbralRevision * 100,
+			Desglose:         describirDesglose(intento.Desglose()),
+			FotoVerificacion: intento.FotoVerificacion,
+			FotoReferencia:   fotoReferencia,
+		}
+	}
+
+	c.vista.RenderizarApelaciones(w, map[string]interface{}{
+		"Sesion":      sesion,
+		"Apelaciones": vistaApelaciones,
+	})
+}
+
+// POST /api/sesion-asistencia/{id}/apelaciones/{apelacion_id}/revision
+// Concede o deniega una apelación; al concederla se crea la asistencia del intento
+func (c *AsistenciaControlador) RevisarApelacion(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de sesión inválido"})
+		return
+	}
+	apelacionID, err := uuid.Parse(mux.Vars(r)["apelacion_id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de apelación inválido"})
+		return
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusUnauthorized, map[string]string{"error": "No autorizado"})
+		return
+	}
+
+	sesion, err := c.sesionAsistenciaModelo.ObtenerSesionAsistencia(id)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusNotFound, map[string]string{"error": "Sesión no encontrada"})
+		return
+	}
+	if sesion.DocenteID != docenteID {
+		helper.EnviarJson(w, http.StatusForbidden, map[string]string{"error": "No tiene acceso a esta sesión"})
+		return
+	}
+
+	var dto modelo.RevisarApelacionDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error al procesar datos"})
+		return
+	}
+
+	apelacion, err := c.modelo.RevisarApelacion(apelacionID, id, docenteID, &dto)
+	if errors.Is(err, modelo.ErrAsistenciaExistente) {
+		helper.EnviarJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"estado":  apelacion.Estado,
+	})
+}
+
+// asistenciaDelDocente lee el ID de la asistencia de la ruta y verifica que sea de una sesión del docente
+// autenticado; si no, ya respondió el error
+func (c *AsistenciaControlador) asistenciaDelDocente(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de asistencia inválido"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusUnauthorized, map[string]string{"error": "No autorizado"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	asistencia, err := c.modelo.ObtenerAsistencia(id)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusNotFound, map[string]string{"error": "Asistencia no encontrada"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	if asistencia.SesionAsistencia.DocenteID != docenteID {
+		helper.EnviarJson(w, http.StatusForbidden, map[string]string{"error": "No tiene acceso a esta asistencia"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return id, docenteID, true
+}
+
+// responderErrorAjuste traduce los errores de un ajuste manual a la respuesta HTTP
+func responderErrorAjuste(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, modelo.ErrAsistenciaExistente), errors.Is(err, modelo.ErrAsistenciaConFoto):
+		helper.EnviarJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, modelo.ErrEstudianteNoInscrito):
+		helper.EnviarJson(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	default:
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+}
+
+// obtenerDocenteID lee el docente autenticado desde la cookie con el JWT
+func obtenerDocenteID(r *http.Request) (uuid.UUID, error) {
+	cookie, err := r.Cookie("token")
+	if err != nil {
+		return uuid.Nil, err
+	}
+	claims, err := helper.ValidateJwt(cookie.Value)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	docenteIDStr, ok := claims["id"].(string)
+	if !ok {
+		return uuid.Nil, errors.New("token sin id de docente")
+	}
+	return uuid.Parse(docenteIDStr)
+}
+
+// GET /api/asistencia/{id}
+// Detalle de una asistencia con la explicación de su similitud: componentes, umbrales vigentes y algoritmo
+func (c *AsistenciaControlador) ObtenerAsistencia(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de asistencia inválido"})
+		return
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusUnauthorized, map[string]string{"error": "No autorizado"})
+		return
+	}
+
+	asistencia, err := c.modelo.ObtenerAsistencia(id)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusNotFound, map[string]string{"error": "Asistencia no encontrada"})
+		return
+	}
+	if asistencia.SesionAsistencia.DocenteID != docenteID {
+		helper.EnviarJson(w, http.StatusForbidden, map[string]string{"error": "No tiene acceso a esta asistencia"})
+		return
+	}
+
+	// Historial de cambios manuales de esta asistencia
+	ajustes, err := c.modelo.ObtenerAjustesPorSesion(asistencia.SesionAsistenciaID)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	ajustesAsistencia := []map[string]interface{}{}
+	for _, a := range ajustes {
+		if a.AsistenciaID != asistencia.ID {
+			continue
+		}
+		ajustesAsistencia = append(ajustesAsistencia, map[string]interface{}{
+			"accion":             a.Accion,
+			"condicion_anterior": a.CondicionAnterior,
+			"condicion_nueva":    a.CondicionNueva,
+			"motivo":             a.Motivo,
+			"docente_id":         a.DocenteID,
+			"docente":            a.Docente.Nombre + " " + a.Docente.Apellidos,
+			"fecha_hora":         a.FechaHora,
+		})
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]interface{}{
+		"id":                   asistencia.ID,
+		"sesion_asistencia_id": asistencia.SesionAsistenciaID,
+		"estudiante": map[string]interface{}{
+			"id":        asistencia.Estudiante.ID,
+			"nombre":    asistencia.Estudiante.Nombre,
+			"apellidos": asistencia.Estudiante.Apellidos,
+			"registro":  asistencia.Estudiante.Registro,
+		},
+		"fecha_hora":             asistencia.FechaHora,
+		"estado":                 asistencia.Estado,
+		"condicion":              asistencia.Condicion,
+		"similitud":              asistencia.Similitud,
+		"umbral_aceptacion":      asistencia.UmbralAceptacion,
+		"umbral_revision":        asistencia.UmbralRevision,
+		"desglose":               asistencia.Desglose(),
+		"advertencias_metadatos": advertenciasMetadatos(asistencia),
+		"algoritmo":              asistencia.Algoritmo,
+		"version":                asistencia.Version,
+		"referencia_facial_id":   asistencia.ReferenciaFacialID,
+		"manual":                 asistencia.Estado == modelo.EstadoManual,
+		"apelacion_id":           asistencia.ApelacionID,
+		"ajustes":                ajustesAsistencia,
+	})
+}
+
+// advertenciasMetadatos separa los motivos por los que los metadatos de la foto no parecían de la sesión
+func advertenciasMetadatos(a *modelo.Asistencia) []string {
+	if a.AdvertenciasMetadatos == "" {
+		return nil
+	}
+	return strings.Split(a.AdvertenciasMetadatos, "\n")
+}
+
+// solicitudRegistrarAsistencia son los datos de POST /api/registrar-asistencia
+type solicitudRegistrarAsistencia struct {
+	FotoVerificacion string   `json:"foto_verificacion"`
+	Fotogramas       []string `json:"fotogramas"`
+	DesafioID        string   `json:"desafio_id"`
+	SesionID         string   `json:"sesion_id"`
+	EstudianteID     string   `json:"estudiante_id"`
+}
+
+// errCuerpoImagen rechaza una foto enviada como cuerpo (image/*): ahí no caben los fotogramas del desafío de
+// vivacidad, sin los que el registro fallaría siempre
+var errCuerpoImagen = errors.New("envíe la foto junto con los fotogramas del desafío, en JSON o multipart/form-data")
+
+// errorTamanoCapturas es el mensaje para una asistencia con fotos más pesadas que las de la cámara
+func errorTamanoCapturas() string {
+	return fmt.Sprintf("Cada foto de la cámara puede ocupar hasta %d MB", helper.TamanoMaximoCaptura>>20)
+}
+
+// leerSolicitudAsistencia acepta dos formas de enviar la asistencia:
+//   - JSON con las fotos en base64 (la del navegador)
+//   - multipart/form-data con los mismos campos; foto_verificacion y fotogramas pueden ser archivos
+func leerSolicitudAsistencia(r *http.Request) (*solicitudRegistrarAsistencia, error) {
+	var request solicitudRegistrarAsistencia
+
+	switch tipo := helper.TipoContenido(r); {
+	case tipo == "multipart/form-data":
+		foto, err := helper.FotoFormulario(r, "foto_verificacion")
+		if err != nil {
+			return nil, err
+		}
+		fotogramas, err := helper.FotosFormulario(r, "fotogramas")
+		if err != nil {
+			return nil, err
+		}
+		request = solicitudRegistrarAsistencia{
+			FotoVerificacion: foto,
+			Fotogramas:       fotogramas,
+			DesafioID:        r.FormValue("desafio_id"),
+			SesionID:         r.FormValue("sesion_id"),
+			EstudianteID:     r.FormValue("estudiante_id"),
+		}
+	case helper.EsCuerpoImagen(r):
+		return nil, errCuerpoImagen
+	default:
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			return nil, err
+		}
+	}
+	return &request, nil
+}
+
+// etiquetasCondiciones son los nombres legibles de las condiciones de asistencia
+var etiquetasCondiciones = map[string]string{
+	modelo.CondicionPresente:          "Presente",
+	modelo.CondicionTarde:             "Tarde",
+	modelo.CondicionAusente:           "Ausente",
+	modelo.CondicionJustificado:       "Justificado",
+	modelo.CondicionPendienteRevision: "Pendiente de revisión",
+}
+
+// describirAjuste resume un ajuste manual para la lista de asistencias: quién, cuándo (en la zona de la sesión) y por qué
+func describirAjuste(a *modelo.AjusteAsistencia, zona *time.Location) string {
+	return fmt.Sprintf("%s %s, %s: %s", a.Docente.Nombre, a.Docente.Apellidos, a.FechaHora.In(zona).Format("2006-01-02 15:04"), a.Motivo)
+}
+
+// condicionesManuales son las condiciones que el docente puede asignar a mano, con su etiqueta
+func condicionesManuales() []map[string]string {
+	var condiciones []map[string]string
+	for _, c := range modelo.Condiciones {
+		if c != modelo.CondicionPendienteRevision {
+			condiciones = append(condiciones, map[string]string{"Valor": c, "Etiqueta": etiquetasCondiciones[c]})
+		}
+	}
+	return condiciones
+}
+
+// etiquetasComponentes son los nombres legibles de los componentes de similitud conocidos
+var etiquetasComponentes = map[string]string{
+	"color":       "Color",
+	"brillo":      "Brillo",
+	"dimensiones": "Dimensiones",
+}
+
+// describirDesglose arma el texto de la lista con los componentes que pesan en la similitud total
+func describirDesglose(componentes []helper.ComponenteSimilitud) string {
+	var partes []string
+	for _, comp := range componentes {
+		if comp.Peso == 0 {
+			continue
+		}
+		etiqueta, ok := etiquetasComponentes[comp.Nombre]
+		if !ok {
+			etiqueta = comp.Nombre
+		}
+		partes = append(partes, fmt.Sprintf("%s %.1f%% (peso %.0f%%)", etiqueta, comp.Valor*100, comp.Peso*100))
+	}
+	return strings.Join(partes, " · ")
+}
+
+// describirUmbrales muestra los umbrales y el algoritmo con que se decidió la asistencia
+func describirUmbrales(a *modelo.Asistencia) string {
+	if a.UmbralAceptacion == nil || a.UmbralRevision == nil {
+		return ""
+	}
+	return fmt.Sprintf("Umbrales: aceptación %.0f%%, revisión %.0f%% · %s v%s",
+		*a.UmbralAceptacion*100, *a.UmbralRevision*100, a.Algoritmo, a.Version)
+}
@@ -1,15 +1,18 @@
 package controlador
 
 import (
+	"encoding/json"
 	"net/http"
 
+	"github.com/MetaDandy/Assistense-System/helper"
 	"github.com/MetaDandy/Assistense-System/src/modelo"
 	"github.com/MetaDandy/Assistense-System/src/vista"
 )
 
 type DocenteControlador struct {
-	modelos   modelo.DocenteModeloInterfaz
-	vistaHTML *vista.DocenteVistaHTML
+	modelos         modelo.DocenteModeloInterfaz
+	justificaciones modelo.JustificacionModeloInterfaz
+	vistaHTML       *vista.DocenteVistaHTML
 }
 
 type DocenteControladorInterfaz interface {
@@ -19,12 +22,14 @@ type DocenteControladorInterfaz interface {
 	MostrarLogin(w http.ResponseWriter, r *http.Request)
 	ProcesarLogin(w http.ResponseWriter, r *http.Request)
 	MostrarPanelDocente(w http.ResponseWriter, r *http.Request)
+	ActualizarZonaHoraria(w http.ResponseWriter, r *http.Request)
 }
 
-func NuevoDocenteControlador(modelos modelo.DocenteModeloInterfaz, vista *vista.DocenteVistaHTML) DocenteControladorInterfaz {
+func NuevoDocenteControlador(modelos modelo.DocenteModeloInterfaz, justificaciones modelo.JustificacionModeloInterfaz, vista *vista.DocenteVistaHTML) DocenteControladorInterfaz {
 	return &DocenteControlador{
-		modelos:   modelos,
-		vistaHTML: vista,
+		modelos:         modelos,
+		justificaciones: justificaciones,
+		vistaHTML:       vista,
 	}
 }
 
@@ -49,19 +54,21 @@ func (dc *DocenteControlador) ProcesarRegistro(w http.ResponseWriter, r *http.Re
 	}
 
 	registro := modelo.RegistrarDocenteDto{
-		Correo:     r.FormValue("correo"),
-		Nombre:     r.FormValue("nombre"),
-		Apellidos:  r.FormValue("apellidos"),
-		Contraseña: r.FormValue("contraseña"),
+		Correo:      r.FormValue("correo"),
+		Nombre:      r.FormValue("nombre"),
+		Apellidos:   r.FormValue("apellidos"),
+		Contraseña:  r.FormValue("contraseña"),
+		ZonaHoraria: r.FormValue("zona_horaria"),
 	}
 
 	confirmarContraseña := r.FormValue("confirmar_contraseña")
 	if registro.Contraseña != confirmarContraseña {
 		data := map[string]interface{}{
-			"Error":     "Las contraseñas no coinciden",
-			"Correo":    registro.Correo,
-			"Nombre":    registro.Nombre,
-			"Apellidos": registro.Apellidos,
+			"Error":       "Las contraseñas no coinciden",
+			"Correo":      registro.Correo,
+			"Nombre":      registro.Nombre,
+			"Apellidos":   registro.Apellidos,
+			"ZonaHoraria": registro.ZonaHoraria,
 		}
 		dc.vistaHTML.RenderizarRegistro(w, data)
 		return
@@ -129,5 +136,46 @@ func (dc *DocenteControlador) ProcesarLogin(w http.ResponseWriter, r *http.Reque
 }
 
 func (dc *DocenteControlador) MostrarPanelDocente(w http.ResponseWriter, r *http.Request) {
-	dc.vistaHTML.RenderizarPanelDocente(w, nil)
+	// Justificaciones de ausencias que esperan la revisión del docente
+	// y la zona horaria en que programa sus sesiones (vacía: la de la institución)
+	var pendientes int64
+	var zonaHoraria string
+	if docenteID, err := obtenerDocenteID(r); err == nil {
+		pendientes, _ = dc.justificaciones.ContarJustificacionesPendientes(docenteID)
+		if docente, err := dc.modelos.ObtenerDocentePorID(docenteID); err == nil {
+			zonaHoraria = docente.ZonaHoraria
+		}
+	}
+
+	dc.vistaHTML.RenderizarPanelDocente(w, map[string]interface{}{
+		"JustificacionesPendientes": pendientes,
+		"ZonaHoraria":               zonaHoraria,
+	})
+}
+
+// ActualizarZonaHoraria cambia la zona horaria en que el docente programa sus sesiones (JSON)
+// Enviar una zona vacía vuelve a la de la institución
+func (dc *DocenteControlador) ActualizarZonaHoraria(w http.ResponseWriter, r *http.Request) {
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusUnauthorized, map[string]string{"error": "No autorizado"})
+		return
+	}
+
+	var dto modelo.ActualizarZonaHorariaDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error al procesar datos"})
+		return
+	}
+
+	docente, err := dc.modelos.ActualizarZonaHoraria(docenteID, &dto)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"zona_horaria": docente.ZonaHoraria,
+	})
 }
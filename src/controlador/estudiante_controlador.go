@@ -2,9 +2,12 @@ package controlador
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/MetaDandy/Assistense-System/helper"
 	"github.com/MetaDandy/Assistense-System/src/modelo"
 	"github.com/MetaDandy/Assistense-System/src/vista"
 	"github.com/google/uuid"
@@ -21,6 +24,11 @@ type EstudianteControladorInterfaz interface {
 	ProcesarRegistrarEstudiante(w http.ResponseWriter, r *http.Request)
 	MostrarEditarEstudiante(w http.ResponseWriter, r *http.Request)
 	ProcesarEditarEstudiante(w http.ResponseWriter, r *http.Request)
+	MostrarReferenciasFaciales(w http.ResponseWriter, r *http.Request)
+	ListarReferenciasFaciales(w http.ResponseWriter, r *http.Request)
+	AgregarReferenciaFacial(w http.ResponseWriter, r *http.Request)
+	EliminarReferenciaFacial(w http.ResponseWriter, r *http.Request)
+	RevertirActualizacionReferencia(w http.ResponseWriter, r *http.Request)
 }
 
 func NuevoEstudianteControlador(modelos modelo.EstudianteModeloInterfaz, vistas *vista.EstudianteVistaHTML) EstudianteControladorInterfaz {
@@ -48,30 +56,49 @@ func (ec *EstudianteControlador) ProcesarRegistrarEstudiante(w http.ResponseWrit
 	}
 
 	var estudiante modelo.RegistrarEstudianteDto
+	helper.LimitarCuerpoFotos(w, r, 1)
 
-	// Verificar si es JSON o form data
-	contentType := r.Header.Get("Content-Type")
-	if strings.Contains(contentType, "application/json") {
+	// Verificar si es JSON, una imagen como cuerpo (datos en la query) o form data (urlencoded o multipart)
+	contentType := tipoRespuesta(r)
+	switch {
+	case helper.TipoContenido(r) == "application/json":
 		// Manejo de JSON
 		if err := json.NewDecoder(r.Body).Decode(&estudiante); err != nil {
-			http.Error(w, "Error decodificando JSON", http.StatusBadRequest)
+			responderErrorLectura(w, err, "Error decodificando JSON")
 			return
 		}
-	} else {
+	case helper.EsCuerpoImagen(r):
+		foto, err := helper.LeerFotoBinaria(r.Body)
+		if err != nil {
+			responderErrorLectura(w, err, "Error leyendo la foto: "+err.Error())
+			return
+		}
+		query := r.URL.Query()
+		estudiante = modelo.RegistrarEstudianteDto{
+			Nombre:         query.Get("nombre"),
+			Apellidos:      query.Get("apellidos"),
+			Registro:       query.Get("registro"),
+			FotoReferencia: foto,
+		}
+	default:
 		// Manejo de form data
-		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Error procesando formulario", http.StatusBadRequest)
+		foto, err := fotoReferenciaFormulario(r)
+		if err != nil {
+			responderErrorLectura(w, err, "Error procesando formulario")
 			return
 		}
 		estudiante = modelo.RegistrarEstudianteDto{
 			Nombre:         r.FormValue("nombre"),
 			Apellidos:      r.FormValue("apellidos"),
 			Registro:       r.FormValue("registro"),
-			FotoReferencia: r.FormValue("foto_referencia"),
+			FotoReferencia: foto,
 		}
 	}
 
 	if _, err := ec.modelos.RegistrarEstudiante(&estudiante); err != nil {
+		if responderErrorCalidad(w, contentType, err) {
+			return
+		}
 		if strings.Contains(contentType, "application/json") {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		} else {
@@ -106,11 +133,13 @@ func (ec *EstudianteControlador) ProcesarEditarEstudiante(w http.ResponseWriter,
 	}
 
 	id := mux.Vars(r)["id"]
-	contentType := r.Header.Get("Content-Type")
+	helper.LimitarCuerpoFotos(w, r, 1)
+	contentType := tipoRespuesta(r)
 
 	var actualizar modelo.ActualizarEstudiante
 
-	if strings.Contains(contentType, "application/json") {
+	switch {
+	case helper.TipoContenido(r) == "application/json":
 		// Manejo de JSON
 		var data struct {
 			Nombre         string `json:"nombre"`
@@ -120,7 +149,7 @@ func (ec *EstudianteControlador) ProcesarEditarEstudiante(w http.ResponseWriter,
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			http.Error(w, "Error decodificando JSON", http.StatusBadRequest)
+			responderErrorLectura(w, err, "Error decodificando JSON")
 			return
 		}
 
@@ -130,17 +159,25 @@ func (ec *EstudianteControlador) ProcesarEditarEstudiante(w http.ResponseWriter,
 			Registro:       &data.Registro,
 			FotoReferencia: &data.FotoReferencia,
 		}
-	} else {
+	case helper.EsCuerpoImagen(r):
+		// Solo se reemplaza la foto de referencia
+		foto, err := helper.LeerFotoBinaria(r.Body)
+		if err != nil {
+			responderErrorLectura(w, err, "Error leyendo la foto: "+err.Error())
+			return
+		}
+		actualizar = modelo.ActualizarEstudiante{FotoReferencia: &foto}
+	default:
 		// Manejo de form data
-		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Error procesando formulario", http.StatusBadRequest)
+		fotoReferencia, err := fotoReferenciaFormulario(r)
+		if err != nil {
+			responderErrorLectura(w, err, "Error procesando formulario")
 			return
 		}
 
 		nombre := r.FormValue("nombre")
 		apellidos := r.FormValue("apellidos")
 		registro := r.FormValue("registro")
-		fotoReferencia := r.FormValue("foto_referencia")
 
 		actualizar = modelo.ActualizarEstudiante{
 			Nombre:         &nombre,
@@ -151,6 +188,9 @@ func (ec *EstudianteControlador) ProcesarEditarEstudiante(w http.ResponseWriter,
 	}
 
 	if _, err := ec.modelos.ActualizarEstudiante(uuid.MustParse(id), &actualizar); err != nil {
+		if responderErrorCalidad(w, contentType, err) {
+			return
+		}
 		if strings.Contains(contentType, "application/json") {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		} else {
@@ -167,3 +207,204 @@ func (ec *EstudianteControlador) ProcesarEditarEstudiante(w http.ResponseWriter,
 		http.Redirect(w, r, "/gestionar-estudiantes", http.StatusSeeOther)
 	}
 }
+
+// tipoRespuesta decide cómo responder: a quien envía JSON o una imagen como cuerpo (clientes de la API)
+// se le responde en JSON; a los formularios, con redirecciones
+func tipoRespuesta(r *http.Request) string {
+	if helper.EsCuerpoImagen(r) {
+		return "application/json"
+	}
+	return r.Header.Get("Content-Type")
+}
+
+// fotoReferenciaFormulario lee la foto de referencia de un formulario: en multipart puede ser un archivo
+// o el texto base64 que arma la página; en urlencoded es siempre base64
+func fotoReferenciaFormulario(r *http.Request) (string, error) {
+	if helper.TipoContenido(r) == "multipart/form-data" {
+		return helper.FotoFormulario(r, "foto_referencia")
+	}
+	if err := r.ParseForm(); err != nil {
+		return "", err
+	}
+	return r.FormValue("foto_referencia"), nil
+}
+
+// responderErrorLectura responde 413 si el cuerpo o la foto superan el tamaño máximo y 400 en otro caso
+func responderErrorLectura(w http.ResponseWriter, err error, mensaje string) {
+	if helper.EsErrorTamano(err) {
+		http.Error(w, fmt.Sprintf("La foto no puede superar %d MB", helper.TamanoMaximoFoto>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, mensaje, http.StatusBadRequest)
+}
+
+// responderErrorCalidad responde con la lista de controles fallidos si err es un rechazo por calidad de la foto
+// Devuelve false si err es de otro tipo y el llamador debe responder por su cuenta
+func responderErrorCalidad(w http.ResponseWriter, contentType string, err error) bool {
+	var errCalidad *helper.ErrorCalidadFoto
+	if !errors.As(err, &errCalidad) {
+		return false
+	}
+
+	if strings.Contains(contentType, "application/json") {
+		helper.EnviarJson(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":  "La foto de referencia no cumple los requisitos de calidad",
+			"fallas": errCalidad.Fallas,
+		})
+		return true
+	}
+
+	mensaje := "La foto de referencia no cumple los requisitos de calidad:"
+	for _, f := range errCalidad.Fallas {
+		mensaje += "\n- " + f.Motivo
+	}
+	http.Error(w, mensaje, http.StatusUnprocessableEntity)
+	return true
+}
+
+// GET /estudiante/{id}/referencias
+func (ec *EstudianteControlador) MostrarReferenciasFaciales(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	estudiante, err := ec.modelos.ObtenerEstudiantePorID(id)
+	if err != nil {
+		http.Error(w, "Estudiante no encontrado", http.StatusNotFound)
+		return
+	}
+
+	referencias, err := ec.modelos.ObtenerReferenciasFaciales(id)
+	if err != nil {
+		http.Error(w, "Error al obtener las fotos de referencia", http.StatusInternalServerError)
+		return
+	}
+
+	// Historial de referencias automáticas, para que el docente pueda revertirlas
+	actualizaciones, err := ec.modelos.ObtenerActualizacionesReferencia(id)
+	if err != nil {
+		http.Error(w, "Error al obtener las actualizaciones de referencias", http.StatusInternalServerError)
+		return
+	}
+
+	ec.vistaHTML.RenderizarReferenciasFaciales(w, map[string]interface{}{
+		"Estudiante":      estudiante,
+		"Referencias":     referencias,
+		"Actualizaciones": actualizaciones,
+	})
+}
+
+// GET /api/estudiante/{id}/referencias
+func (ec *EstudianteControlador) ListarReferenciasFaciales(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de estudiante inválido"})
+		return
+	}
+
+	referencias, err := ec.modelos.ObtenerReferenciasFaciales(id)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respuesta := make([]map[string]interface{}, 0, len(referencias))
+	for _, ref := range referencias {
+		respuesta = append(respuesta, map[string]interface{}{
+			"id":          ref.ID.String(),
+			"descripcion": ref.Descripcion,
+			"principal":   ref.Principal,
+			"origen":      ref.Origen,
+			"creado_en":   ref.CreadoEn,
+			"foto":        ref.Foto,
+		})
+	}
+
+	helper.EnviarJson(w, http.StatusOK, respuesta)
+}
+
+// POST /api/estudiante/{id}/referencias
+func (ec *EstudianteControlador) AgregarReferenciaFacial(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de estudiante inválido"})
+		return
+	}
+
+	var dto modelo.AgregarReferenciaFacialDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error decodificando JSON"})
+		return
+	}
+
+	referencia, err := ec.modelos.AgregarReferenciaFacial(id, &dto)
+	if err != nil {
+		if responderErrorCalidad(w, "application/json", err) {
+			return
+		}
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusCreated, map[string]interface{}{
+		"message": "Foto de referencia agregada",
+		"id":      referencia.ID.String(),
+	})
+}
+
+// DELETE /api/estudiante/{id}/referencias/{referencia_id}
+func (ec *EstudianteControlador) EliminarReferenciaFacial(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de estudiante inválido"})
+		return
+	}
+	referenciaID, err := uuid.Parse(vars["referencia_id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de referencia inválido"})
+		return
+	}
+
+	if err := ec.modelos.EliminarReferenciaFacial(id, referenciaID); err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]string{"message": "Foto de referencia eliminada"})
+}
+
+// POST /api/estudiante/{id}/actualizaciones-referencia/{actualizacion_id}/revertir
+func (ec *EstudianteControlador) RevertirActualizacionReferencia(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de estudiante inválido"})
+		return
+	}
+	actualizacionID, err := uuid.Parse(vars["actualizacion_id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de actualización inválido"})
+		return
+	}
+
+	// La reversión queda registrada con el docente que la hizo
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusUnauthorized, map[string]string{"error": "No autorizado"})
+		return
+	}
+
+	if err := ec.modelos.RevertirActualizacionReferencia(id, actualizacionID, docenteID); err != nil {
+		estado := http.StatusBadRequest
+		if errors.Is(err, modelo.ErrEstudianteAjeno) {
+			estado = http.StatusForbidden
+		}
+		helper.EnviarJson(w, estado, map[string]string{"error": err.Error()})
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]string{"message": "Actualización revertida"})
+}
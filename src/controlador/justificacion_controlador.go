@@ -0,0 +1,258 @@
+package controlador
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+	"github.com/MetaDandy/Assistense-System/src/controlador/sesion_estado"
+	"github.com/MetaDandy/Assistense-System/src/modelo"
+	"github.com/MetaDandy/Assistense-System/src/vista"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type JustificacionControladorInterfaz interface {
+	MostrarNuevaJustificacion(w http.ResponseWriter, r *http.Request)
+	EnviarJustificacion(w http.ResponseWriter, r *http.Request)
+	MostrarJustificaciones(w http.ResponseWriter, r *http.Request)
+	DescargarDocumento(w http.ResponseWriter, r *http.Request)
+	RevisarJustificacion(w http.ResponseWriter, r *http.Request)
+}
+
+type JustificacionControlador struct {
+	modelo           modelo.JustificacionModeloInterfaz
+	estudianteModelo modelo.EstudianteModeloInterfaz
+	sesionModelo     modelo.SesionAsistenciaInterfaz
+	vista            *vista.JustificacionVistaHTML
+}
+
+func NuevoJustificacionControlador(m modelo.JustificacionModeloInterfaz, em modelo.EstudianteModeloInterfaz, sm modelo.SesionAsistenciaInterfaz, v *vista.JustificacionVistaHTML) JustificacionControladorInterfaz {
+	return &JustificacionControlador{
+		modelo:           m,
+		estudianteModelo: em,
+		sesionModelo:     sm,
+		vista:            v,
+	}
+}
+
+// GET /justificaciones/nueva?estudiante=...&sesion=...
+// Formulario para que un estudiante (o su docente) justifique una ausencia
+func (c *JustificacionControlador) MostrarNuevaJustificacion(w http.ResponseWriter, r *http.Request) {
+	estudiantes, err := c.estudianteModelo.MostrarEstudiantes()
+	if err != nil {
+		http.Error(w, "Error al obtener estudiantes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	todas, err := c.modelo.ObtenerSesionesJustificables()
+	if err != nil {
+		http.Error(w, "Error al obtener sesiones: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Solo las sesiones cuyo estado acepta justificaciones (patrón State)
+	var sesiones []modelo.SesionAsistencia
+	for _, s := range todas {
+		ctx := &sesion_estado.Sesion{
+			Estado: s.Estado,
+		}
+		if ctx.CanAceptarJustificaciones() {
+			sesiones = append(sesiones, s)
+		}
+	}
+
+	data := map[string]interface{}{
+		"Estudiantes":            estudiantes,
+		"Sesiones":               sesiones,
+		"EstudianteSeleccionado": r.URL.Query().Get("estudiante"),
+		"SesionSeleccionada":     r.URL.Query().Get("sesion"),
+		"MaximoDocumentos":       helper.MaximoDocumentos,
+		"TamanoMaximoMB":         helper.TamanoMaximoDocumento >> 20,
+	}
+	c.vista.RenderizarNuevaJustificacion(w, data)
+}
+
+// POST /api/justificaciones
+// Formulario multipart con estudiante_id, motivo, uno o más sesion_ids y hasta MaximoDocumentos documentos
+// Si la envía un docente autenticado, queda registrada como enviada por el docente
+func (c *JustificacionControlador) EnviarJustificacion(w http.ResponseWriter, r *http.Request) {
+	helper.LimitarCuerpoDocumentos(w, r)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		if helper.EsErrorTamano(err) {
+			helper.EnviarJson(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "Los documentos superan el tamaño máximo permitido"})
+			return
+		}
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error al procesar datos"})
+		return
+	}
+
+	estudianteID, err := uuid.Parse(r.FormValue("estudiante_id"))
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Seleccione un estudiante"})
+		return
+	}
+	dto := modelo.EnviarJustificacionDto{
+		EstudianteID: estudianteID,
+		Motivo:       r.FormValue("motivo"),
+	}
+	for _, valor := range r.MultipartForm.Value["sesion_ids"] {
+		sesionID, err := uuid.Parse(valor)
+		if err != nil {
+			helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de sesión inválido"})
+			return
+		}
+		sesion, err := c.sesionModelo.ObtenerSesionAsistencia(sesionID)
+		if err != nil {
+			helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Sesión no encontrada"})
+			return
+		}
+		ctx := &sesion_estado.Sesion{
+			Estado: sesion.Estado,
+		}
+		if !ctx.CanAceptarJustificaciones() {
+			helper.EnviarJson(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("La sesión del %s está %s y no acepta justificaciones", sesion.Fecha(), sesion.Estado)})
+			return
+		}
+		dto.SesionIDs = append(dto.SesionIDs, sesionID)
+	}
+
+	dto.Documentos, err = helper.DocumentosFormulario(r, "documentos")
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, helper.ErrDocumentoDemasiadoGrande) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		helper.EnviarJson(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var docenteID *uuid.UUID
+	if id, err := obtenerDocenteID(r); err == nil {
+		docenteID = &id
+	}
+
+	justificacion, err := c.modelo.EnviarJustificacion(&dto, docenteID)
+	if errors.Is(err, modelo.ErrEstudianteNoInscrito) {
+		helper.EnviarJson(w, http.StatusForbidden, map[string]string{"error": "El estudiante no está inscrito en alguna de las sesiones"})
+		return
+	}
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusCreated, map[string]interface{}{
+		"success":          true,
+		"justificacion_id": justificacion.ID,
+		"estado":           justificacion.Estado,
+	})
+}
+
+// GET /justificaciones
+// Justificaciones de las sesiones del docente, las pendientes primero
+func (c *JustificacionControlador) MostrarJustificaciones(w http.ResponseWriter, r *http.Request) {
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	justificaciones, err := c.modelo.ObtenerJustificacionesDocente(docenteID)
+	if err != nil {
+		http.Error(w, "Error al obtener justificaciones: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pendientes := 0
+	for _, j := range justificaciones {
+		if j.Estado == modelo.JustificacionPendiente {
+			pendientes++
+		}
+	}
+
+	// Las fechas de envío se muestran en la zona horaria del docente
+	zona, err := c.sesionModelo.ZonaHorariaDocente(docenteID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	c.vista.RenderizarJustificaciones(w, map[string]interface{}{
+		"Justificaciones": justificaciones,
+		"Pendientes":      pendientes,
+		"Zona":            zona,
+	})
+}
+
+// GET /justificaciones/{id}/documentos/{documento_id}
+func (c *JustificacionControlador) DescargarDocumento(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	documentoID, err := uuid.Parse(mux.Vars(r)["documento_id"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	justificacion, err := c.modelo.ObtenerJustificacion(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if justificacion.DocenteID != docenteID {
+		http.Error(w, "No tiene acceso a esta justificación", http.StatusForbidden)
+		return
+	}
+
+	documento, err := c.modelo.ObtenerDocumento(id, documentoID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", documento.TipoContenido)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", documento.Nombre))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(documento.Datos)
+}
+
+// POST /api/justificaciones/{id}/revision
+// Aprueba o rechaza una justificación; al aprobarla sus ausencias pasan a justificadas
+func (c *JustificacionControlador) RevisarJustificacion(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de justificación inválido"})
+		return
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusUnauthorized, map[string]string{"error": "No autorizado"})
+		return
+	}
+
+	var dto modelo.RevisarJustificacionDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error al procesar datos"})
+		return
+	}
+
+	justificacion, err := c.modelo.RevisarJustificacion(id, docenteID, &dto)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"estado":  justificacion.Estado,
+	})
+}
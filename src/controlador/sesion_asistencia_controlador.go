@@ -1,7 +1,12 @@
 package controlador
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/MetaDandy/Assistense-System/helper"
 	"github.com/MetaDandy/Assistense-System/src/controlador/sesion_estado"
@@ -21,6 +26,8 @@ type SesionAsistenciaControladorInterfaz interface {
 	MostrarRegistrarAsistencias(w http.ResponseWriter, r *http.Request)
 	ProcesarSeleccionEstudiante(w http.ResponseWriter, r *http.Request)
 	MostrarFormularioFoto(w http.ResponseWriter, r *http.Request)
+	ActualizarConfiguracion(w http.ResponseWriter, r *http.Request)
+	CambiarEstado(w http.ResponseWriter, r *http.Request)
 }
 
 type SesionAsistenciaControlador struct {
@@ -120,24 +127,24 @@ func (c *SesionAsistenciaControlador) ListarSesiones(w http.ResponseWriter, r *h
 		HoraInicio string
 		HoraFin    string
 		Activa     bool
+		Estado     string
 	}
 	var sesionesView []SesionView
 
 	for _, s := range sesiones {
-		// Verificar si la sesión está activa usando el patrón State
+		// Verificar si la sesión está abierta usando el patrón State
 		ctx := &sesion_estado.Sesion{
-			Fecha:      s.Fecha,
-			HoraInicio: s.HoraInicio,
-			HoraFin:    s.HoraFin,
+			Estado: s.Estado,
 		}
 		activa := ctx.CanRegistrarAsistencia()
 
 		sesionesView = append(sesionesView, SesionView{
 			ID:         s.ID.String(),
-			Fecha:      s.Fecha,
-			HoraInicio: s.HoraInicio,
-			HoraFin:    s.HoraFin,
+			Fecha:      s.Fecha(),
+			HoraInicio: s.HoraInicio(),
+			HoraFin:    s.HoraFin(),
 			Activa:     activa,
+			Estado:     s.Estado,
 		})
 	}
 	c.vista.RenderizarListar(w, map[string]interface{}{"Sesiones": sesionesView})
@@ -156,22 +163,95 @@ func (c *SesionAsistenciaControlador) MostrarDetalle(w http.ResponseWriter, r *h
 		return
 	}
 
-	// Verificar si la sesión está activa usando el patrón State
+	// Verificar si la sesión está abierta usando el patrón State
 	ctx := &sesion_estado.Sesion{
-		Fecha:      sesion.Fecha,
-		HoraInicio: sesion.HoraInicio,
-		HoraFin:    sesion.HoraFin,
+		Estado: sesion.Estado,
 	}
 	activa := ctx.CanRegistrarAsistencia()
 
+	umbrales := sesion.Umbrales(c.modelo.UmbralesPorDefecto())
+
+	transiciones, err := c.modelo.ObtenerTransiciones(id)
+	if err != nil {
+		http.Error(w, "Error al obtener el historial de estados: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	data := map[string]interface{}{
-		"Sesion": sesion,
-		"Activa": activa,
+		"Sesion":           sesion,
+		"Activa":           activa,
+		"Acciones":         modelo.AccionesDisponibles(sesion.Estado),
+		"Transiciones":     transiciones,
+		"Zona":             sesion.Zona(),
+		"UmbralAceptacion": umbrales.Aceptacion * 100,
+		"UmbralRevision":   umbrales.Revision * 100,
+		"ModoMetadatos":    sesion.ModoMetadatosVigente(c.modelo.ModoMetadatosPorDefecto()),
+		"Tolerancia":       sesion.ToleranciaTardanzaVigente(c.modelo.ToleranciaTardanzaPorDefecto()),
+		"Gracia":           sesion.GraciaCierreVigente(c.modelo.GraciaCierrePorDefecto()),
 	}
 
 	c.vista.RenderizarDetalle(w, data)
 }
 
+// sesionGestionView es una fila de la pantalla de gestión de sesiones, con la configuración vigente de cada una
+type sesionGestionView struct {
+	ID               string
+	Fecha            string
+	HoraInicio       string
+	HoraFin          string
+	Activa           bool
+	Estado           string
+	Editable         bool
+	Acciones         []string // Acciones de estado disponibles para el docente
+	UmbralAceptacion float64  // En porcentaje
+	UmbralRevision   float64  // En porcentaje
+	UmbralesPropios  bool
+	ModoMetadatos    string
+	ModoPropio       bool
+	Tolerancia       int // Minutos de tolerancia de tardanza
+	ToleranciaPropia bool
+	ZonaHoraria      string
+	Gracia           int // Minutos de gracia después del fin
+	GraciaPropia     bool
+}
+
+// construirSesionesView arma las filas de la pantalla de gestión de sesiones
+func (c *SesionAsistenciaControlador) construirSesionesView(sesiones []modelo.SesionAsistencia) []sesionGestionView {
+	sesionesView := make([]sesionGestionView, 0, len(sesiones))
+
+	for _, s := range sesiones {
+		// Verificar si la sesión está abierta usando el patrón State
+		ctx := &sesion_estado.Sesion{
+			Estado: s.Estado,
+		}
+		activa := ctx.CanRegistrarAsistencia()
+		umbrales := s.Umbrales(c.modelo.UmbralesPorDefecto())
+
+		sesionesView = append(sesionesView, sesionGestionView{
+			ID:         s.ID.String(),
+			Fecha:      s.Fecha(),
+			HoraInicio: s.HoraInicio(),
+			HoraFin:    s.HoraFin(),
+			Activa:     activa,
+			Estado:     s.Estado,
+			Editable:   ctx.CanEditar(),
+			Acciones:   modelo.AccionesDisponibles(s.Estado),
+
+			UmbralAceptacion: umbrales.Aceptacion * 100,
+			UmbralRevision:   umbrales.Revision * 100,
+			UmbralesPropios:  s.UmbralAceptacion != nil || s.UmbralRevision != nil,
+			ModoMetadatos:    s.ModoMetadatosVigente(c.modelo.ModoMetadatosPorDefecto()),
+			ModoPropio:       s.ModoMetadatos != nil,
+			Tolerancia:       s.ToleranciaTardanzaVigente(c.modelo.ToleranciaTardanzaPorDefecto()),
+			ToleranciaPropia: s.ToleranciaTardanza != nil,
+			Gracia:           s.GraciaCierreVigente(c.modelo.GraciaCierrePorDefecto()),
+			GraciaPropia:     s.GraciaCierre != nil,
+			ZonaHoraria:      s.ZonaHoraria,
+		})
+	}
+	return sesionesView
+}
+
 func (c *SesionAsistenciaControlador) MostrarGestionarSesiones(w http.ResponseWriter, r *http.Request) {
 	// Obtener el DocenteID desde el JWT en la cookie
 	cookie, err := r.Cookie("token")
@@ -196,33 +276,11 @@ func (c *SesionAsistenciaControlador) MostrarGestionarSesiones(w http.ResponseWr
 	}
 
 	sesiones, _ := c.modelo.ObtenerSesionesAsistencia(docenteID)
-	type SesionView struct {
-		ID         string
-		Fecha      string
-		HoraInicio string
-		HoraFin    string
-		Activa     bool
-	}
-	var sesionesView []SesionView
-
-	for _, s := range sesiones {
-		// Verificar si la sesión está activa usando el patrón State
-		ctx := &sesion_estado.Sesion{
-			Fecha:      s.Fecha,
-			HoraInicio: s.HoraInicio,
-			HoraFin:    s.HoraFin,
-		}
-		activa := ctx.CanRegistrarAsistencia()
-
-		sesionesView = append(sesionesView, SesionView{
-			ID:         s.ID.String(),
-			Fecha:      s.Fecha,
-			HoraInicio: s.HoraInicio,
-			HoraFin:    s.HoraFin,
-			Activa:     activa,
-		})
-	}
-	c.vista.RenderizarGestionarSesiones(w, map[string]interface{}{"Sesiones": sesionesView})
+	sesionesView := c.construirSesionesView(sesiones)
+	c.vista.RenderizarGestionarSesiones(w, map[string]interface{}{
+		"Sesiones":    sesionesView,
+		"Estudiantes": c.estudiantesInscribibles(),
+	})
 }
 
 func (c *SesionAsistenciaControlador) ProcesarGestionarSesiones(w http.ResponseWriter, r *http.Request) {
@@ -236,6 +294,57 @@ func (c *SesionAsistenciaControlador) ProcesarGestionarSesiones(w http.ResponseW
 	horaInicioStr := r.FormValue("hora_inicio") // "11:33"
 	horaFinStr := r.FormValue("hora_fin")       // "12:33"
 
+	// Umbrales opcionales en porcentaje: vacíos usan los de la institución
+	umbralAceptacion, err := leerUmbralFormulario(r, "umbral_aceptacion")
+	if err != nil {
+		c.renderGestionarConError(w, r, err.Error())
+		return
+	}
+	umbralRevision, err := leerUmbralFormulario(r, "umbral_revision")
+	if err != nil {
+		c.renderGestionarConError(w, r, err.Error())
+		return
+	}
+
+	// Tolerancia de tardanza opcional, en minutos: vacía usa la de la institución
+	var toleranciaTardanza *int
+	if valor := r.FormValue("tolerancia_tardanza"); valor != "" {
+		minutos, err := strconv.Atoi(valor)
+		if err != nil {
+			c.renderGestionarConError(w, r, "Tolerancia de tardanza inválida: "+valor)
+			return
+		}
+		toleranciaTardanza = &minutos
+	}
+
+	// Gracia de cierre opcional, en minutos: vacía usa la de la institución
+	var graciaCierre *int
+	if valor := r.FormValue("gracia_cierre"); valor != "" {
+		minutos, err := strconv.Atoi(valor)
+		if err != nil {
+			c.renderGestionarConError(w, r, "Gracia de cierre inválida: "+valor)
+			return
+		}
+		graciaCierre = &minutos
+	}
+
+	// Modo de validación de metadatos opcional: vacío usa el de la institución
+	var modoMetadatos *string
+	if modo := r.FormValue("modo_metadatos"); modo != "" {
+		modoMetadatos = &modo
+	}
+
+	// Estudiantes inscritos: si no se marca ninguno, el modelo inscribe a todos
+	var estudianteIDs []uuid.UUID
+	for _, valor := range r.Form["estudiante_ids"] {
+		estudianteID, err := uuid.Parse(valor)
+		if err != nil {
+			c.renderGestionarConError(w, r, "Estudiante inválido: "+valor)
+			return
+		}
+		estudianteIDs = append(estudianteIDs, estudianteID)
+	}
+
 	// Obtener el DocenteID desde el JWT en la cookie
 	cookie, err := r.Cookie("token")
 	if err != nil {
@@ -264,16 +373,48 @@ func (c *SesionAsistenciaControlador) ProcesarGestionarSesiones(w http.ResponseW
 		HoraInicio: horaInicioStr, // "11:33"
 		HoraFin:    horaFinStr,    // "12:33"
 		DocenteID:  docenteID,
+
+		UmbralAceptacion: umbralAceptacion,
+		UmbralRevision:   umbralRevision,
+		ModoMetadatos:    modoMetadatos,
+
+		ToleranciaTardanza: toleranciaTardanza,
+		GraciaCierre:       graciaCierre,
+
+		EstudianteIDs: estudianteIDs,
 	}
 
 	_, err = c.modelo.RegistrarSesionAsistencia(dto)
 	if err != nil {
-		c.renderGestionarConError(w, r, "No se pudo registrar la sesión")
+		c.renderGestionarConError(w, r, "No se pudo registrar la sesión: "+err.Error())
 		return
 	}
 	c.renderGestionarConExito(w, r)
 }
 
+// estudiantesInscribibles devuelve los estudiantes que se pueden inscribir al crear una sesión
+func (c *SesionAsistenciaControlador) estudiantesInscribibles() []modelo.Estudiante {
+	estudiantes, err := c.estudianteModelo.MostrarEstudiantes()
+	if err != nil {
+		log.Printf("Error al obtener estudiantes: %v", err)
+	}
+	return estudiantes
+}
+
+// leerUmbralFormulario lee un umbral opcional en porcentaje (0 a 100) y lo devuelve entre 0 y 1
+func leerUmbralFormulario(r *http.Request, campo string) (*float64, error) {
+	valor := r.FormValue(campo)
+	if valor == "" {
+		return nil, nil
+	}
+	porcentaje, err := strconv.ParseFloat(valor, 64)
+	if err != nil || porcentaje < 0 || porcentaje > 100 {
+		return nil, fmt.Errorf("umbral inválido: %s (debe estar entre 0 y 100)", valor)
+	}
+	umbral := porcentaje / 100
+	return &umbral, nil
+}
+
 func (c *SesionAsistenciaControlador) renderGestionarConError(w http.ResponseWriter, r *http.Request, mensaje string) {
 	// Recargar las sesiones para mostrar la lista actualizada
 	cookie, _ := r.Cookie("token")
@@ -282,34 +423,11 @@ func (c *SesionAsistenciaControlador) renderGestionarConError(w http.ResponseWri
 	docenteID, _ := uuid.Parse(docenteIDStr)
 
 	sesiones, _ := c.modelo.ObtenerSesionesAsistencia(docenteID)
-	type SesionView struct {
-		ID         string
-		Fecha      string
-		HoraInicio string
-		HoraFin    string
-		Activa     bool
-	}
-	var sesionesView []SesionView
-
-	for _, s := range sesiones {
-		// Verificar si la sesión está activa usando el patrón State
-		ctx := &sesion_estado.Sesion{
-			Fecha:      s.Fecha,
-			HoraInicio: s.HoraInicio,
-			HoraFin:    s.HoraFin,
-		}
-		activa := ctx.CanRegistrarAsistencia()
-		sesionesView = append(sesionesView, SesionView{
-			ID:         s.ID.String(),
-			Fecha:      s.Fecha,
-			HoraInicio: s.HoraInicio,
-			HoraFin:    s.HoraFin,
-			Activa:     activa,
-		})
-	}
+	sesionesView := c.construirSesionesView(sesiones)
 	c.vista.RenderizarGestionarSesiones(w, map[string]interface{}{
-		"Sesiones": sesionesView,
-		"Error":    mensaje,
+		"Sesiones":    sesionesView,
+		"Estudiantes": c.estudiantesInscribibles(),
+		"Error":       mensaje,
 	})
 }
 
@@ -321,34 +439,11 @@ func (c *SesionAsistenciaControlador) renderGestionarConExito(w http.ResponseWri
 	docenteID, _ := uuid.Parse(docenteIDStr)
 
 	sesiones, _ := c.modelo.ObtenerSesionesAsistencia(docenteID)
-	type SesionView struct {
-		ID         string
-		Fecha      string
-		HoraInicio string
-		HoraFin    string
-		Activa     bool
-	}
-	var sesionesView []SesionView
-
-	for _, s := range sesiones {
-		// Verificar si la sesión está activa usando el patrón State
-		ctx := &sesion_estado.Sesion{
-			Fecha:      s.Fecha,
-			HoraInicio: s.HoraInicio,
-			HoraFin:    s.HoraFin,
-		}
-		activa := ctx.CanRegistrarAsistencia()
-		sesionesView = append(sesionesView, SesionView{
-			ID:         s.ID.String(),
-			Fecha:      s.Fecha,
-			HoraInicio: s.HoraInicio,
-			HoraFin:    s.HoraFin,
-			Activa:     activa,
-		})
-	}
+	sesionesView := c.construirSesionesView(sesiones)
 	c.vista.RenderizarGestionarSesiones(w, map[string]interface{}{
-		"Sesiones": sesionesView,
-		"Exito":    true,
+		"Sesiones":    sesionesView,
+		"Estudiantes": c.estudiantesInscribibles(),
+		"Exito":       true,
 	})
 }
 
@@ -367,19 +462,17 @@ func (c *SesionAsistenciaControlador) MostrarRegistrarAsistencias(w http.Respons
 		return
 	}
 
-	// Verificar que la sesión esté activa usando el patrón State
+	// Verificar que la sesión esté abierta usando el patrón State
 	ctx := &sesion_estado.Sesion{
-		Fecha:      sesion.Fecha,
-		HoraInicio: sesion.HoraInicio,
-		HoraFin:    sesion.HoraFin,
+		Estado: sesion.Estado,
 	}
 	if !ctx.CanRegistrarAsistencia() {
-		http.Error(w, "Solo se pueden registrar asistencias en sesiones activas", http.StatusForbidden)
+		http.Error(w, "La sesión no está recibiendo asistencias", http.StatusForbidden)
 		return
 	}
 
-	// Obtener lista de estudiantes REALES de la base de datos
-	estudiantesDB, err := c.estudianteModelo.MostrarEstudiantes()
+	// Solo los estudiantes inscritos en la sesión
+	estudiantesDB, err := c.modelo.ObtenerEstudiantesInscritos(id)
 	if err != nil {
 		http.Error(w, "Error al obtener estudiantes: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -465,11 +558,9 @@ func (c *SesionAsistenciaControlador) MostrarFormularioFoto(w http.ResponseWrite
 		return
 	}
 
-	// Verificar que la sesión esté activa usando el patrón State
+	// Verificar que la sesión esté abierta usando el patrón State
 	ctx := &sesion_estado.Sesion{
-		Fecha:      sesion.Fecha,
-		HoraInicio: sesion.HoraInicio,
-		HoraFin:    sesion.HoraFin,
+		Estado: sesion.Estado,
 	}
 	activa := ctx.CanVerRostro()
 
@@ -485,3 +576,101 @@ func (c *SesionAsistenciaControlador) MostrarFormularioFoto(w http.ResponseWrite
 
 	c.vista.RenderizarFormularioFoto(w, data)
 }
+
+// ActualizarConfiguracion cambia umbrales (entre 0 y 1), modo de metadatos, tolerancia y gracia (minutos)
+// de una sesión del docente (JSON). Los campos nulos no cambian; los listados en "restablecer" vuelven
+// al valor de la institución
+func (c *SesionAsistenciaControlador) ActualizarConfiguracion(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de sesión inválido"})
+		return
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusUnauthorized, map[string]string{"error": "No autorizado"})
+		return
+	}
+	if !c.verificarSesionEditable(w, id) {
+		return
+	}
+
+	var dto modelo.ActualizarConfiguracionSesionDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error al procesar datos"})
+		return
+	}
+
+	sesion, err := c.modelo.ActualizarConfiguracion(id, docenteID, &dto)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	umbrales := sesion.Umbrales(c.modelo.UmbralesPorDefecto())
+	helper.EnviarJson(w, http.StatusOK, map[string]interface{}{
+		"success":             true,
+		"umbral_aceptacion":   umbrales.Aceptacion,
+		"umbral_revision":     umbrales.Revision,
+		"modo_metadatos":      sesion.ModoMetadatosVigente(c.modelo.ModoMetadatosPorDefecto()),
+		"tolerancia_tardanza": sesion.ToleranciaTardanzaVigente(c.modelo.ToleranciaTardanzaPorDefecto()),
+		"gracia_cierre":       sesion.GraciaCierreVigente(c.modelo.GraciaCierrePorDefecto()),
+	})
+}
+
+// verificarSesionEditable responde con un error si la sesión no existe o su estado no permite cambiar
+// su configuración (patrón State)
+func (c *SesionAsistenciaControlador) verificarSesionEditable(w http.ResponseWriter, id uuid.UUID) bool {
+	sesion, err := c.modelo.ObtenerSesionAsistencia(id)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusNotFound, map[string]string{"error": "Sesión no encontrada"})
+		return false
+	}
+	ctx := &sesion_estado.Sesion{
+		Estado: sesion.Estado,
+	}
+	if !ctx.CanEditar() {
+		helper.EnviarJson(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("Una sesión %s no se puede modificar", sesion.Estado)})
+		return false
+	}
+	return true
+}
+
+// POST /api/sesion-asistencia/{id}/estado
+// Abre, cierra, suspende, reanuda o cancela una sesión del docente (JSON: accion y motivo opcional)
+func (c *SesionAsistenciaControlador) CambiarEstado(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "ID de sesión inválido"})
+		return
+	}
+
+	docenteID, err := obtenerDocenteID(r)
+	if err != nil {
+		helper.EnviarJson(w, http.StatusUnauthorized, map[string]string{"error": "No autorizado"})
+		return
+	}
+
+	var dto modelo.CambiarEstadoSesionDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": "Error al procesar datos"})
+		return
+	}
+
+	sesion, err := c.modelo.CambiarEstado(id, docenteID, &dto)
+	if errors.Is(err, modelo.ErrTransicionInvalida) {
+		helper.EnviarJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		helper.EnviarJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	helper.EnviarJson(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"estado":   sesion.Estado,
+		"acciones": modelo.AccionesDisponibles(sesion.Estado),
+	})
+}
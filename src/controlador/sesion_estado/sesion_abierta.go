@@ -0,0 +1,29 @@
+package sesion_estado
+
+// SesionAbierta implementa el estado de la sesión abierta, por su horario o por el docente
+type SesionAbierta struct{}
+
+// CanRegistrarAsistencia devuelve true porque en estado abierto se puede registrar asistencia
+func (s *SesionAbierta) CanRegistrarAsistencia() bool {
+	return true
+}
+
+// CanVerRostro devuelve true porque en estado abierto se puede verificar rostro
+func (s *SesionAbierta) CanVerRostro() bool {
+	return true
+}
+
+// CanEditar devuelve true: los cambios afectan a las asistencias que se registren desde ahora
+func (s *SesionAbierta) CanEditar() bool {
+	return true
+}
+
+// CanAceptarJustificaciones devuelve true
+func (s *SesionAbierta) CanAceptarJustificaciones() bool {
+	return true
+}
+
+// RequiereAprobacionDocente devuelve false: la verificación facial decide
+func (s *SesionAbierta) RequiereAprobacionDocente() bool {
+	return false
+}
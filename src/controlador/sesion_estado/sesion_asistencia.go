@@ -1,43 +1,59 @@
 package sesion_estado
 
 import (
-	"time"
+	"github.com/MetaDandy/Assistense-System/src/modelo"
 )
 
 // Sesion es el contexto del patrón State
-// Contiene los datos necesarios para calcular el estado
+// Contiene el estado guardado de la sesión; el modelo lo mantiene al día con el horario
 type Sesion struct {
-	Fecha      string
-	HoraInicio string
-	HoraFin    string
+	Estado string
 }
 
-// CanRegistrarAsistencia devuelve true si la sesión está en estado activo
-// Calcula el estado actual y delega al estado
+// CanRegistrarAsistencia devuelve true si la sesión recibe registros (abierta, en tardanza o en gracia)
+// Obtiene el estado actual y delega al estado
 func (s *Sesion) CanRegistrarAsistencia() bool {
-	estado := s.obtenerEstadoActual()
-	return estado.CanRegistrarAsistencia()
+	return s.obtenerEstadoActual().CanRegistrarAsistencia()
 }
 
-// CanVerRostro devuelve true si la sesión está en estado activo
-// Calcula el estado actual y delega al estado
+// CanVerRostro devuelve true si la sesión recibe registros
+// Obtiene el estado actual y delega al estado
 func (s *Sesion) CanVerRostro() bool {
-	estado := s.obtenerEstadoActual()
-	return estado.CanVerRostro()
+	return s.obtenerEstadoActual().CanVerRostro()
 }
 
-// obtenerEstadoActual determina automáticamente el estado actual de la sesión
-// basado en la fecha y hora actual comparadas con el rango definido
-func (s *Sesion) obtenerEstadoActual() SesionEstado {
-	now := time.Now()
-	fechaActual := now.Format("2006-01-02")
-	horaActual := now.Format("15:04")
+// CanEditar devuelve true si la configuración de la sesión todavía se puede cambiar
+func (s *Sesion) CanEditar() bool {
+	return s.obtenerEstadoActual().CanEditar()
+}
 
-	// Si la sesión es de hoy y la hora actual está dentro del rango, está activa
-	if s.Fecha == fechaActual && horaActual >= s.HoraInicio && horaActual <= s.HoraFin {
-		return &SesionActiva{}
-	}
+// CanAceptarJustificaciones devuelve true si se pueden justificar ausencias de la sesión
+func (s *Sesion) CanAceptarJustificaciones() bool {
+	return s.obtenerEstadoActual().CanAceptarJustificaciones()
+}
 
-	// En cualquier otro caso, está inactiva
-	return &SesionInactiva{}
+// RequiereAprobacionDocente devuelve true si las asistencias que se registren esperan al docente
+func (s *Sesion) RequiereAprobacionDocente() bool {
+	return s.obtenerEstadoActual().RequiereAprobacionDocente()
+}
+
+// obtenerEstadoActual devuelve la implementación del estado guardado
+// Un estado desconocido se trata como cerrado: no permite registrar ni editar
+func (s *Sesion) obtenerEstadoActual() SesionEstado {
+	switch s.Estado {
+	case modelo.EstadoSesionProgramada:
+		return &SesionProgramada{}
+	case modelo.EstadoSesionAbierta:
+		return &SesionAbierta{}
+	case modelo.EstadoSesionTardanza:
+		return &SesionTardanza{}
+	case modelo.EstadoSesionSuspendida:
+		return &SesionSuspendida{}
+	case modelo.EstadoSesionGracia:
+		return &SesionGracia{}
+	case modelo.EstadoSesionCancelada:
+		return &SesionCancelada{}
+	default:
+		return &SesionCerrada{}
+	}
 }
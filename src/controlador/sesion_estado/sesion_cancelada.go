@@ -0,0 +1,29 @@
+package sesion_estado
+
+// SesionCancelada implementa el estado de la sesión que el docente canceló antes de abrirla
+type SesionCancelada struct{}
+
+// CanRegistrarAsistencia devuelve false porque la sesión no se dicta
+func (s *SesionCancelada) CanRegistrarAsistencia() bool {
+	return false
+}
+
+// CanVerRostro devuelve false porque la sesión no se dicta
+func (s *SesionCancelada) CanVerRostro() bool {
+	return false
+}
+
+// CanEditar devuelve false porque la sesión no se dicta
+func (s *SesionCancelada) CanEditar() bool {
+	return false
+}
+
+// CanAceptarJustificaciones devuelve false: en una sesión cancelada no hay ausencias
+func (s *SesionCancelada) CanAceptarJustificaciones() bool {
+	return false
+}
+
+// RequiereAprobacionDocente devuelve false porque no se registran asistencias
+func (s *SesionCancelada) RequiereAprobacionDocente() bool {
+	return false
+}
@@ -0,0 +1,29 @@
+package sesion_estado
+
+// SesionCerrada implementa el estado de la sesión terminada, por su horario o por el docente
+type SesionCerrada struct{}
+
+// CanRegistrarAsistencia devuelve false porque la sesión terminó
+func (s *SesionCerrada) CanRegistrarAsistencia() bool {
+	return false
+}
+
+// CanVerRostro devuelve false porque la sesión terminó
+func (s *SesionCerrada) CanVerRostro() bool {
+	return false
+}
+
+// CanEditar devuelve false: ya no se registrarán asistencias a las que aplicar los cambios
+func (s *SesionCerrada) CanEditar() bool {
+	return false
+}
+
+// CanAceptarJustificaciones devuelve true porque las ausencias se justifican después de la sesión
+func (s *SesionCerrada) CanAceptarJustificaciones() bool {
+	return true
+}
+
+// RequiereAprobacionDocente devuelve false porque no se registran asistencias
+func (s *SesionCerrada) RequiereAprobacionDocente() bool {
+	return false
+}
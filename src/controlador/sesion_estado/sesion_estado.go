@@ -8,4 +8,14 @@ type SesionEstado interface {
 
 	// CanVerRostro indica si se puede ver/verificar el rostro en este estado
 	CanVerRostro() bool
+
+	// CanEditar indica si se puede cambiar la configuración de la sesión (umbrales, metadatos, tolerancia)
+	CanEditar() bool
+
+	// CanAceptarJustificaciones indica si se pueden enviar justificaciones de ausencia para la sesión
+	CanAceptarJustificaciones() bool
+
+	// RequiereAprobacionDocente indica si las asistencias que se registren quedan pendientes del docente,
+	// aunque la verificación facial las acepte
+	RequiereAprobacionDocente() bool
 }
@@ -0,0 +1,30 @@
+package sesion_estado
+
+// SesionGracia implementa el estado de la sesión pasada su hora de fin, durante los minutos de gracia:
+// todavía se reciben registros, pero solo valen si el docente los aprueba
+type SesionGracia struct{}
+
+// CanRegistrarAsistencia devuelve true: el registro queda pendiente del docente
+func (s *SesionGracia) CanRegistrarAsistencia() bool {
+	return true
+}
+
+// CanVerRostro devuelve true porque todavía se registran asistencias
+func (s *SesionGracia) CanVerRostro() bool {
+	return true
+}
+
+// CanEditar devuelve true para que el docente pueda extender o acortar la gracia
+func (s *SesionGracia) CanEditar() bool {
+	return true
+}
+
+// CanAceptarJustificaciones devuelve true
+func (s *SesionGracia) CanAceptarJustificaciones() bool {
+	return true
+}
+
+// RequiereAprobacionDocente devuelve true: fuera de horario ningún registro se acepta solo
+func (s *SesionGracia) RequiereAprobacionDocente() bool {
+	return true
+}
@@ -0,0 +1,29 @@
+package sesion_estado
+
+// SesionProgramada implementa el estado de la sesión que todavía no se abrió
+type SesionProgramada struct{}
+
+// CanRegistrarAsistencia devuelve false porque la sesión todavía no se abrió
+func (s *SesionProgramada) CanRegistrarAsistencia() bool {
+	return false
+}
+
+// CanVerRostro devuelve false porque la sesión todavía no se abrió
+func (s *SesionProgramada) CanVerRostro() bool {
+	return false
+}
+
+// CanEditar devuelve true para preparar la sesión antes de abrirla
+func (s *SesionProgramada) CanEditar() bool {
+	return true
+}
+
+// CanAceptarJustificaciones devuelve true: una ausencia prevista se puede justificar de antemano
+func (s *SesionProgramada) CanAceptarJustificaciones() bool {
+	return true
+}
+
+// RequiereAprobacionDocente devuelve false porque no se registran asistencias
+func (s *SesionProgramada) RequiereAprobacionDocente() bool {
+	return false
+}
@@ -0,0 +1,29 @@
+package sesion_estado
+
+// SesionSuspendida implementa el estado de la sesión que el docente suspendió y puede reanudar
+type SesionSuspendida struct{}
+
+// CanRegistrarAsistencia devuelve false mientras la sesión está suspendida
+func (s *SesionSuspendida) CanRegistrarAsistencia() bool {
+	return false
+}
+
+// CanVerRostro devuelve false mientras la sesión está suspendida
+func (s *SesionSuspendida) CanVerRostro() bool {
+	return false
+}
+
+// CanEditar devuelve true porque la sesión se puede reanudar
+func (s *SesionSuspendida) CanEditar() bool {
+	return true
+}
+
+// CanAceptarJustificaciones devuelve true
+func (s *SesionSuspendida) CanAceptarJustificaciones() bool {
+	return true
+}
+
+// RequiereAprobacionDocente devuelve false porque no se registran asistencias
+func (s *SesionSuspendida) RequiereAprobacionDocente() bool {
+	return false
+}
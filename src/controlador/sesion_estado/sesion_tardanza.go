@@ -0,0 +1,30 @@
+package sesion_estado
+
+// SesionTardanza implementa el estado de la sesión abierta pasada la tolerancia de tardanza:
+// las llegadas se siguen aceptando, pero como tarde
+type SesionTardanza struct{}
+
+// CanRegistrarAsistencia devuelve true: la asistencia se registra como tarde
+func (s *SesionTardanza) CanRegistrarAsistencia() bool {
+	return true
+}
+
+// CanVerRostro devuelve true porque todavía se registran asistencias
+func (s *SesionTardanza) CanVerRostro() bool {
+	return true
+}
+
+// CanEditar devuelve true: los cambios afectan a las asistencias que se registren desde ahora
+func (s *SesionTardanza) CanEditar() bool {
+	return true
+}
+
+// CanAceptarJustificaciones devuelve true
+func (s *SesionTardanza) CanAceptarJustificaciones() bool {
+	return true
+}
+
+// RequiereAprobacionDocente devuelve false: la verificación facial decide
+func (s *SesionTardanza) RequiereAprobacionDocente() bool {
+	return false
+}
@@ -0,0 +1,179 @@
+package modelo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Acciones de un ajuste manual de asistencia
+const (
+	AccionAjusteCrear     = "crear"
+	AccionAjusteModificar = "modificar"
+	AccionAjusteEliminar  = "eliminar"
+)
+
+// Errores de los ajustes manuales que el controlador distingue
+var (
+	ErrAsistenciaExistente = errors.New("el estudiante ya tiene asistencia en esta sesión")
+	ErrAsistenciaConFoto   = errors.New("una asistencia con foto de verificación no se elimina; cambie su condición")
+)
+
+// AjusteAsistencia registra cada cambio manual del docente sobre la asistencia de un estudiante
+// No tiene clave foránea a la asistencia: los ajustes de una asistencia eliminada se conservan
+type AjusteAsistencia struct {
+	ID                uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	Accion            string    `gorm:"type:varchar(20);not null"`
+	CondicionAnterior string    `gorm:"type:varchar(20)"` // Vacía al crear
+	CondicionNueva    string    `gorm:"type:varchar(20)"` // Vacía al eliminar
+	Motivo            string    `gorm:"type:varchar(255);not null"`
+	FechaHora         time.Time `gorm:"not null"`
+
+	AsistenciaID       uuid.UUID `gorm:"type:uuid;not null;index"`
+	EstudianteID       uuid.UUID `gorm:"type:uuid;not null"`
+	SesionAsistenciaID uuid.UUID `gorm:"type:uuid;not null;index"`
+	DocenteID          uuid.UUID `gorm:"type:uuid;not null"`
+
+	Estudiante Estudiante `gorm:"foreignKey:EstudianteID"`
+	Docente    Docente    `gorm:"foreignKey:DocenteID"`
+}
+
+type AjusteAsistenciaDto struct {
+	EstudianteID uuid.UUID `json:"estudiante_id"` // Solo al crear
+	Condicion    string    `json:"condicion"`     // No se usa al eliminar
+	Motivo       string    `json:"motivo" binding:"required"`
+}
+
+// validar revisa el motivo y, si se pide, la condición; pendiente_revision no se asigna a mano
+func (dto *AjusteAsistenciaDto) validar(conCondicion bool) error {
+	dto.Motivo = strings.TrimSpace(dto.Motivo)
+	if dto.Motivo == "" {
+		return fmt.Errorf("el motivo es obligatorio")
+	}
+	if len(dto.Motivo) > 255 {
+		return fmt.Errorf("el motivo no puede superar los 255 caracteres")
+	}
+	if conCondicion && (!EsCondicionValida(dto.Condicion) || dto.Condicion == CondicionPendienteRevision) {
+		return fmt.Errorf("condición inválida: %s", dto.Condicion)
+	}
+	return nil
+}
+
+// CrearAsistenciaManual registra la asistencia de un estudiante sin foto, con la condición que indica el docente
+func (am *AsistenciaModelo) CrearAsistenciaManual(sesionID, docenteID uuid.UUID, dto *AjusteAsistenciaDto) (*Asistencia, error) {
+	if err := dto.validar(true); err != nil {
+		return nil, err
+	}
+	if _, err := am.estudianteModelo.ObtenerEstudiantePorID(dto.EstudianteID); err != nil {
+		return nil, fmt.Errorf("estudiante no encontrado")
+	}
+	inscrito, err := am.sesionModelo.EstaInscrito(sesionID, dto.EstudianteID)
+	if err != nil {
+		return nil, err
+	}
+	if !inscrito {
+		return nil, ErrEstudianteNoInscrito
+	}
+	existe, err := am.VerificarAsistenciaExistente(dto.EstudianteID, sesionID)
+	if err != nil {
+		return nil, err
+	}
+	if existe {
+		return nil, ErrAsistenciaExistente
+	}
+
+	ahora := time.Now()
+	asistencia := &Asistencia{
+		ID:                 uuid.New(),
+		FechaHora:          ahora,
+		Estado:             EstadoManual,
+		Condicion:          dto.Condicion,
+		EstudianteID:       dto.EstudianteID,
+		SesionAsistenciaID: sesionID,
+	}
+	err = am.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(asistencia).Error; err != nil {
+			return err
+		}
+		return tx.Create(nuevoAjuste(asistencia, docenteID, AccionAjusteCrear, "", dto.Condicion, dto.Motivo, ahora)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asistencia, nil
+}
+
+// ModificarAsistenciaManual cambia la condición de una asistencia, con o sin foto; desde entonces su estado
+// es manual, así que sale de la cola de revisión. Los datos de la verificación facial se conservan
+func (am *AsistenciaModelo) ModificarAsistenciaManual(asistenciaID, docenteID uuid.UUID, dto *AjusteAsistenciaDto) (*Asistencia, error) {
+	if err := dto.validar(true); err != nil {
+		return nil, err
+	}
+
+	var asistencia Asistencia
+	err := am.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", asistenciaID).First(&asistencia).Error; err != nil {
+			return fmt.Errorf("asistencia no encontrada")
+		}
+		anterior := asistencia.Condicion
+		if err := tx.Model(&asistencia).Updates(map[string]interface{}{"estado": EstadoManual, "condicion": dto.Condicion}).Error; err != nil {
+			return err
+		}
+		asistencia.Estado, asistencia.Condicion = EstadoManual, dto.Condicion
+		return tx.Create(nuevoAjuste(&asistencia, docenteID, AccionAjusteModificar, anterior, dto.Condicion, dto.Motivo, time.Now())).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &asistencia, nil
+}
+
+// EliminarAsistenciaManual elimina una asistencia sin foto (manual o ausencia marcada al cerrar la sesión)
+// Las que tienen foto son evidencia de la verificación facial y sus sospechas y revisiones dependen de ellas
+func (am *AsistenciaModelo) EliminarAsistenciaManual(asistenciaID, docenteID uuid.UUID, dto *AjusteAsistenciaDto) error {
+	if err := dto.validar(false); err != nil {
+		return err
+	}
+
+	return am.db.Transaction(func(tx *gorm.DB) error {
+		var asistencia Asistencia
+		if err := tx.Where("id = ?", asistenciaID).First(&asistencia).Error; err != nil {
+			return fmt.Errorf("asistencia no encontrada")
+		}
+		if asistencia.FotoVerificacion != "" {
+			return ErrAsistenciaConFoto
+		}
+		if err := tx.Delete(&asistencia).Error; err != nil {
+			return err
+		}
+		return tx.Create(nuevoAjuste(&asistencia, docenteID, AccionAjusteEliminar, asistencia.Condicion, "", dto.Motivo, time.Now())).Error
+	})
+}
+
+// ObtenerAjustesPorSesion lista los ajustes manuales de la sesión, del más reciente al más antiguo
+func (am *AsistenciaModelo) ObtenerAjustesPorSesion(sesionID uuid.UUID) ([]AjusteAsistencia, error) {
+	var ajustes []AjusteAsistencia
+	err := am.db.Preload("Estudiante").Preload("Docente").
+		Where("sesion_asistencia_id = ?", sesionID).
+		Order("fecha_hora DESC").Find(&ajustes).Error
+	return ajustes, err
+}
+
+func nuevoAjuste(a *Asistencia, docenteID uuid.UUID, accion, anterior, nueva, motivo string, fecha time.Time) *AjusteAsistencia {
+	return &AjusteAsistencia{
+		ID:                 uuid.New(),
+		Accion:             accion,
+		CondicionAnterior:  anterior,
+		CondicionNueva:     nueva,
+		Motivo:             motivo,
+		FechaHora:          fecha,
+		AsistenciaID:       a.ID,
+		EstudianteID:       a.EstudianteID,
+		SesionAsistenciaID: a.SesionAsistenciaID,
+		DocenteID:          docenteID,
+	}
+}
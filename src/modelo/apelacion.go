@@ -0,0 +1,360 @@
+package modelo
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+	"github.com/MetaDandy/Assistense-System/src/modelo/cadena_responsabilidad"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Estados de una apelación
+const (
+	ApelacionPendiente = "pendiente"
+	ApelacionConcedida = "concedida"
+	ApelacionDenegada  = "denegada"
+)
+
+// Decisiones del docente sobre una apelación
+const (
+	DecisionConceder = "conceder"
+	DecisionDenegar  = "denegar"
+)
+
+// Errores de los intentos rechazados y sus apelaciones que el controlador distingue
+var (
+	ErrApelacionExistente    = errors.New("este intento ya fue apelado")
+	ErrApelacionNoAutorizada = errors.New("el intento no corresponde a este estudiante o el código de apelación no es válido")
+	ErrDemasiadosIntentos    = errors.New("demasiados intentos rechazados seguidos")
+)
+
+// Límite de intentos rechazados por rostro: cada uno guarda su foto, así que tras IntentosRechazadosMaximos
+// en VentanaIntentosRechazados el estudiante espera antes de volver a intentar en la sesión
+const (
+	IntentosRechazadosMaximos = 5
+	VentanaIntentosRechazados = 10 * time.Minute
+)
+
+// IntentoRechazado es una asistencia rechazada porque el rostro no coincidió lo suficiente
+// Se guarda con la foto y la comparación para que el estudiante pueda apelarla
+type IntentoRechazado struct {
+	ID                uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	FechaHora         time.Time `gorm:"not null"`
+	FotoVerificacion  string    `gorm:"type:text"`
+	Similitud         float64   `gorm:"type:decimal(5,4)"`
+	UmbralRevision    float64   `gorm:"type:decimal(5,4)"` // Mínimo que no alcanzó
+	DesgloseSimilitud string    `gorm:"type:text"`
+
+	// Algoritmo y Version tienen el mismo largo que en CaracteristicasReferencia
+	CaracteristicasVerificacion []byte `gorm:"type:bytea"`
+	Algoritmo                   string `gorm:"type:varchar(100)"`
+	Version                     string `gorm:"type:varchar(50)"`
+
+	// Hash SHA-256 del código que recibió quien hizo el intento; sin él no se puede apelar
+	TokenApelacion string `gorm:"type:varchar(64)"`
+
+	EstudianteID       uuid.UUID  `gorm:"type:uuid;not null;index"`
+	SesionAsistenciaID uuid.UUID  `gorm:"type:uuid;not null;index"`
+	ReferenciaFacialID *uuid.UUID `gorm:"type:uuid"` // Referencia que mejor coincidió
+
+	Estudiante       Estudiante        `gorm:"foreignKey:EstudianteID"`
+	SesionAsistencia SesionAsistencia  `gorm:"foreignKey:SesionAsistenciaID"`
+	ReferenciaFacial *ReferenciaFacial `gorm:"foreignKey:ReferenciaFacialID"`
+}
+
+// Desglose devuelve los componentes de la similitud guardados con el intento (nil si no hay)
+func (i *IntentoRechazado) Desglose() []helper.ComponenteSimilitud {
+	return leerDesglose(i.DesgloseSimilitud)
+}
+
+// Apelacion es el reclamo del estudiante sobre un intento rechazado; si el docente la concede,
+// se crea la asistencia con referencia a la apelación
+type Apelacion struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	Motivo     string    `gorm:"type:varchar(500);not null"`
+	Estado     string    `gorm:"type:varchar(20);not null;default:'pendiente';index"`
+	FechaHora  time.Time `gorm:"not null"`
+	Comentario string    `gorm:"type:varchar(255)"` // Del docente al revisarla
+	RevisadaEn *time.Time
+
+	IntentoRechazadoID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex"`
+	SesionAsistenciaID uuid.UUID  `gorm:"type:uuid;not null;index"`
+	DocenteID          *uuid.UUID `gorm:"type:uuid"` // Quien la revisó
+
+	IntentoRechazado IntentoRechazado `gorm:"foreignKey:IntentoRechazadoID"`
+}
+
+// El estudiante y el código de apelación son los de la respuesta del rechazo: atan la apelación a quien hizo el intento
+type ApelarIntentoDto struct {
+	Motivo         string    `json:"motivo" binding:"required"`
+	EstudianteID   uuid.UUID `json:"estudiante_id" binding:"required"`
+	TokenApelacion string    `json:"token_apelacion" binding:"required"`
+}
+
+type RevisarApelacionDto struct {
+	Decision   string `json:"decision" binding:"required"` // conceder | denegar
+	Comentario string `json:"comentario"`
+}
+
+// registrarIntentoRechazado guarda el intento que ValidadorSimilitud rechazó, con su foto y su comparación
+// Devuelve también el código para apelarlo, que solo se guarda hasheado
+func (am *AsistenciaModelo) registrarIntentoRechazado(solicitud *cadena_responsabilidad.SolicitudAsistencia, umbral float64) (uuid.UUID, string, error) {
+	token, err := generarTokenApelacion()
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	intento := &IntentoRechazado{
+		ID:                          uuid.New(),
+		FechaHora:                   time.Now(),
+		FotoVerificacion:            solicitud.FotoVerificacion,
+		Similitud:                   solicitud.Similitud,
+		UmbralRevision:              umbral,
+		CaracteristicasVerificacion: solicitud.CaracteristicasVerificacion,
+		Algoritmo:                   am.matcher.Algoritmo(),
+		Version:                     am.matcher.Version(),
+		TokenApelacion:              hashTokenApelacion(token),
+		EstudianteID:                solicitud.EstudianteID,
+		SesionAsistenciaID:          solicitud.SesionID,
+	}
+	if solicitud.ReferenciaID != uuid.Nil {
+		intento.ReferenciaFacialID = &solicitud.ReferenciaID
+	}
+	if len(solicitud.Desglose) > 0 {
+		desglose, err := json.Marshal(solicitud.Desglose)
+		if err != nil {
+			return uuid.Nil, "", err
+		}
+		intento.DesgloseSimilitud = string(desglose)
+	}
+	if err := am.db.Create(intento).Error; err != nil {
+		return uuid.Nil, "", err
+	}
+	return intento.ID, token, nil
+}
+
+// verificarIntentosRechazados devuelve ErrDemasiadosIntentos si el estudiante ya acumuló
+// IntentosRechazadosMaximos rechazos por rostro en la sesión durante la última VentanaIntentosRechazados
+func (am *AsistenciaModelo) verificarIntentosRechazados(estudianteID, sesionID uuid.UUID) error {
+	var recientes int64
+	if err := am.db.Model(&IntentoRechazado{}).
+		Where("estudiante_id = ? AND sesion_asistencia_id = ? AND fecha_hora > ?", estudianteID, sesionID, time.Now().Add(-VentanaIntentosRechazados)).
+		Count(&recientes).Error; err != nil {
+		return err
+	}
+	if recientes >= IntentosRechazadosMaximos {
+		return ErrDemasiadosIntentos
+	}
+	return nil
+}
+
+// generarTokenApelacion genera el código aleatorio con el que se apela un intento
+func generarTokenApelacion() (string, error) {
+	datos := make([]byte, 32)
+	if _, err := rand.Read(datos); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(datos), nil
+}
+
+func hashTokenApelacion(token string) string {
+	suma := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(suma[:])
+}
+
+// ApelarIntento registra la apelación del estudiante sobre su intento rechazado; cada intento se apela una vez
+// Solo la acepta con el estudiante del intento y el código que recibió en la respuesta del rechazo
+func (am *AsistenciaModelo) ApelarIntento(intentoID uuid.UUID, dto *ApelarIntentoDto) (*Apelacion, error) {
+	motivo := strings.TrimSpace(dto.Motivo)
+	if motivo == "" {
+		return nil, fmt.Errorf("el motivo es obligatorio")
+	}
+	if len(motivo) > 500 {
+		return nil, fmt.Errorf("el motivo no puede superar los 500 caracteres")
+	}
+
+	var intento IntentoRechazado
+	if err := am.db.Select("id", "sesion_asistencia_id", "estudiante_id", "token_apelacion").Where("id = ?", intentoID).
+		First(&intento).Error; err != nil {
+		return nil, fmt.Errorf("intento no encontrado")
+	}
+	if intento.TokenApelacion == "" || intento.EstudianteID != dto.EstudianteID ||
+		subtle.ConstantTimeCompare([]byte(intento.TokenApelacion), []byte(hashTokenApelacion(dto.TokenApelacion))) != 1 {
+		return nil, ErrApelacionNoAutorizada
+	}
+	var existentes int64
+	if err := am.db.Model(&Apelacion{}).Where("intento_rechazado_id = ?", intentoID).Count(&existentes).Error; err != nil {
+		return nil, err
+	}
+	if existentes > 0 {
+		return nil, ErrApelacionExistente
+	}
+
+	apelacion := &Apelacion{
+		ID:                 uuid.New(),
+		Motivo:             motivo,
+		Estado:             ApelacionPendiente,
+		FechaHora:          time.Now(),
+		IntentoRechazadoID: intento.ID,
+		SesionAsistenciaID: intento.SesionAsistenciaID,
+	}
+	if err := am.db.Create(apelacion).Error; err != nil {
+		return nil, err
+	}
+	return apelacion, nil
+}
+
+// ObtenerApelacionesPorSesion lista las apelaciones de la sesión con el intento, el estudiante y la referencia
+// que mejor coincidió; primero las pendientes, de la más antigua a la más nueva
+func (am *AsistenciaModelo) ObtenerApelacionesPorSesion(sesionID uuid.UUID) ([]Apelacion, error) {
+	var apelaciones []Apelacion
+	err := am.db.Preload("IntentoRechazado.Estudiante").Preload("IntentoRechazado.ReferenciaFacial").
+		Where("sesion_asistencia_id = ?", sesionID).
+		Order(fmt.Sprintf("estado = '%s' DESC", ApelacionPendiente)).Order("fecha_hora").
+		Find(&apelaciones).Error
+	return apelaciones, err
+}
+
+// RevisarApelacion concede o deniega una apelación pendiente de la sesión
+// Al concederla se crea la asistencia aceptada del intento, con la hora del intento para decidir la condición;
+// si el estudiante solo tenía la ausencia marcada al cerrar la sesión, esa ausencia se reemplaza
+func (am *AsistenciaModelo) RevisarApelacion(id, sesionID, docenteID uuid.UUID, dto *RevisarApelacionDto) (*Apelacion, error) {
+	var nuevoEstado string
+	switch dto.Decision {
+	case DecisionConceder:
+		nuevoEstado = ApelacionConcedida
+	case DecisionDenegar:
+		nuevoEstado = ApelacionDenegada
+	default:
+		return nil, fmt.Errorf("decisión inválida: %s", dto.Decision)
+	}
+
+	reglas, err := am.sesionModelo.ObtenerReglasValidacion(sesionID)
+	if err != nil {
+		return nil, fmt.Errorf("sesión no encontrada")
+	}
+
+	var apelacion Apelacion
+	err = am.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("IntentoRechazado").Where("id = ? AND sesion_asistencia_id = ?", id, sesionID).
+			First(&apelacion).Error; err != nil {
+			return fmt.Errorf("apelación no encontrada")
+		}
+		if apelacion.Estado != ApelacionPendiente {
+			return fmt.Errorf("la apelación ya fue revisada")
+		}
+
+		if nuevoEstado == ApelacionConcedida {
+			if err := am.crearAsistenciaApelada(tx, &apelacion, reglas); err != nil {
+				return err
+			}
+		}
+
+		ahora := time.Now()
+		apelacion.Estado, apelacion.RevisadaEn, apelacion.DocenteID = nuevoEstado, &ahora, &docenteID
+		apelacion.Comentario = strings.TrimSpace(dto.Comentario)
+		return tx.Model(&apelacion).Updates(map[string]interface{}{
+			"estado":      apelacion.Estado,
+			"revisada_en": apelacion.RevisadaEn,
+			"docente_id":  apelacion.DocenteID,
+			"comentario":  apelacion.Comentario,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &apelacion, nil
+}
+
+// crearAsistenciaApelada crea la asistencia del intento de una apelación concedida
+func (am *AsistenciaModelo) crearAsistenciaApelada(tx *gorm.DB, apelacion *Apelacion, reglas ReglasValidacionSesion) error {
+	intento := apelacion.IntentoRechazado
+
+	// La ausencia que se marcó al cerrar la sesión era solo un marcador; cualquier otro registro vigente gana
+	if err := tx.Where("estudiante_id = ? AND sesion_asistencia_id = ? AND estado = ? AND condicion = ?",
+		intento.EstudianteID, intento.SesionAsistenciaID, EstadoSinVerificacion, CondicionAusente).
+		Delete(&Asistencia{}).Error; err != nil {
+		return err
+	}
+	var existentes int64
+	if err := tx.Model(&Asistencia{}).Where("estudiante_id = ? AND sesion_asistencia_id = ? AND estado <> ?",
+		intento.EstudianteID, intento.SesionAsistenciaID, EstadoRechazada).Count(&existentes).Error; err != nil {
+		return err
+	}
+	if existentes > 0 {
+		return ErrAsistenciaExistente
+	}
+
+	// Las huellas no se calcularon al rechazarla; sin ellas la foto no se detectaría si se reutiliza
+	hashExacto, err := helper.HashExactoImagen(intento.FotoVerificacion)
+	if err != nil {
+		return fmt.Errorf("error al calcular huella de la foto: %v", err)
+	}
+	hashPerceptual, err := helper.HashPerceptualImagen(intento.FotoVerificacion)
+	if err != nil {
+		return fmt.Errorf("error al calcular huella de la foto: %v", err)
+	}
+
+	asistencia := &Asistencia{
+		ID:                          uuid.New(),
+		FechaHora:                   intento.FechaHora,
+		FotoVerificacion:            intento.FotoVerificacion,
+		Similitud:                   intento.Similitud,
+		Estado:                      EstadoAceptada,
+		Condicion:                   reglas.CondicionLlegada(intento.FechaHora),
+		HashExacto:                  hashExacto,
+		HashPerceptual:              int64(hashPerceptual),
+		CaracteristicasVerificacion: intento.CaracteristicasVerificacion,
+		Algoritmo:                   intento.Algoritmo,
+		Version:                     intento.Version,
+		UmbralRevision:              &intento.UmbralRevision,
+		DesgloseSimilitud:           intento.DesgloseSimilitud,
+		EstudianteID:                intento.EstudianteID,
+		SesionAsistenciaID:          intento.SesionAsistenciaID,
+		ReferenciaFacialID:          intento.ReferenciaFacialID,
+		ApelacionID:                 &apelacion.ID,
+	}
+	return tx.Create(asistencia).Error
+}
+
+// PurgarIntentosRechazados aplica la retención de los intentos rechazados anteriores a antesDe: los que no se
+// apelaron se eliminan y los de apelaciones ya revisadas pierden la foto y sus características, conservando
+// la decisión. Los de apelaciones pendientes se conservan completos hasta que el docente las revise
+func (am *AsistenciaModelo) PurgarIntentosRechazados(antesDe time.Time) (int, error) {
+	apelados := am.db.Model(&Apelacion{}).Select("intento_rechazado_id")
+	eliminados := am.db.Where("fecha_hora < ? AND id NOT IN (?)", antesDe, apelados).Delete(&IntentoRechazado{})
+	if eliminados.Error != nil {
+		return 0, eliminados.Error
+	}
+
+	revisados := am.db.Model(&Apelacion{}).Select("intento_rechazado_id").Where("estado <> ?", ApelacionPendiente)
+	vaciados := am.db.Model(&IntentoRechazado{}).
+		Where("fecha_hora < ? AND foto_verificacion <> '' AND id IN (?)", antesDe, revisados).
+		Updates(map[string]interface{}{"foto_verificacion": "", "caracteristicas_verificacion": nil})
+	if vaciados.Error != nil {
+		return 0, vaciados.Error
+	}
+	return int(eliminados.RowsAffected + vaciados.RowsAffected), nil
+}
+
+// ProgramarPurgaIntentosRechazados aplica la retención de los intentos rechazados al iniciar y luego cada hora
+func ProgramarPurgaIntentosRechazados(asistencias AsistenciaInterfaz, retencion time.Duration) {
+	for {
+		purgados, err := asistencias.PurgarIntentosRechazados(time.Now().Add(-retencion))
+		if err != nil {
+			log.Printf("Error al purgar intentos rechazados: %v", err)
+		} else if purgados > 0 {
+			log.Printf("Purgados %d intento(s) rechazado(s) con más de %v", purgados, retencion)
+		}
+		time.Sleep(time.Hour)
+	}
+}
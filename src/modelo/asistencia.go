@@ -1,63 +1,185 @@
 package modelo
 
 import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
 	"time"
 
+	"github.com/MetaDandy/Assistense-System/helper"
 	"github.com/MetaDandy/Assistense-System/src/modelo/cadena_responsabilidad"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// Estados de una asistencia según la banda de similitud y la revisión del docente
+const (
+	EstadoAceptada          = "aceptada"
+	EstadoPendienteRevision = "pendiente_revision"
+	EstadoRechazada         = "rechazada"
+	EstadoSinVerificacion   = "sin_verificacion" // Registros sin foto, como las ausencias marcadas al cerrar la sesión
+	EstadoManual            = "manual"           // La condición la decidió el docente (cámara rota, lesión, etc.)
+)
+
+// Condición del estudiante en la sesión. El estado dice si la verificación facial se aceptó;
+// la condición, si el estudiante asistió y cómo
+const (
+	CondicionPresente          = "presente"
+	CondicionTarde             = "tarde"
+	CondicionAusente           = "ausente"
+	CondicionJustificado       = "justificado"
+	CondicionPendienteRevision = "pendiente_revision"
+)
+
+// Condiciones en el orden en que se muestran y filtran
+var Condiciones = []string{CondicionPresente, CondicionTarde, CondicionAusente, CondicionJustificado, CondicionPendienteRevision}
+
+// UmbralesSimilitud es el alias de los umbrales de decisión de la cadena de validadores
+type UmbralesSimilitud = cadena_responsabilidad.UmbralesSimilitud
+
 type Asistencia struct {
 	ID               uuid.UUID `gorm:"type:uuid;primaryKey;"`
-	FechaHora        string    `gorm:"type:varchar(50);not null"`
+	FechaHora        time.Time `gorm:"type:timestamptz;not null;index:idx_asistencias_estudiante_fecha,priority:2"` // Hora de llegada
 	FotoVerificacion string    `gorm:"type:text"`
 	Similitud        float64   `gorm:"type:decimal(5,4)"`
+	Estado           string    `gorm:"type:varchar(20);not null;default:'aceptada';index"`
+	Condicion        string    `gorm:"type:varchar(20);index"` // Ver Condiciones; se calcula al registrar, vacía en las rechazadas
+	HashExacto       string    `gorm:"type:varchar(64);index"` // SHA-256 de la foto de verificación
+	HashPerceptual   int64     // dHash de la foto de verificación, para detectar fotos reutilizadas
+
+	// Características de la foto de verificación y el matcher que las generó
+	// Algoritmo y Version tienen el mismo largo que en CaracteristicasReferencia: los informa el backend
+	CaracteristicasVerificacion []byte `gorm:"type:bytea"`
+	Algoritmo                   string `gorm:"type:varchar(100)"`
+	Version                     string `gorm:"type:varchar(50)"`
+
+	// Explicación de la decisión: umbrales vigentes al registrarla y componentes de la similitud (JSON)
+	// Vacíos en las asistencias registradas antes de guardarlos
+	UmbralAceptacion  *float64 `gorm:"type:decimal(5,4)"`
+	UmbralRevision    *float64 `gorm:"type:decimal(5,4)"`
+	DesgloseSimilitud string   `gorm:"type:text"`
+
+	// Motivos por los que los metadatos de la foto no parecen de la sesión, uno por línea
+	// Solo en sesiones con la validación de metadatos en modo advertencia
+	AdvertenciasMetadatos string `gorm:"type:text"`
 
-	EstudianteID       uuid.UUID `gorm:"type:uuid;not null"`
-	SesionAsistenciaID uuid.UUID `gorm:"type:uuid;not null"`
+	EstudianteID       uuid.UUID  `gorm:"type:uuid;not null;index:idx_asistencias_estudiante_fecha,priority:1"`
+	SesionAsistenciaID uuid.UUID  `gorm:"type:uuid;not null"`
+	ReferenciaFacialID *uuid.UUID `gorm:"type:uuid"` // Referencia que mejor coincidió
+	ApelacionID        *uuid.UUID `gorm:"type:uuid"` // Apelación concedida que creó la asistencia
 
-	Estudiante       Estudiante       `gorm:"foreignKey:EstudianteID"`
-	SesionAsistencia SesionAsistencia `gorm:"foreignKey:SesionAsistenciaID"`
+	Estudiante       Estudiante        `gorm:"foreignKey:EstudianteID"`
+	SesionAsistencia SesionAsistencia  `gorm:"foreignKey:SesionAsistenciaID"`
+	ReferenciaFacial *ReferenciaFacial `gorm:"foreignKey:ReferenciaFacialID"`
+}
+
+// Desglose devuelve los componentes de la similitud guardados con la asistencia (nil si no hay)
+func (a *Asistencia) Desglose() []helper.ComponenteSimilitud {
+	return leerDesglose(a.DesgloseSimilitud)
+}
+
+// leerDesglose decodifica el JSON de los componentes de la similitud; uno vacío o inválido da nil
+func leerDesglose(desglose string) []helper.ComponenteSimilitud {
+	var componentes []helper.ComponenteSimilitud
+	if desglose == "" || json.Unmarshal([]byte(desglose), &componentes) != nil {
+		return nil
+	}
+	return componentes
 }
 
 type RegistrarAsistenciaDto struct {
 	FotoVerificacion   string    `json:"foto_verificacion" binding:"required"`
+	Fotogramas         []string  `json:"fotogramas"` // Fotogramas del desafío de vivacidad, después de FotoVerificacion
+	DesafioID          uuid.UUID `json:"desafio_id"` // Obligatorio salvo con la vivacidad opcional o desactivada
 	Similitud          float64   `json:"similitud"`
 	EstudianteID       uuid.UUID `json:"estudiante_id" binding:"required"`
 	SesionAsistenciaID uuid.UUID `json:"sesion_asistencia_id" binding:"required"`
+
+	// El estado de la sesión exige que el docente apruebe el registro (gracia después del fin); no viene del cliente
+	RequiereAprobacion bool `json:"-"`
 }
 
 type AsistenciaInterfaz interface {
 	RegistrarAsistencia(dto *RegistrarAsistenciaDto) (*Asistencia, error)
+	IdentificarYRegistrarAsistencia(dto *IdentificarAsistenciaDto) (*ResultadoIdentificacion, error)
+	ObtenerAsistencia(id uuid.UUID) (*Asistencia, error)
 	ObtenerAsistenciasPorSesion(sesionID uuid.UUID) ([]Asistencia, error)
 	VerificarAsistenciaExistente(estudianteID, sesionID uuid.UUID) (bool, error)
+	ObtenerIntentosFotoRepetida(sesionID uuid.UUID) ([]IntentoFotoRepetida, error)
+	ObtenerIntentoFotoRepetida(id uuid.UUID) (*IntentoFotoRepetida, error)
+	MarcarIntentoFotoRepetidaRevisado(id uuid.UUID) error
+	ObtenerSospechasPorSesion(sesionID uuid.UUID) ([]SospechaSuplantacion, error)
+	CrearDesafioVivacidad(estudianteID, sesionID uuid.UUID) (*DesafioVivacidad, error)
+	ObtenerAsistenciasPendientes(sesionID uuid.UUID) ([]Asistencia, error)
+	RevisarAsistencias(sesionID, docenteID uuid.UUID, dto *RevisarAsistenciasDto) (int, error)
+	CrearAsistenciaManual(sesionID, docenteID uuid.UUID, dto *AjusteAsistenciaDto) (*Asistencia, error)
+	ModificarAsistenciaManual(asistenciaID, docenteID uuid.UUID, dto *AjusteAsistenciaDto) (*Asistencia, error)
+	EliminarAsistenciaManual(asistenciaID, docenteID uuid.UUID, dto *AjusteAsistenciaDto) error
+	ObtenerAjustesPorSesion(sesionID uuid.UUID) ([]AjusteAsistencia, error)
+	ApelarIntento(intentoID uuid.UUID, dto *ApelarIntentoDto) (*Apelacion, error)
+	PurgarIntentosRechazados(antesDe time.Time) (int, error)
+	ObtenerApelacionesPorSesion(sesionID uuid.UUID) ([]Apelacion, error)
+	RevisarApelacion(id, sesionID, docenteID uuid.UUID, dto *RevisarApelacionDto) (*Apelacion, error)
+	MarcarAusencias(sesionID uuid.UUID) (int, error)
+	MarcarAusenciasSesionesTerminadas() (int, error)
 }
 
 type AsistenciaModelo struct {
-	db               *gorm.DB
-	estudianteModelo EstudianteModeloInterfaz
-	sesionModelo     SesionAsistenciaInterfaz
+	db                   *gorm.DB
+	matcher              helper.FaceMatcher
+	politica             PoliticaReferenciasAdaptativas
+	margenIdentificacion float64
+	modoVivacidad        string
+	estudianteModelo     EstudianteModeloInterfaz
+	sesionModelo         SesionAsistenciaInterfaz
 }
 
-func NuevoAsistenciaModelo(db *gorm.DB, estudianteModelo EstudianteModeloInterfaz, sesionModelo SesionAsistenciaInterfaz) AsistenciaInterfaz {
+// Los umbrales de similitud y el modo de metadatos los resuelve sesionModelo en cada asistencia:
+// cada sesión puede tener los suyos. margenIdentificacion es la ventaja mínima del primer estudiante
+// sobre el segundo para registrar una asistencia identificada 1:N, y modoVivacidad (ModoVivacidad*) qué
+// hacer con los registros sin desafío de vivacidad
+func NuevoAsistenciaModelo(db *gorm.DB, matcher helper.FaceMatcher, politica PoliticaReferenciasAdaptativas, margenIdentificacion float64, modoVivacidad string, estudianteModelo EstudianteModeloInterfaz, sesionModelo SesionAsistenciaInterfaz) AsistenciaInterfaz {
 	return &AsistenciaModelo{
-		db:               db,
-		estudianteModelo: estudianteModelo,
-		sesionModelo:     sesionModelo,
+		db:                   db,
+		matcher:              matcher,
+		politica:             politica,
+		margenIdentificacion: margenIdentificacion,
+		modoVivacidad:        modoVivacidad,
+		estudianteModelo:     estudianteModelo,
+		sesionModelo:         sesionModelo,
 	}
 }
 
 func (am *AsistenciaModelo) RegistrarAsistencia(dto *RegistrarAsistenciaDto) (*Asistencia, error) {
+	// Antes de validar la foto: un estudiante que no está inscrito no registra asistencia en la sesión
+	inscrito, err := am.sesionModelo.EstaInscrito(dto.SesionAsistenciaID, dto.EstudianteID)
+	if err != nil {
+		return nil, err
+	}
+	if !inscrito {
+		return nil, ErrEstudianteNoInscrito
+	}
+	if err := am.verificarIntentosRechazados(dto.EstudianteID, dto.SesionAsistenciaID); err != nil {
+		return nil, err
+	}
+
 	// Crear la solicitud que viajará por la cadena de validadores
 	solicitud := &cadena_responsabilidad.SolicitudAsistencia{
 		FotoVerificacion: dto.FotoVerificacion,
 		SesionID:         dto.SesionAsistenciaID,
 		EstudianteID:     dto.EstudianteID,
+		DesafioID:        dto.DesafioID,
+		Fotogramas:       dto.Fotogramas,
 	}
 
+	// Umbrales, modo de metadatos y horario de la sesión. Si no se encuentra se usan los de la institución:
+	// el registro falla después al guardar la asistencia de una sesión inexistente
+	reglas, _ := am.sesionModelo.ObtenerReglasValidacion(dto.SesionAsistenciaID)
+	umbrales := reglas.Umbrales
+
 	// Construir la cadena de validadores
-	primerValidador := am.construirCadenaValidadores()
+	primerValidador := am.construirCadenaValidadores(reglas)
 
 	// Validar usando la cadena de responsabilidad
 	// Iniciar la cadena desde el primer validador (ValidadorImagen)
@@ -66,26 +188,82 @@ func (am *AsistenciaModelo) RegistrarAsistencia(dto *RegistrarAsistenciaDto) (*A
 	}
 
 	// Si todas las validaciones pasaron, registrar la asistencia
+	// En la banda de revisión, o si la sesión lo exige, queda pendiente hasta que el docente la apruebe o rechace
+	// La condición (presente o tarde) se decide con la hora de llegada; si requiere revisión, al aprobarla
+	llegada := time.Now()
+	estado := EstadoAceptada
+	condicion := reglas.CondicionLlegada(llegada)
+	if solicitud.RequiereRevision || dto.RequiereAprobacion {
+		estado = EstadoPendienteRevision
+		condicion = CondicionPendienteRevision
+	}
+
 	asistencia := &Asistencia{
 		ID:                 uuid.New(),
-		FechaHora:          time.Now().Format("2006-01-02 15:04:05"),
+		FechaHora:          llegada,
 		FotoVerificacion:   dto.FotoVerificacion,
 		Similitud:          solicitud.Similitud,
+		Estado:             estado,
+		Condicion:          condicion,
+		HashExacto:         solicitud.HashExacto,
+		HashPerceptual:     int64(solicitud.HashPerceptual),
 		EstudianteID:       dto.EstudianteID,
 		SesionAsistenciaID: dto.SesionAsistenciaID,
+		ReferenciaFacialID: &solicitud.ReferenciaID,
+
+		CaracteristicasVerificacion: solicitud.CaracteristicasVerificacion,
+		Algoritmo:                   am.matcher.Algoritmo(),
+		Version:                     am.matcher.Version(),
+
+		UmbralAceptacion: &umbrales.Aceptacion,
+		UmbralRevision:   &umbrales.Revision,
+	}
+	if len(solicitud.Desglose) > 0 {
+		desglose, err := json.Marshal(solicitud.Desglose)
+		if err != nil {
+			return nil, err
+		}
+		asistencia.DesgloseSimilitud = string(desglose)
+	}
+	if len(solicitud.AdvertenciasMetadatos) > 0 {
+		asistencia.AdvertenciasMetadatos = strings.Join(solicitud.AdvertenciasMetadatos, "\n")
 	}
 
 	if err := am.db.Create(asistencia).Error; err != nil {
 		return nil, err
 	}
 
+	// La asistencia ya es válida: la detección de suplantación solo deja sospechas para el docente,
+	// así que corre en segundo plano, sin demorar la respuesta, y un error no la revierte
+	go am.revisarAsistenciaAceptada(asistencia, umbrales)
+
 	return asistencia, nil
 }
 
+// revisarAsistenciaAceptada busca suplantaciones en una asistencia recién registrada y, si no hay dudas, suma su
+// foto a las referencias del estudiante. Corre fuera de la solicitud: sus errores solo se registran
+func (am *AsistenciaModelo) revisarAsistenciaAceptada(asistencia *Asistencia, umbrales UmbralesSimilitud) {
+	sospechosa, err := am.detectarSuplantacion(asistencia)
+	if err != nil {
+		log.Printf("Error al detectar suplantación en la asistencia %s: %v", asistencia.ID, err)
+	}
+
+	// Una foto claramente por encima del umbral, aceptada sin dudas, se suma a las referencias del estudiante
+	// para seguir sus cambios de apariencia. Nunca si es sospechosa o si la detección falló
+	if am.politica.Habilitada && err == nil && !sospechosa && asistencia.Estado == EstadoAceptada &&
+		asistencia.Similitud >= umbrales.Aceptacion+am.politica.MargenSimilitud {
+		if _, err := am.estudianteModelo.AgregarReferenciaAutomatica(asistencia.EstudianteID, asistencia.ID,
+			asistencia.FotoVerificacion, asistencia.Similitud, am.politica); err != nil {
+			log.Printf("Error al agregar referencia automática desde la asistencia %s: %v", asistencia.ID, err)
+		}
+	}
+}
+
 // construirCadenaValidadores construye la cadena de responsabilidad con los validadores
-// Sigue el patrón: Validador → ValidadorImagen → ValidadorUUID → ValidadorEstudiante →
-// ValidadorFotoReferencia → ValidadorSimilitud → ValidadorDuplicado
-func (am *AsistenciaModelo) construirCadenaValidadores() cadena_responsabilidad.Validador {
+// Sigue el patrón: Validador → ValidadorImagen → ValidadorUUID → ValidadorEstudiante → ValidadorMetadatos →
+// ValidadorVivacidad → ValidadorFotoReferencia → ValidadorSimilitud → ValidadorDuplicado → ValidadorRepeticion
+// Recibe los umbrales de similitud, el modo de metadatos y el horario de la sesión
+func (am *AsistenciaModelo) construirCadenaValidadores(reglas ReglasValidacionSesion) cadena_responsabilidad.Validador {
 	// Definir callbacks para evitar ciclos de importación
 
 	// Callback para verificar existencia del estudiante
@@ -97,13 +275,20 @@ func (am *AsistenciaModelo) construirCadenaValidadores() cadena_responsabilidad.
 		return "", nil
 	}
 
-	// Callback para obtener foto de referencia
-	callbackFotoRef := func(estudianteID uuid.UUID) (string, error) {
-		estudiante, err := am.estudianteModelo.ObtenerEstudiantePorID(estudianteID)
+	// Callback para obtener las características precalculadas de la foto de referencia
+	callbackCaracteristicas := func(estudianteID uuid.UUID) ([]cadena_responsabilidad.ReferenciaCaracteristicas, error) {
+		registros, err := am.estudianteModelo.ObtenerCaracteristicasReferencia(estudianteID)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		return estudiante.FotoReferencia, nil
+		referencias := make([]cadena_responsabilidad.ReferenciaCaracteristicas, len(registros))
+		for i, r := range registros {
+			referencias[i] = cadena_responsabilidad.ReferenciaCaracteristicas{
+				ReferenciaID:    r.ReferenciaFacialID,
+				Caracteristicas: r.Datos,
+			}
+		}
+		return referencias, nil
 	}
 
 	// Callback para verificar asistencia duplicada
@@ -115,30 +300,151 @@ func (am *AsistenciaModelo) construirCadenaValidadores() cadena_responsabilidad.
 	v1 := cadena_responsabilidad.NewValidadorImagen()
 	v2 := cadena_responsabilidad.NewValidadorUUID()
 	v3 := cadena_responsabilidad.NewValidadorEstudiante(callbackEstudiante)
-	v4 := cadena_responsabilidad.NewValidadorFotoReferencia(callbackFotoRef)
-	v5 := cadena_responsabilidad.NewValidadorSimilitud(callbackFotoRef)
-	v6 := cadena_responsabilidad.NewValidadorDuplicado(callbackDuplicado)
+	v4 := cadena_responsabilidad.NewValidadorMetadatos(reglas.ModoMetadatos, reglas.Ventana)
+	v5 := cadena_responsabilidad.NewValidadorVivacidad(am.modoVivacidad, am.consumirDesafio)
+	v6 := cadena_responsabilidad.NewValidadorFotoReferencia(callbackCaracteristicas)
+	v7 := cadena_responsabilidad.NewValidadorSimilitud(am.matcher, reglas.Umbrales, callbackCaracteristicas, am.registrarIntentoRechazado)
+	v8 := cadena_responsabilidad.NewValidadorDuplicado(callbackDuplicado)
+	v9 := cadena_responsabilidad.NewValidadorRepeticion(am.buscarFotoRepetida, am.registrarIntentoFotoRepetida)
 
-	// Encadenar los validadores: v1 → v2 → v3 → v4 → v5 → v6
+	// Encadenar los validadores: v1 → v2 → v3 → v4 → v5 → v6 → v7 → v8 → v9
+	// Los metadatos se revisan primero porque es lo más barato: no consumen el desafío de vivacidad
+	// La vivacidad va antes de comparar rostros: una foto impresa no debe llegar al matcher
+	// La repetición va después del duplicado para que reenviar la misma foto en la misma sesión
+	// (doble clic, reintento) se informe como duplicado y no quede registrado como sospechoso
 	// SetSiguiente retorna el siguiente, permitiendo encadenamiento fluido
 	v1.SetSiguiente(v2)
 	v2.SetSiguiente(v3)
 	v3.SetSiguiente(v4)
 	v4.SetSiguiente(v5)
 	v5.SetSiguiente(v6)
+	v6.SetSiguiente(v7)
+	v7.SetSiguiente(v8)
+	v8.SetSiguiente(v9)
 
 	// Retornar el primer manejador de la cadena
 	return v1
 }
 
+// ObtenerAsistencia devuelve una asistencia con su estudiante y su sesión
+func (am *AsistenciaModelo) ObtenerAsistencia(id uuid.UUID) (*Asistencia, error) {
+	var asistencia Asistencia
+	if err := am.db.Preload("Estudiante").Preload("SesionAsistencia").Where("id = ?", id).First(&asistencia).Error; err != nil {
+		return nil, err
+	}
+	return &asistencia, nil
+}
+
+// ObtenerAsistenciasPorSesion devuelve las asistencias vigentes de la sesión: sin las rechazadas por el docente
 func (am *AsistenciaModelo) ObtenerAsistenciasPorSesion(sesionID uuid.UUID) ([]Asistencia, error) {
 	var asistencias []Asistencia
-	err := am.db.Preload("Estudiante").Where("sesion_asistencia_id = ?", sesionID).Find(&asistencias).Error
+	err := am.db.Preload("Estudiante").Where("sesion_asistencia_id = ? AND estado <> ?", sesionID, EstadoRechazada).
+		Find(&asistencias).Error
 	return asistencias, err
 }
 
+// EsCondicionValida indica si condicion es una de Condiciones
+func EsCondicionValida(condicion string) bool {
+	for _, c := range Condiciones {
+		if c == condicion {
+			return true
+		}
+	}
+	return false
+}
+
+// VerificarAsistenciaExistente indica si el estudiante ya tiene una asistencia aceptada o pendiente en la sesión
+// Una rechazada por el docente no cuenta: el estudiante puede volver a intentarlo
 func (am *AsistenciaModelo) VerificarAsistenciaExistente(estudianteID, sesionID uuid.UUID) (bool, error) {
 	var count int64
-	err := am.db.Model(&Asistencia{}).Where("estudiante_id = ? AND sesion_asistencia_id = ?", estudianteID, sesionID).Count(&count).Error
+	err := am.db.Model(&Asistencia{}).Where("estudiante_id = ? AND sesion_asistencia_id = ? AND estado <> ?", estudianteID, sesionID, EstadoRechazada).Count(&count).Error
 	return count > 0, err
 }
+
+// MigrarCondicionAsistencias calcula la condición de las asistencias registradas antes de que existiera
+// Las aceptadas son presentes o tardes según la tolerancia de su sesión (o toleranciaPorDefecto, en minutos)
+// Las rechazadas no tienen condición; se les quita la de ausente que tenían antes
+func MigrarCondicionAsistencias(db *gorm.DB, toleranciaPorDefecto int) (int, error) {
+	if err := db.Model(&Asistencia{}).Where("estado = ? AND condicion <> ''", EstadoRechazada).
+		Update("condicion", "").Error; err != nil {
+		return 0, err
+	}
+
+	var asistencias []Asistencia
+	if err := db.Preload("SesionAsistencia").Select("id", "fecha_hora", "estado", "sesion_asistencia_id").
+		Where("(condicion IS NULL OR condicion = '') AND estado <> ?", EstadoRechazada).Find(&asistencias).Error; err != nil {
+		return 0, err
+	}
+
+	for i, a := range asistencias {
+		condicion := CondicionPresente
+		switch a.Estado {
+		case EstadoPendienteRevision:
+			condicion = CondicionPendienteRevision
+		default:
+			if !a.SesionAsistencia.Inicio.IsZero() {
+				limite := a.SesionAsistencia.Inicio.Add(time.Duration(a.SesionAsistencia.ToleranciaTardanzaVigente(toleranciaPorDefecto)) * time.Minute)
+				condicion = ReglasValidacionSesion{LimiteTardanza: limite}.CondicionLlegada(a.FechaHora)
+			}
+		}
+		if err := db.Model(&Asistencia{}).Where("id = ?", a.ID).Update("condicion", condicion).Error; err != nil {
+			return i, err
+		}
+	}
+
+	return len(asistencias), nil
+}
+
+// MigrarFechaHoraAsistencias pasa la hora de llegada de las asistencias anteriores, guardada como texto en la
+// hora del servidor (time.Local, la que usaba time.Now al escribirla), a un instante. Corre antes de
+// AutoMigrate, que convertiría el texto a timestamptz en UTC, la zona de la conexión
+func MigrarFechaHoraAsistencias(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasTable(&Asistencia{}) {
+		return 0, nil
+	}
+	var tipo string
+	if err := db.Raw(`SELECT data_type FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'asistencias' AND column_name = 'fecha_hora'`).
+		Scan(&tipo).Error; err != nil {
+		return 0, err
+	}
+	if tipo == "" || tipo == "timestamp with time zone" {
+		return 0, nil
+	}
+
+	var llegadas []struct {
+		ID        uuid.UUID
+		FechaHora string
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE asistencias ADD COLUMN IF NOT EXISTS fecha_hora_zona timestamptz").Error; err != nil {
+			return err
+		}
+		if err := tx.Table("asistencias").Select("id", "fecha_hora").Find(&llegadas).Error; err != nil {
+			return err
+		}
+		for _, l := range llegadas {
+			llegada, err := time.ParseInLocation("2006-01-02 15:04:05", l.FechaHora, time.Local)
+			if err != nil {
+				return fmt.Errorf("asistencia %s: fecha y hora inválida: %q", l.ID, l.FechaHora)
+			}
+			if err := tx.Table("asistencias").Where("id = ?", l.ID).Update("fecha_hora_zona", llegada).Error; err != nil {
+				return err
+			}
+		}
+		for _, sentencia := range []string{
+			"ALTER TABLE asistencias DROP COLUMN fecha_hora",
+			"ALTER TABLE asistencias RENAME COLUMN fecha_hora_zona TO fecha_hora",
+			"ALTER TABLE asistencias ALTER COLUMN fecha_hora SET NOT NULL",
+		} {
+			if err := tx.Exec(sentencia).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(llegadas), nil
+}
@@ -0,0 +1,134 @@
+package modelo
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Errores al marcar ausencias que el controlador distingue
+var (
+	ErrSesionNoFinalizada = errors.New("la sesión todavía no terminó")
+	ErrSesionCancelada    = errors.New("la sesión fue cancelada")
+)
+
+// MarcarAusencias crea una asistencia ausente para cada estudiante inscrito sin asistencia en la sesión ya cerrada
+// (justificada si tiene una justificación aprobada para la sesión)
+// Es idempotente: los estudiantes que ya tienen un registro (de cualquier condición) no se tocan, y la
+// sesión queda bloqueada mientras tanto para que dos ejecuciones simultáneas no dupliquen ausencias
+// Devuelve cuántas ausencias creó
+func (am *AsistenciaModelo) MarcarAusencias(sesionID uuid.UUID) (int, error) {
+	// Al leerla se aplica la transición de estado que corresponda por su horario
+	if _, err := am.sesionModelo.ObtenerSesionAsistencia(sesionID); err != nil {
+		return 0, fmt.Errorf("sesión no encontrada")
+	}
+
+	creadas := 0
+	err := am.db.Transaction(func(tx *gorm.DB) error {
+		var sesion SesionAsistencia
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", sesionID).First(&sesion).Error; err != nil {
+			return fmt.Errorf("sesión no encontrada")
+		}
+		if sesion.Estado == EstadoSesionCancelada {
+			return ErrSesionCancelada
+		}
+		if sesion.Estado != EstadoSesionCerrada {
+			return ErrSesionNoFinalizada
+		}
+		var faltantes []uuid.UUID
+		if err := tx.Table("sesion_estudiantes").Where("sesion_asistencia_id = ?", sesionID).
+			Where("estudiante_id NOT IN (?)", tx.Model(&Asistencia{}).Select("estudiante_id").
+				Where("sesion_asistencia_id = ? AND estado <> ?", sesionID, EstadoRechazada)).
+			Pluck("estudiante_id", &faltantes).Error; err != nil {
+			return err
+		}
+
+		// Los que tienen una justificación aprobada para la sesión quedan justificados
+		var justificados []uuid.UUID
+		if err := tx.Model(&Justificacion{}).
+			Where("estado = ? AND id IN (?)", JustificacionAprobada,
+				tx.Table("justificacion_sesiones").Select("justificacion_id").Where("sesion_asistencia_id = ?", sesionID)).
+			Pluck("estudiante_id", &justificados).Error; err != nil {
+			return err
+		}
+		conJustificacion := make(map[uuid.UUID]bool, len(justificados))
+		for _, id := range justificados {
+			conJustificacion[id] = true
+		}
+
+		if len(faltantes) > 0 {
+			ausencias := make([]Asistencia, len(faltantes))
+			for i, estudianteID := range faltantes {
+				condicion := CondicionAusente
+				if conJustificacion[estudianteID] {
+					condicion = CondicionJustificado
+				}
+				ausencias[i] = Asistencia{
+					ID:                 uuid.New(),
+					FechaHora:          sesion.Fin,
+					Estado:             EstadoSinVerificacion,
+					Condicion:          condicion,
+					EstudianteID:       estudianteID,
+					SesionAsistenciaID: sesionID,
+				}
+			}
+			if err := tx.Create(&ausencias).Error; err != nil {
+				return err
+			}
+		}
+
+		ahora := time.Now()
+		if err := tx.Model(&sesion).Update("ausencias_marcadas_en", &ahora).Error; err != nil {
+			return err
+		}
+		creadas = len(faltantes)
+		return nil
+	})
+	return creadas, err
+}
+
+// MarcarAusenciasSesionesTerminadas marca las ausencias de las sesiones cerradas que todavía no se procesaron
+// Antes aplica las transiciones automáticas (ProgramarEstadosSesiones también lo hace, con su propio intervalo),
+// para marcar en esta misma pasada las sesiones que acaban de cerrarse
+// Una sesión que falla no detiene a las demás: se reintenta en la siguiente pasada
+func (am *AsistenciaModelo) MarcarAusenciasSesionesTerminadas() (int, error) {
+	if err := am.sesionModelo.ActualizarEstadosSesiones(); err != nil {
+		return 0, err
+	}
+
+	var sesiones []SesionAsistencia
+	if err := am.db.Where("ausencias_marcadas_en IS NULL AND estado = ?", EstadoSesionCerrada).
+		Find(&sesiones).Error; err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, sesion := range sesiones {
+		creadas, err := am.MarcarAusencias(sesion.ID)
+		if err != nil {
+			log.Printf("Error al marcar ausencias de la sesión %s: %v", sesion.ID, err)
+			continue
+		}
+		total += creadas
+	}
+	return total, nil
+}
+
+// ProgramarMarcadoAusencias marca las ausencias de las sesiones cerradas al iniciar y luego cada intervalo
+// El estado vive en la base (AusenciasMarcadasEn), así que tras un reinicio retoma las sesiones pendientes
+func ProgramarMarcadoAusencias(asistencias AsistenciaInterfaz, intervalo time.Duration) {
+	for {
+		creadas, err := asistencias.MarcarAusenciasSesionesTerminadas()
+		if err != nil {
+			log.Printf("Error al buscar sesiones terminadas para marcar ausencias: %v", err)
+		} else if creadas > 0 {
+			log.Printf("Marcadas %d ausencia(s) de sesiones terminadas", creadas)
+		}
+		time.Sleep(intervalo)
+	}
+}
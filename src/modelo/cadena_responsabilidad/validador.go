@@ -1,15 +1,135 @@
 package cadena_responsabilidad
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MetaDandy/Assistense-System/helper"
 	"github.com/google/uuid"
 )
 
+// Errores que el controlador distingue para responder con el código HTTP adecuado
+var (
+	ErrRostroNoCoincide    = errors.New("rostro no coincide")
+	ErrAsistenciaDuplicada = errors.New("ya existe asistencia registrada para esta sesión")
+	ErrSinFotoReferencia   = errors.New("estudiante no tiene foto de referencia registrada")
+	ErrFotoRepetida        = errors.New("la foto de verificación ya fue usada en una asistencia anterior")
+	ErrDesafioInvalido     = errors.New("desafío de vivacidad inexistente, vencido o ya usado")
+	ErrMetadatosFoto       = errors.New("los metadatos de la foto indican que no es una captura de esta sesión")
+)
+
+// Modos de ValidadorMetadatos, configurables por sesión
+const (
+	ModoMetadatosEstricto    = "estricto"    // Rechaza la foto; si no tiene fecha de captura la deja pendiente de revisión
+	ModoMetadatosAdvertencia = "advertencia" // La acepta y deja los motivos con la asistencia
+	ModoMetadatosDesactivado = "desactivado"
+)
+
+// Modos de ValidadorVivacidad, de la institución
+const (
+	ModoVivacidadObligatoria = "obligatoria" // Sin desafío se rechaza
+	ModoVivacidadOpcional    = "opcional"    // Sin desafío queda pendiente de revisión del docente (clientes anteriores)
+	ModoVivacidadDesactivada = "desactivada"
+)
+
 // SolicitudAsistencia es el objeto que viaja a través de la cadena de validadores
 type SolicitudAsistencia struct {
 	FotoVerificacion string
 	SesionID         uuid.UUID
 	EstudianteID     uuid.UUID
 	Similitud        float64
+
+	// DesafioID es el nonce del desafío de vivacidad; Fotogramas son los capturados al hacer la acción,
+	// en orden y después de FotoVerificacion (que es la posición inicial)
+	DesafioID  uuid.UUID
+	Fotogramas []string
+
+	// ReferenciaID es la referencia facial que mejor coincidió (la fija ValidadorSimilitud)
+	ReferenciaID uuid.UUID
+
+	// RequiereRevision indica que el docente debe decidir la asistencia: la similitud quedó en la banda de
+	// revisión manual (ValidadorSimilitud) o llegó sin prueba de vivacidad en modo opcional (ValidadorVivacidad)
+	RequiereRevision bool
+
+	// Desglose son los componentes de la similitud con la referencia ganadora (lo fija ValidadorSimilitud
+	// si el matcher sabe desglosar), para explicar la decisión al docente
+	Desglose []helper.ComponenteSimilitud
+
+	// CaracteristicasVerificacion son las de la foto de verificación (las fija ValidadorSimilitud)
+	// Se guardan con la asistencia para compararla luego con las demás de la sesión
+	CaracteristicasVerificacion []byte
+
+	// Referencias las carga ValidadorFotoReferencia para que los siguientes no vuelvan a consultarlas
+	Referencias []ReferenciaCaracteristicas
+
+	// AdvertenciasMetadatos son los problemas de metadatos que ValidadorMetadatos dejó pasar en modo advertencia,
+	// o la falta de fecha de captura en modo estricto
+	AdvertenciasMetadatos []string
+
+	// Huellas de la foto de verificación (las fija ValidadorRepeticion) para guardarlas con la asistencia
+	HashExacto     string
+	HashPerceptual uint64
+}
+
+// ErrorSimilitud detalla un rechazo por similitud insuficiente; errors.Is lo reconoce como ErrRostroNoCoincide
+type ErrorSimilitud struct {
+	Similitud float64
+	Umbral    float64
+	Desglose  []helper.ComponenteSimilitud
+	IntentoID uuid.UUID // Intento rechazado guardado, que el estudiante puede apelar
+	// TokenApelacion es el secreto que el estudiante presenta al apelar el intento; solo se entrega aquí
+	TokenApelacion string
+}
+
+func (e *ErrorSimilitud) Error() string {
+	return fmt.Sprintf("%v (similitud: %.2f%% < %.2f%% requerido)", ErrRostroNoCoincide, e.Similitud*100, e.Umbral*100)
+}
+
+func (e *ErrorSimilitud) Unwrap() error {
+	return ErrRostroNoCoincide
+}
+
+// ErrorMetadatos detalla por qué ValidadorMetadatos rechazó la foto; errors.Is lo reconoce como ErrMetadatosFoto
+type ErrorMetadatos struct {
+	Motivos []string
+}
+
+func (e *ErrorMetadatos) Error() string {
+	return fmt.Sprintf("%v: %s", ErrMetadatosFoto, strings.Join(e.Motivos, "; "))
+}
+
+func (e *ErrorMetadatos) Unwrap() error {
+	return ErrMetadatosFoto
+}
+
+// VentanaSesion es el intervalo en que se dicta la sesión; las fotos deben haberse tomado dentro
+type VentanaSesion struct {
+	Inicio time.Time
+	Fin    time.Time
+	Zona   *time.Location // Zona horaria de la sesión, para las fotos cuyo EXIF no indica la suya
+}
+
+// FotoRepetida describe la asistencia anterior cuya foto coincide con la enviada
+type FotoRepetida struct {
+	AsistenciaID uuid.UUID
+	Exacta       bool // Mismo archivo byte a byte
+	Distancia    int  // Bits distintos entre los hashes perceptuales (0 si es exacta)
+}
+
+// UmbralesSimilitud definen las tres bandas de decisión de ValidadorSimilitud:
+// desde Aceptacion se acepta, desde Revision queda pendiente del docente y por debajo se rechaza
+type UmbralesSimilitud struct {
+	Aceptacion float64
+	Revision   float64
+}
+
+// ReferenciaCaracteristicas son las características de una de las fotos de referencia del estudiante
+// Son opacas: las genera y compara el FaceMatcher configurado
+type ReferenciaCaracteristicas struct {
+	ReferenciaID    uuid.UUID
+	Caracteristicas []byte
 }
 
 // Validador es la interfaz que define el contrato para todos los validadores
@@ -28,8 +148,24 @@ type Validador interface {
 // CallbackVerificarEstudiante verifica si un estudiante existe por su ID
 type CallbackVerificarEstudiante func(estudianteID uuid.UUID) (fotoReferencia string, err error)
 
-// CallbackObtenerFotoReferencia obtiene la foto de referencia de un estudiante
-type CallbackObtenerFotoReferencia func(estudianteID uuid.UUID) (foto string, err error)
+// CallbackObtenerCaracteristicas obtiene las características precalculadas de las fotos de referencia
+// Devuelve una lista vacía si el estudiante no tiene fotos de referencia
+type CallbackObtenerCaracteristicas func(estudianteID uuid.UUID) (referencias []ReferenciaCaracteristicas, err error)
 
 // CallbackVerificarDuplicado verifica si ya existe asistencia registrada
 type CallbackVerificarDuplicado func(estudianteID uuid.UUID, sesionID uuid.UUID) (existe bool, err error)
+
+// CallbackBuscarFotoRepetida busca una asistencia anterior con la misma foto (de cualquier estudiante y sesión)
+// o una del mismo estudiante con un hash perceptual a distancia menor o igual a distanciaMaxima. Devuelve nil si no hay
+type CallbackBuscarFotoRepetida func(estudianteID uuid.UUID, hashExacto string, hashPerceptual uint64, distanciaMaxima int) (repetida *FotoRepetida, err error)
+
+// CallbackRegistrarFotoRepetida guarda el intento rechazado para que el docente lo revise
+type CallbackRegistrarFotoRepetida func(solicitud *SolicitudAsistencia, repetida *FotoRepetida) error
+
+// CallbackRegistrarRechazoSimilitud guarda el intento rechazado por similitud para que el estudiante pueda apelarlo
+// La solicitud ya trae la similitud, el desglose, la referencia y las características de la comparación
+type CallbackRegistrarRechazoSimilitud func(solicitud *SolicitudAsistencia, umbral float64) (intentoID uuid.UUID, tokenApelacion string, err error)
+
+// CallbackConsumirDesafio marca el desafío como usado y devuelve la acción pedida
+// Falla con ErrDesafioInvalido si no existe, venció, ya se usó o es de otro estudiante o sesión
+type CallbackConsumirDesafio func(desafioID, estudianteID, sesionID uuid.UUID) (accion string, err error)
@@ -36,7 +36,7 @@ func (v *ValidadorDuplicado) Validar(solicitud *SolicitudAsistencia) error {
 			return fmt.Errorf("error al verificar asistencia duplicada: %v", err)
 		}
 		if existe {
-			return fmt.Errorf("ya existe asistencia registrada para esta sesión")
+			return ErrAsistenciaDuplicada
 		}
 	}
 
@@ -4,17 +4,17 @@ import (
 	"fmt"
 )
 
-// ValidadorFotoReferencia valida que el estudiante tenga una foto de referencia registrada
+// ValidadorFotoReferencia valida que el estudiante tenga al menos una foto de referencia registrada
 type ValidadorFotoReferencia struct {
-	siguiente   Validador
-	obtenerFoto CallbackObtenerFotoReferencia
+	siguiente              Validador
+	obtenerCaracteristicas CallbackObtenerCaracteristicas
 }
 
 // NewValidadorFotoReferencia crea una nueva instancia de ValidadorFotoReferencia
-// Recibe un callback para obtener la foto de referencia del estudiante
-func NewValidadorFotoReferencia(callback CallbackObtenerFotoReferencia) *ValidadorFotoReferencia {
+// Recibe un callback para obtener las características precalculadas de la foto de referencia
+func NewValidadorFotoReferencia(callback CallbackObtenerCaracteristicas) *ValidadorFotoReferencia {
 	return &ValidadorFotoReferencia{
-		obtenerFoto: callback,
+		obtenerCaracteristicas: callback,
 	}
 }
 
@@ -25,15 +25,17 @@ func (v *ValidadorFotoReferencia) SetSiguiente(validador Validador) Validador {
 }
 
 // Validar implementa la validación de foto de referencia
-// Verifica que exista una foto de referencia, luego delega al siguiente validador
+// Verifica que existan fotos de referencia y deja sus características en la solicitud,
+// luego delega al siguiente validador
 func (v *ValidadorFotoReferencia) Validar(solicitud *SolicitudAsistencia) error {
-	foto, err := v.obtenerFoto(solicitud.EstudianteID)
+	referencias, err := v.obtenerCaracteristicas(solicitud.EstudianteID)
 	if err != nil {
 		return fmt.Errorf("error al obtener foto de referencia: %v", err)
 	}
-	if foto == "" {
-		return fmt.Errorf("estudiante no tiene foto de referencia registrada")
+	if len(referencias) == 0 {
+		return ErrSinFotoReferencia
 	}
+	solicitud.Referencias = referencias
 
 	// Validación exitosa, pasar al siguiente validador
 	if v.siguiente != nil {
@@ -29,7 +29,7 @@ func (v *ValidadorImagen) SetSiguiente(validador Validador) Validador {
 // Si la imagen es válida, delega al siguiente validador
 func (v *ValidadorImagen) Validar(solicitud *SolicitudAsistencia) error {
 	if err := helper.ValidarImagenBase64(solicitud.FotoVerificacion); err != nil {
-		return fmt.Errorf("imagen base64 inválida: %v", err)
+		return fmt.Errorf("imagen base64 inválida: %w", err)
 	}
 
 	// Validación exitosa, pasar al siguiente validador
@@ -0,0 +1,100 @@
+package cadena_responsabilidad
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+)
+
+// toleranciaRelojCamara admite fotos tomadas un poco antes o después de la sesión por relojes desfasados
+const toleranciaRelojCamara = 5 * time.Minute
+
+// ValidadorMetadatos revisa los metadatos EXIF de la foto de verificación para descartar fotos viejas,
+// editadas o capturas de pantalla. Las fotos sin fecha de captura (las que arma el navegador desde la
+// cámara, o cualquier foto a la que se le borraron los metadatos) no se pueden juzgar: en modo estricto
+// quedan pendientes de revisión del docente en lugar de pasar como aceptadas
+type ValidadorMetadatos struct {
+	siguiente Validador
+	modo      string
+	ventana   VentanaSesion
+}
+
+// NewValidadorMetadatos crea una nueva instancia de ValidadorMetadatos
+// Recibe el modo de la sesión (estricto, advertencia o desactivado) y su horario
+func NewValidadorMetadatos(modo string, ventana VentanaSesion) *ValidadorMetadatos {
+	return &ValidadorMetadatos{
+		modo:    modo,
+		ventana: ventana,
+	}
+}
+
+// SetSiguiente establece el siguiente validador en la cadena
+func (v *ValidadorMetadatos) SetSiguiente(validador Validador) Validador {
+	v.siguiente = validador
+	return validador
+}
+
+// Validar junta los motivos por los que la foto no parece tomada en la sesión
+// En modo estricto corta la cadena con ErrorMetadatos; en modo advertencia los deja en la solicitud y delega
+func (v *ValidadorMetadatos) Validar(solicitud *SolicitudAsistencia) error {
+	if v.modo != ModoMetadatosDesactivado {
+		metadatos, err := helper.LeerMetadatosFoto(solicitud.FotoVerificacion)
+		if err != nil {
+			return fmt.Errorf("error al leer metadatos de la foto: %v", err)
+		}
+
+		motivos := v.evaluar(metadatos)
+		if len(motivos) > 0 {
+			if v.modo == ModoMetadatosEstricto {
+				return &ErrorMetadatos{Motivos: motivos}
+			}
+			solicitud.AdvertenciasMetadatos = motivos
+		}
+
+		// Sin fecha no hay cómo saber si la foto es de la sesión: en modo estricto la decide el docente
+		if v.modo == ModoMetadatosEstricto && metadatos.FechaCaptura == nil {
+			solicitud.AdvertenciasMetadatos = append(solicitud.AdvertenciasMetadatos, "la foto no tiene fecha de captura en sus metadatos")
+			solicitud.RequiereRevision = true
+		}
+	}
+
+	// Validación exitosa, pasar al siguiente validador
+	if v.siguiente != nil {
+		return v.siguiente.Validar(solicitud)
+	}
+
+	// Fin de la cadena
+	return nil
+}
+
+// fechaCaptura devuelve la fecha de captura en la zona de la sesión
+// Si el EXIF no indica la zona, la hora del reloj de la cámara se toma como hora de la sesión
+func (v *ValidadorMetadatos) fechaCaptura(metadatos *helper.MetadatosFoto) *time.Time {
+	fecha := metadatos.FechaCaptura
+	if fecha == nil || v.ventana.Zona == nil {
+		return fecha
+	}
+	enZona := fecha.In(v.ventana.Zona)
+	if metadatos.FechaSinZona {
+		enZona = time.Date(fecha.Year(), fecha.Month(), fecha.Day(), fecha.Hour(), fecha.Minute(), fecha.Second(), 0, v.ventana.Zona)
+	}
+	return &enZona
+}
+
+func (v *ValidadorMetadatos) evaluar(metadatos *helper.MetadatosFoto) []string {
+	var motivos []string
+
+	if fecha := v.fechaCaptura(metadatos); fecha != nil &&
+		(fecha.Before(v.ventana.Inicio.Add(-toleranciaRelojCamara)) || fecha.After(v.ventana.Fin.Add(toleranciaRelojCamara))) {
+		motivos = append(motivos, fmt.Sprintf("la foto se tomó el %s, fuera del horario de la sesión", fecha.Format("2006-01-02 15:04")))
+	}
+	if software := metadatos.SoftwareEdicion(); software != "" {
+		motivos = append(motivos, fmt.Sprintf("la foto fue editada con %s", software))
+	}
+	if metadatos.EsCapturaPantalla() {
+		motivos = append(motivos, fmt.Sprintf("la foto parece una captura de pantalla (%dx%d)", metadatos.Ancho, metadatos.Alto))
+	}
+
+	return motivos
+}
@@ -0,0 +1,67 @@
+package cadena_responsabilidad
+
+import (
+	"fmt"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+)
+
+// ValidadorRepeticion rechaza fotos de verificación que ya se usaron en otra asistencia
+// Detecta el mismo archivo (hash exacto) y también la misma foto recomprimida o redimensionada (hash perceptual)
+type ValidadorRepeticion struct {
+	siguiente           Validador
+	distanciaMaxima     int
+	buscarRepetida      CallbackBuscarFotoRepetida
+	registrarRepeticion CallbackRegistrarFotoRepetida
+}
+
+// NewValidadorRepeticion crea una nueva instancia de ValidadorRepeticion
+// Recibe un callback para buscar fotos anteriores por sus hashes y otro para registrar los intentos rechazados
+func NewValidadorRepeticion(buscar CallbackBuscarFotoRepetida, registrar CallbackRegistrarFotoRepetida) *ValidadorRepeticion {
+	return &ValidadorRepeticion{
+		distanciaMaxima:     4,
+		buscarRepetida:      buscar,
+		registrarRepeticion: registrar,
+	}
+}
+
+// SetSiguiente establece el siguiente validador en la cadena
+func (v *ValidadorRepeticion) SetSiguiente(validador Validador) Validador {
+	v.siguiente = validador
+	return validador
+}
+
+// Validar calcula las huellas de la foto de verificación y las busca entre las asistencias anteriores
+// Si la foto ya se usó, registra el intento y corta la cadena; si no, deja las huellas en la solicitud
+func (v *ValidadorRepeticion) Validar(solicitud *SolicitudAsistencia) error {
+	hashExacto, err := helper.HashExactoImagen(solicitud.FotoVerificacion)
+	if err != nil {
+		return fmt.Errorf("error al calcular huella de la foto: %v", err)
+	}
+	hashPerceptual, err := helper.HashPerceptualImagen(solicitud.FotoVerificacion)
+	if err != nil {
+		return fmt.Errorf("error al calcular huella de la foto: %v", err)
+	}
+
+	solicitud.HashExacto = hashExacto
+	solicitud.HashPerceptual = hashPerceptual
+
+	repetida, err := v.buscarRepetida(solicitud.EstudianteID, hashExacto, hashPerceptual, v.distanciaMaxima)
+	if err != nil {
+		return fmt.Errorf("error al buscar fotos repetidas: %v", err)
+	}
+	if repetida != nil {
+		if err := v.registrarRepeticion(solicitud, repetida); err != nil {
+			return fmt.Errorf("error al registrar foto repetida: %v", err)
+		}
+		return fmt.Errorf("%w (asistencia %s)", ErrFotoRepetida, repetida.AsistenciaID)
+	}
+
+	// Validación exitosa, pasar al siguiente validador
+	if v.siguiente != nil {
+		return v.siguiente.Validar(solicitud)
+	}
+
+	// Fin de la cadena
+	return nil
+}
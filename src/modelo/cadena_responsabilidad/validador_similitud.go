@@ -6,20 +6,26 @@ import (
 	"github.com/MetaDandy/Assistense-System/helper"
 )
 
-// ValidadorSimilitud valida la similitud entre rostros usando comparación de histogramas
+// ValidadorSimilitud valida la similitud entre rostros usando el FaceMatcher configurado
+// Decide en tres bandas: aceptada, pendiente de revisión del docente o rechazada
 type ValidadorSimilitud struct {
-	siguiente    Validador
-	similitudMin float64
-	obtenerFoto  CallbackObtenerFotoReferencia
+	siguiente              Validador
+	umbrales               UmbralesSimilitud
+	matcher                helper.FaceMatcher
+	obtenerCaracteristicas CallbackObtenerCaracteristicas
+	registrarRechazo       CallbackRegistrarRechazoSimilitud
 }
 
 // NewValidadorSimilitud crea una nueva instancia de ValidadorSimilitud
-// Recibe un callback para obtener la foto de referencia
-// La comparación de rostros se realiza directamente con el helper
-func NewValidadorSimilitud(callbackFoto CallbackObtenerFotoReferencia) *ValidadorSimilitud {
+// Recibe el matcher que compara los rostros, los umbrales de las bandas y un callback para obtener
+// las características de referencia cuando no vienen en la solicitud, y otro para guardar los intentos
+// rechazados (si es nil no se guardan)
+func NewValidadorSimilitud(matcher helper.FaceMatcher, umbrales UmbralesSimilitud, callback CallbackObtenerCaracteristicas, registrarRechazo CallbackRegistrarRechazoSimilitud) *ValidadorSimilitud {
 	return &ValidadorSimilitud{
-		similitudMin: 0.6,
-		obtenerFoto:  callbackFoto,
+		umbrales:               umbrales,
+		matcher:                matcher,
+		obtenerCaracteristicas: callback,
+		registrarRechazo:       registrarRechazo,
 	}
 }
 
@@ -30,25 +36,73 @@ func (v *ValidadorSimilitud) SetSiguiente(validador Validador) Validador {
 }
 
 // Validar implementa la validación de similitud de rostro
-// Compara la foto de referencia con la foto de verificación usando el helper, luego delega al siguiente
+// Compara la foto de verificación con todas las referencias del estudiante usando el matcher
+// y se queda con la mejor. Rechaza por debajo del umbral de revisión; entre ese y el de aceptación
+// marca la solicitud para revisión manual y, como cuando se acepta, delega al siguiente
 func (v *ValidadorSimilitud) Validar(solicitud *SolicitudAsistencia) error {
-	fotoReferencia, err := v.obtenerFoto(solicitud.EstudianteID)
-	if err != nil {
-		return fmt.Errorf("error al obtener foto de referencia para validación: %v", err)
+	referencias := solicitud.Referencias
+	if len(referencias) == 0 {
+		var err error
+		referencias, err = v.obtenerCaracteristicas(solicitud.EstudianteID)
+		if err != nil {
+			return fmt.Errorf("error al obtener foto de referencia para validación: %v", err)
+		}
+		if len(referencias) == 0 {
+			return ErrSinFotoReferencia
+		}
 	}
 
-	// Comparar rostros directamente con el helper
-	_, similitud, err := helper.CompararRostros(fotoReferencia, solicitud.FotoVerificacion)
+	// Solo la foto de verificación se decodifica en cada asistencia
+	// Si la foto no tiene exactamente un rostro, el error lo indica (helper.ErrSinRostro / helper.ErrVariosRostros)
+	actual, err := v.matcher.ExtraerCaracteristicas(solicitud.FotoVerificacion)
 	if err != nil {
-		return fmt.Errorf("error al comparar rostros: %v", err)
+		return fmt.Errorf("error al comparar rostros: %w", err)
+	}
+
+	// Mejor de N: basta con que una de las referencias coincida
+	similitud := -1.0
+	var mejor ReferenciaCaracteristicas
+	for _, ref := range referencias {
+		s, err := v.matcher.Comparar(ref.Caracteristicas, actual)
+		if err != nil {
+			return fmt.Errorf("error al comparar rostros: %v", err)
+		}
+		if s > similitud {
+			similitud = s
+			mejor = ref
+		}
 	}
 
-	if similitud < v.similitudMin {
-		return fmt.Errorf("rostro no coincide (similitud: %.2f%% < %.2f%% requerido)", similitud*100, v.similitudMin*100)
+	// Desglose de la comparación ganadora, para explicar la decisión tanto si pasa como si no
+	var desglose []helper.ComponenteSimilitud
+	if desglosable, ok := v.matcher.(helper.MatcherDesglosable); ok {
+		desglose, err = desglosable.DesglosarComparacion(mejor.Caracteristicas, actual)
+		if err != nil {
+			return fmt.Errorf("error al desglosar la similitud: %v", err)
+		}
 	}
 
-	// Almacenar la similitud en la solicitud para uso posterior
+	// Almacenar la similitud y la referencia ganadora en la solicitud para uso posterior
 	solicitud.Similitud = similitud
+	solicitud.Desglose = desglose
+	solicitud.ReferenciaID = mejor.ReferenciaID
+	solicitud.CaracteristicasVerificacion = actual
+
+	// Por debajo del umbral de revisión se rechaza, pero el intento queda guardado para que se pueda apelar
+	if similitud < v.umbrales.Revision {
+		rechazo := &ErrorSimilitud{Similitud: similitud, Umbral: v.umbrales.Revision, Desglose: desglose}
+		if v.registrarRechazo != nil {
+			intentoID, token, err := v.registrarRechazo(solicitud, v.umbrales.Revision)
+			if err != nil {
+				return fmt.Errorf("error al registrar intento rechazado: %v", err)
+			}
+			rechazo.IntentoID, rechazo.TokenApelacion = intentoID, token
+		}
+		return rechazo
+	}
+
+	// Banda de la similitud: entre el umbral de revisión y el de aceptación la decide el docente
+	solicitud.RequiereRevision = solicitud.RequiereRevision || similitud < v.umbrales.Aceptacion
 
 	// Validación exitosa, pasar al siguiente validador
 	if v.siguiente != nil {
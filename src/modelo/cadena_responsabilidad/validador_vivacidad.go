@@ -0,0 +1,65 @@
+package cadena_responsabilidad
+
+import (
+	"fmt"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+	"github.com/google/uuid"
+)
+
+// ValidadorVivacidad pide que la foto venga de alguien frente a la cámara haciendo una acción al azar
+// El servidor emite un desafío (nonce + acción) y el cliente envía varios fotogramas haciendo esa acción
+// Es una señal débil: solo mide cuánto se corre o crece el recuadro del rostro entre fotogramas, así que una
+// foto impresa o una pantalla movida frente a la cámara también pasa. Sirve para frenar el envío de una foto
+// guardada y ligar los fotogramas a un desafío de un solo uso; no reemplaza la revisión del docente
+type ValidadorVivacidad struct {
+	siguiente       Validador
+	modo            string
+	consumirDesafio CallbackConsumirDesafio
+}
+
+// NewValidadorVivacidad crea una nueva instancia de ValidadorVivacidad
+// Recibe el modo (ModoVivacidad*) y un callback que consume el desafío (un solo uso) y devuelve la acción pedida
+func NewValidadorVivacidad(modo string, callback CallbackConsumirDesafio) *ValidadorVivacidad {
+	return &ValidadorVivacidad{
+		modo:            modo,
+		consumirDesafio: callback,
+	}
+}
+
+// SetSiguiente establece el siguiente validador en la cadena
+func (v *ValidadorVivacidad) SetSiguiente(validador Validador) Validador {
+	v.siguiente = validador
+	return validador
+}
+
+// Validar consume el desafío y verifica que los fotogramas muestren el movimiento pedido
+// El desafío se consume aunque el movimiento falle: cada intento necesita un desafío nuevo
+// Un desafío enviado siempre se verifica; sin desafío, el modo decide si se rechaza o queda para revisión
+func (v *ValidadorVivacidad) Validar(solicitud *SolicitudAsistencia) error {
+	switch {
+	case v.modo == ModoVivacidadDesactivada:
+	case solicitud.DesafioID == uuid.Nil && v.modo == ModoVivacidadOpcional:
+		solicitud.RequiereRevision = true
+	case solicitud.DesafioID == uuid.Nil:
+		return fmt.Errorf("%w: falta el desafío", ErrDesafioInvalido)
+	default:
+		accion, err := v.consumirDesafio(solicitud.DesafioID, solicitud.EstudianteID, solicitud.SesionID)
+		if err != nil {
+			return err
+		}
+
+		fotogramas := append([]string{solicitud.FotoVerificacion}, solicitud.Fotogramas...)
+		if err := helper.VerificarMovimiento(accion, fotogramas); err != nil {
+			return fmt.Errorf("prueba de vivacidad fallida: %w", err)
+		}
+	}
+
+	// Validación exitosa, pasar al siguiente validador
+	if v.siguiente != nil {
+		return v.siguiente.Validar(solicitud)
+	}
+
+	// Fin de la cadena
+	return nil
+}
@@ -2,6 +2,8 @@ package modelo
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/MetaDandy/Assistense-System/helper"
 	"github.com/google/uuid"
@@ -15,6 +17,10 @@ type Docente struct {
 	Apellidos  string    `gorm:"type:varchar(100);not null"`
 	Contraseña string    `gorm:"not null"`
 
+	// Zona horaria en que el docente programa sus sesiones (nombre IANA, por ejemplo America/La_Paz)
+	// Si está vacía se usa la de la institución (ZONA_HORARIA)
+	ZonaHoraria string `gorm:"type:varchar(64)"`
+
 	Sesiones []SesionAsistencia `gorm:"foreignKey:DocenteID"`
 }
 
@@ -24,6 +30,25 @@ type RegistrarDocenteDto struct {
 	Apellidos           string `json:"apellidos" binding:"required"`
 	Contraseña          string `json:"contraseña" binding:"required,min=6"`
 	ConfirmarContraseña string `json:"confirmar_contraseña,omitempty"` // Solo para formularios HTML
+	ZonaHoraria         string `json:"zona_horaria"`                   // Opcional; vacía usa la de la institución
+}
+
+// ActualizarZonaHorariaDto cambia la zona horaria del docente; vacía vuelve a la de la institución
+// Las sesiones ya programadas conservan la zona en que se crearon
+type ActualizarZonaHorariaDto struct {
+	ZonaHoraria string `json:"zona_horaria"`
+}
+
+// ZonaHorariaVigente devuelve la zona horaria del docente o, si no tiene (o ya no es válida), la de la institución
+func (d *Docente) ZonaHorariaVigente(porDefecto *time.Location) *time.Location {
+	if d.ZonaHoraria == "" {
+		return porDefecto
+	}
+	zona, err := CargarZonaHoraria(d.ZonaHoraria)
+	if err != nil {
+		return porDefecto
+	}
+	return zona
 }
 
 type IniciarSesionDto struct {
@@ -39,6 +64,7 @@ type DocenteModeloInterfaz interface {
 	RegistrarDocente(docente *RegistrarDocenteDto) (*Docente, string, error)
 	IniciarSesion(inicio IniciarSesionDto) (*Docente, string, error)
 	ObtenerDocentePorID(id uuid.UUID) (*Docente, error)
+	ActualizarZonaHoraria(id uuid.UUID, dto *ActualizarZonaHorariaDto) (*Docente, error)
 }
 
 func NuevoDocenteModelo(db *gorm.DB) DocenteModeloInterfaz {
@@ -52,17 +78,25 @@ func (dm *DocenteModelo) RegistrarDocente(docente *RegistrarDocenteDto) (*Docent
 		return nil, "", fmt.Errorf("el correo ya está registrado")
 	}
 
+	zona := strings.TrimSpace(docente.ZonaHoraria)
+	if zona != "" {
+		if _, err := CargarZonaHoraria(zona); err != nil {
+			return nil, "", err
+		}
+	}
+
 	hash, err := helper.HashPassword(docente.Contraseña)
 	if err != nil {
 		return nil, "", fmt.Errorf("error al generar el hash de la contraseña")
 	}
 
 	nuevoDocente := Docente{
-		ID:         uuid.New(),
-		Correo:     docente.Correo,
-		Nombre:     docente.Nombre,
-		Apellidos:  docente.Apellidos,
-		Contraseña: hash,
+		ID:          uuid.New(),
+		Correo:      docente.Correo,
+		Nombre:      docente.Nombre,
+		Apellidos:   docente.Apellidos,
+		Contraseña:  hash,
+		ZonaHoraria: zona,
 	}
 
 	if err := dm.db.Create(&nuevoDocente).Error; err != nil {
@@ -105,3 +139,23 @@ func (dm *DocenteModelo) ObtenerDocentePorID(id uuid.UUID) (*Docente, error) {
 
 	return &docente, nil
 }
+
+// ActualizarZonaHoraria cambia la zona horaria en que el docente programa sus próximas sesiones
+func (dm *DocenteModelo) ActualizarZonaHoraria(id uuid.UUID, dto *ActualizarZonaHorariaDto) (*Docente, error) {
+	zona := strings.TrimSpace(dto.ZonaHoraria)
+	if zona != "" {
+		if _, err := CargarZonaHoraria(zona); err != nil {
+			return nil, err
+		}
+	}
+
+	docente, err := dm.ObtenerDocentePorID(id)
+	if err != nil {
+		return nil, err
+	}
+	docente.ZonaHoraria = zona
+	if err := dm.db.Model(docente).Select("zona_horaria").Updates(docente).Error; err != nil {
+		return nil, err
+	}
+	return docente, nil
+}
@@ -0,0 +1,287 @@
+package modelo
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Estados de una sesión de asistencia; se guardan en la sesión y cada cambio queda en TransicionSesion
+// Por su horario, la sesión pasa de programada a abierta, a tardanza (después de la tolerancia: las llegadas
+// son tarde), a gracia (después del fin: los registros esperan la aprobación del docente) y a cerrada
+const (
+	EstadoSesionProgramada = "programada"
+	EstadoSesionAbierta    = "abierta"
+	EstadoSesionTardanza   = "tardanza"
+	EstadoSesionSuspendida = "suspendida"
+	EstadoSesionGracia     = "gracia"
+	EstadoSesionCerrada    = "cerrada"
+	EstadoSesionCancelada  = "cancelada"
+)
+
+// Acciones del docente sobre el estado de una sesión
+const (
+	AccionSesionAbrir     = "abrir"
+	AccionSesionCerrar    = "cerrar"
+	AccionSesionSuspender = "suspender"
+	AccionSesionReanudar  = "reanudar"
+	AccionSesionCancelar  = "cancelar"
+)
+
+// intervaloEstadosSesiones es cada cuánto se aplican las transiciones automáticas de las sesiones; es corto
+// porque la tolerancia y la gracia se miden en minutos
+const intervaloEstadosSesiones = time.Minute
+
+// ErrTransicionInvalida indica que la acción no se puede hacer en el estado actual de la sesión
+var ErrTransicionInvalida = errors.New("la acción no está permitida en el estado actual de la sesión")
+
+// transicionesSesion es la tabla de transiciones: los estados a los que puede pasar cada estado
+// Cerrada y cancelada son finales; una programada puede saltar estados si nadie la consultó mientras duraban
+var transicionesSesion = map[string][]string{
+	EstadoSesionProgramada: {EstadoSesionAbierta, EstadoSesionTardanza, EstadoSesionGracia, EstadoSesionCerrada, EstadoSesionCancelada},
+	EstadoSesionAbierta:    {EstadoSesionTardanza, EstadoSesionGracia, EstadoSesionSuspendida, EstadoSesionCerrada},
+	EstadoSesionTardanza:   {EstadoSesionGracia, EstadoSesionSuspendida, EstadoSesionCerrada},
+	EstadoSesionSuspendida: {EstadoSesionAbierta, EstadoSesionCerrada},
+	EstadoSesionGracia:     {EstadoSesionCerrada},
+}
+
+// ordenHorario es el orden en que el horario lleva a la sesión de un estado al siguiente
+var ordenHorario = map[string]int{
+	EstadoSesionProgramada: 0,
+	EstadoSesionAbierta:    1,
+	EstadoSesionTardanza:   2,
+	EstadoSesionGracia:     3,
+	EstadoSesionCerrada:    4,
+}
+
+// accionSesion es el estado al que lleva una acción del docente y desde qué estados se puede hacer
+type accionSesion struct {
+	destino  string
+	origenes []string
+}
+
+// accionesSesion, en el orden en que se muestran
+var accionesSesion = []struct {
+	nombre string
+	accionSesion
+}{
+	{AccionSesionAbrir, accionSesion{EstadoSesionAbierta, []string{EstadoSesionProgramada}}},
+	{AccionSesionSuspender, accionSesion{EstadoSesionSuspendida, []string{EstadoSesionAbierta, EstadoSesionTardanza}}},
+	{AccionSesionReanudar, accionSesion{EstadoSesionAbierta, []string{EstadoSesionSuspendida}}},
+	{AccionSesionCerrar, accionSesion{EstadoSesionCerrada, []string{EstadoSesionAbierta, EstadoSesionTardanza, EstadoSesionSuspendida, EstadoSesionGracia}}},
+	{AccionSesionCancelar, accionSesion{EstadoSesionCancelada, []string{EstadoSesionProgramada}}},
+}
+
+// TransicionSesion registra cada cambio de estado de una sesión: quién lo hizo, cuándo y por qué
+// Las que hace el sistema por el horario no tienen docente ni acción
+type TransicionSesion struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	EstadoAnterior string    `gorm:"type:varchar(20);not null"`
+	EstadoNuevo    string    `gorm:"type:varchar(20);not null"`
+	Accion         string    `gorm:"type:varchar(20)"`
+	Motivo         string    `gorm:"type:varchar(255)"`
+	FechaHora      time.Time `gorm:"not null"`
+
+	SesionAsistenciaID uuid.UUID  `gorm:"type:uuid;not null;index"`
+	DocenteID          *uuid.UUID `gorm:"type:uuid"`
+
+	Docente *Docente `gorm:"foreignKey:DocenteID"`
+}
+
+type CambiarEstadoSesionDto struct {
+	Accion string `json:"accion" binding:"required"` // abrir | cerrar | suspender | reanudar | cancelar
+	Motivo string `json:"motivo"`
+}
+
+// PuedeTransicionar indica si la tabla de transiciones permite pasar de un estado a otro
+func PuedeTransicionar(desde, hacia string) bool {
+	for _, estado := range transicionesSesion[desde] {
+		if estado == hacia {
+			return true
+		}
+	}
+	return false
+}
+
+// AccionesDisponibles lista las acciones que el docente puede hacer sobre una sesión en ese estado
+func AccionesDisponibles(estado string) []string {
+	var acciones []string
+	for _, a := range accionesSesion {
+		for _, origen := range a.origenes {
+			if origen == estado {
+				acciones = append(acciones, a.nombre)
+			}
+		}
+	}
+	return acciones
+}
+
+// estadoSegunHorario devuelve el estado al que la sesión pasa sola por su horario, con la tolerancia y la gracia
+// de la institución si no tiene propias. Solo avanza: una sesión abierta antes de hora sigue abierta, y la
+// suspendida solo se cierra al llegar el fin, sin gracia. Las cerradas y canceladas no cambian
+func (s *SesionAsistencia) estadoSegunHorario(ahora time.Time, toleranciaPorDefecto, graciaPorDefecto int) string {
+	ventana := s.Ventana()
+	if s.Estado == EstadoSesionSuspendida {
+		if !ahora.Before(ventana.Fin) {
+			return EstadoSesionCerrada
+		}
+		return s.Estado
+	}
+	actual, sigueHorario := ordenHorario[s.Estado]
+	if !sigueHorario {
+		return s.Estado
+	}
+
+	limiteTardanza := ventana.Inicio.Add(time.Duration(s.ToleranciaTardanzaVigente(toleranciaPorDefecto)) * time.Minute)
+	finGracia := ventana.Fin.Add(time.Duration(s.GraciaCierreVigente(graciaPorDefecto)) * time.Minute)
+	porHorario := EstadoSesionCerrada
+	switch {
+	case ahora.Before(ventana.Inicio):
+		porHorario = EstadoSesionProgramada
+	case !ahora.After(limiteTardanza):
+		porHorario = EstadoSesionAbierta
+	case ahora.Before(ventana.Fin):
+		porHorario = EstadoSesionTardanza
+	case ahora.Before(finGracia):
+		porHorario = EstadoSesionGracia
+	}
+	if ordenHorario[porHorario] > actual {
+		return porHorario
+	}
+	return s.Estado
+}
+
+// sincronizarEstado aplica y registra la transición automática que corresponda por el horario
+// Solo cambia la sesión si sigue en el estado leído; si otro proceso ya la cambió, relee el estado
+func (sam *SesionAsistenciaModelo) sincronizarEstado(db *gorm.DB, sesion *SesionAsistencia, ahora time.Time) error {
+	nuevo := sesion.estadoSegunHorario(ahora, sam.toleranciaPorDefecto, sam.graciaPorDefecto)
+	if nuevo == sesion.Estado {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		resultado := tx.Model(&SesionAsistencia{}).Where("id = ? AND estado = ?", sesion.ID, sesion.Estado).Update("estado", nuevo)
+		if resultado.Error != nil {
+			return resultado.Error
+		}
+		if resultado.RowsAffected == 0 {
+			return tx.Model(&SesionAsistencia{}).Select("estado").Where("id = ?", sesion.ID).Row().Scan(&sesion.Estado)
+		}
+		anterior := sesion.Estado
+		sesion.Estado = nuevo
+		return tx.Create(nuevaTransicion(sesion.ID, nil, anterior, nuevo, "", "", ahora)).Error
+	})
+}
+
+// sincronizarEstados aplica las transiciones automáticas a las sesiones leídas; un error no impide leerlas
+func (sam *SesionAsistenciaModelo) sincronizarEstados(sesiones []SesionAsistencia) {
+	ahora := time.Now()
+	for i := range sesiones {
+		if err := sam.sincronizarEstado(sam.db, &sesiones[i], ahora); err != nil {
+			log.Printf("Error al actualizar el estado de la sesión %s: %v", sesiones[i].ID, err)
+		}
+	}
+}
+
+// CambiarEstado aplica la acción del docente sobre su sesión y la registra
+// Antes se aplica la transición automática pendiente, así una sesión que ya terminó no se puede reanudar;
+// después también, así una sesión reanudada pasada la tolerancia queda en tardanza
+func (sam *SesionAsistenciaModelo) CambiarEstado(id, docenteID uuid.UUID, dto *CambiarEstadoSesionDto) (*SesionAsistencia, error) {
+	var accion *accionSesion
+	for i := range accionesSesion {
+		if accionesSesion[i].nombre == dto.Accion {
+			accion = &accionesSesion[i].accionSesion
+		}
+	}
+	if accion == nil {
+		return nil, fmt.Errorf("acción inválida: %s", dto.Accion)
+	}
+	motivo := strings.TrimSpace(dto.Motivo)
+	if len(motivo) > 255 {
+		return nil, fmt.Errorf("el motivo no puede superar los 255 caracteres")
+	}
+
+	var sesion SesionAsistencia
+	err := sam.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND docente_id = ?", id, docenteID).
+			First(&sesion).Error; err != nil {
+			return fmt.Errorf("sesión no encontrada")
+		}
+		ahora := time.Now()
+		if err := sam.sincronizarEstado(tx, &sesion, ahora); err != nil {
+			return err
+		}
+
+		permitida := false
+		for _, origen := range accion.origenes {
+			permitida = permitida || origen == sesion.Estado
+		}
+		if !permitida || !PuedeTransicionar(sesion.Estado, accion.destino) {
+			return ErrTransicionInvalida
+		}
+
+		anterior := sesion.Estado
+		if err := tx.Model(&sesion).Update("estado", accion.destino).Error; err != nil {
+			return err
+		}
+		sesion.Estado = accion.destino
+		if err := tx.Create(nuevaTransicion(sesion.ID, &docenteID, anterior, accion.destino, dto.Accion, motivo, ahora)).Error; err != nil {
+			return err
+		}
+		return sam.sincronizarEstado(tx, &sesion, ahora)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sesion, nil
+}
+
+// ObtenerTransiciones lista los cambios de estado de la sesión, del más reciente al más antiguo
+func (sam *SesionAsistenciaModelo) ObtenerTransiciones(id uuid.UUID) ([]TransicionSesion, error) {
+	var transiciones []TransicionSesion
+	err := sam.db.Preload("Docente").Where("sesion_asistencia_id = ?", id).Order("fecha_hora DESC").Find(&transiciones).Error
+	return transiciones, err
+}
+
+// ActualizarEstadosSesiones aplica las transiciones automáticas a las sesiones que todavía pueden cambiar solas
+// Las lecturas también las aplican; esto mantiene al día las sesiones que nadie consulta
+func (sam *SesionAsistenciaModelo) ActualizarEstadosSesiones() error {
+	var sesiones []SesionAsistencia
+	if err := sam.db.Where("estado IN ? AND inicio <= ?",
+		[]string{EstadoSesionProgramada, EstadoSesionAbierta, EstadoSesionTardanza, EstadoSesionSuspendida, EstadoSesionGracia},
+		time.Now()).
+		Find(&sesiones).Error; err != nil {
+		return err
+	}
+	sam.sincronizarEstados(sesiones)
+	return nil
+}
+
+// ProgramarEstadosSesiones aplica las transiciones automáticas al iniciar y luego cada intervaloEstadosSesiones
+// Corre aparte del marcado de ausencias: INTERVALO_AUSENCIAS=0 no deja a las sesiones sin abrirse ni cerrarse
+func ProgramarEstadosSesiones(sesiones SesionAsistenciaInterfaz) {
+	for {
+		if err := sesiones.ActualizarEstadosSesiones(); err != nil {
+			log.Printf("Error al actualizar los estados de las sesiones: %v", err)
+		}
+		time.Sleep(intervaloEstadosSesiones)
+	}
+}
+
+func nuevaTransicion(sesionID uuid.UUID, docenteID *uuid.UUID, anterior, nuevo, accion, motivo string, fecha time.Time) *TransicionSesion {
+	return &TransicionSesion{
+		ID:                 uuid.New(),
+		EstadoAnterior:     anterior,
+		EstadoNuevo:        nuevo,
+		Accion:             accion,
+		Motivo:             motivo,
+		FechaHora:          fecha,
+		SesionAsistenciaID: sesionID,
+		DocenteID:          docenteID,
+	}
+}
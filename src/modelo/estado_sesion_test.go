@@ -0,0 +1,81 @@
+package modelo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstadoSegunHorario(t *testing.T) {
+	// Sesión de 10:00 a 12:00; la institución da 10 minutos de tolerancia y 10 de gracia
+	inicio := time.Date(2026, 3, 10, 10, 0, 0, 0, time.UTC)
+	fin := inicio.Add(2 * time.Hour)
+	minutos := func(m int) *int { return &m }
+
+	casos := []struct {
+		nombre     string
+		estado     string
+		tolerancia *int
+		gracia     *int
+		ahora      time.Time
+		esperado   string
+	}{
+		{nombre: "antes del inicio", estado: EstadoSesionProgramada, ahora: inicio.Add(-time.Minute), esperado: EstadoSesionProgramada},
+		{nombre: "al inicio", estado: EstadoSesionProgramada, ahora: inicio, esperado: EstadoSesionAbierta},
+		{nombre: "al final de la tolerancia", estado: EstadoSesionProgramada, ahora: inicio.Add(10 * time.Minute), esperado: EstadoSesionAbierta},
+		{nombre: "después de la tolerancia", estado: EstadoSesionAbierta, ahora: inicio.Add(11 * time.Minute), esperado: EstadoSesionTardanza},
+		{nombre: "sin tolerancia propia", estado: EstadoSesionAbierta, tolerancia: minutos(0), ahora: inicio.Add(time.Minute), esperado: EstadoSesionTardanza},
+		{nombre: "en la gracia", estado: EstadoSesionTardanza, ahora: fin.Add(5 * time.Minute), esperado: EstadoSesionGracia},
+		{nombre: "al terminar la gracia", estado: EstadoSesionTardanza, ahora: fin.Add(10 * time.Minute), esperado: EstadoSesionCerrada},
+		{nombre: "sin gracia propia", estado: EstadoSesionTardanza, gracia: minutos(0), ahora: fin, esperado: EstadoSesionCerrada},
+		{nombre: "programada que nadie consultó", estado: EstadoSesionProgramada, ahora: fin.Add(time.Hour), esperado: EstadoSesionCerrada},
+		{nombre: "abierta antes de hora sigue abierta", estado: EstadoSesionAbierta, ahora: inicio.Add(-30 * time.Minute), esperado: EstadoSesionAbierta},
+		{nombre: "no retrocede", estado: EstadoSesionGracia, ahora: inicio.Add(time.Minute), esperado: EstadoSesionGracia},
+		{nombre: "suspendida durante la sesión", estado: EstadoSesionSuspendida, ahora: inicio.Add(time.Hour), esperado: EstadoSesionSuspendida},
+		{nombre: "suspendida al fin, sin gracia", estado: EstadoSesionSuspendida, ahora: fin, esperado: EstadoSesionCerrada},
+		{nombre: "cerrada antes de hora", estado: EstadoSesionCerrada, ahora: inicio, esperado: EstadoSesionCerrada},
+		{nombre: "cancelada", estado: EstadoSesionCancelada, ahora: inicio.Add(time.Hour), esperado: EstadoSesionCancelada},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			sesion := &SesionAsistencia{
+				Inicio:             inicio,
+				Fin:                fin,
+				ZonaHoraria:        "UTC",
+				Estado:             c.estado,
+				ToleranciaTardanza: c.tolerancia,
+				GraciaCierre:       c.gracia,
+			}
+			got := sesion.estadoSegunHorario(c.ahora, 10, 10)
+			if got != c.esperado {
+				t.Errorf("estadoSegunHorario = %s, se esperaba %s", got, c.esperado)
+			}
+			if got != c.estado && !PuedeTransicionar(c.estado, got) {
+				t.Errorf("%s -> %s no está en la tabla de transiciones", c.estado, got)
+			}
+		})
+	}
+}
+
+func TestAccionesDisponibles(t *testing.T) {
+	casos := map[string][]string{
+		EstadoSesionProgramada: {AccionSesionAbrir, AccionSesionCancelar},
+		EstadoSesionAbierta:    {AccionSesionSuspender, AccionSesionCerrar},
+		EstadoSesionSuspendida: {AccionSesionReanudar, AccionSesionCerrar},
+		EstadoSesionGracia:     {AccionSesionCerrar},
+		EstadoSesionCerrada:    nil,
+		EstadoSesionCancelada:  nil,
+	}
+	for estado, esperadas := range casos {
+		acciones := AccionesDisponibles(estado)
+		if len(acciones) != len(esperadas) {
+			t.Errorf("%s: acciones %v, se esperaban %v", estado, acciones, esperadas)
+			continue
+		}
+		for i := range acciones {
+			if acciones[i] != esperadas[i] {
+				t.Errorf("%s: acciones %v, se esperaban %v", estado, acciones, esperadas)
+				break
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package modelo
 
 import (
+	"github.com/MetaDandy/Assistense-System/helper"
 	"github.com/MetaDandy/Assistense-System/src/modelo/template_method"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -20,19 +21,30 @@ type EstudianteModeloInterfaz interface {
 	ActualizarEstudiante(id uuid.UUID, estudiante *ActualizarEstudiante) (*Estudiante, error)
 	MostrarEstudiantes() ([]Estudiante, error)
 	ObtenerEstudiantePorID(id uuid.UUID) (*Estudiante, error)
+	AgregarReferenciaFacial(estudianteID uuid.UUID, dto *AgregarReferenciaFacialDto) (*ReferenciaFacial, error)
+	ObtenerReferenciasFaciales(estudianteID uuid.UUID) ([]ReferenciaFacial, error)
+	EliminarReferenciaFacial(estudianteID, referenciaID uuid.UUID) error
+	ObtenerCaracteristicasReferencia(estudianteID uuid.UUID) ([]CaracteristicasReferencia, error)
+	ObtenerCaracteristicasEstudiantes(estudianteIDs []uuid.UUID) ([]CaracteristicasReferencia, error)
+	RecalcularCaracteristicas(todos bool) (int, error)
+	AgregarReferenciaAutomatica(estudianteID, asistenciaID uuid.UUID, foto string, similitud float64, politica PoliticaReferenciasAdaptativas) (*ReferenciaFacial, error)
+	ObtenerActualizacionesReferencia(estudianteID uuid.UUID) ([]ActualizacionReferencia, error)
+	DescartarReferenciasVencidas(politica PoliticaReferenciasAdaptativas) (int, error)
+	RevertirActualizacionReferencia(estudianteID, actualizacionID, docenteID uuid.UUID) error
 }
 
 type EstudianteModelo struct {
-	db *gorm.DB
+	db      *gorm.DB
+	matcher helper.FaceMatcher
 }
 
-func NuevoEstudianteModelo(db *gorm.DB) EstudianteModeloInterfaz {
-	return &EstudianteModelo{db: db}
+func NuevoEstudianteModelo(db *gorm.DB, matcher helper.FaceMatcher) EstudianteModeloInterfaz {
+	return &EstudianteModelo{db: db, matcher: matcher}
 }
 
 func (em *EstudianteModelo) RegistrarEstudiante(datos *RegistrarEstudianteDto) (*Estudiante, error) {
 	// Crear el procesador específico de registrar
-	proc := template_method.NewProcesadorRegistrar(em.db, datos)
+	proc := template_method.NewProcesadorRegistrar(em.db, em.matcher, datos)
 
 	// Crear la plantilla base
 	base := template_method.NewProcesadorBase()
@@ -51,7 +63,7 @@ func (em *EstudianteModelo) ActualizarEstudiante(id uuid.UUID, datos *Actualizar
 	dtoTemplate := (*template_method.ActualizarEstudianteDto)(datos)
 
 	// Crear el procesador específico de actualizar
-	proc := template_method.NewProcesadorActualizar(em.db, id.String(), dtoTemplate)
+	proc := template_method.NewProcesadorActualizar(em.db, em.matcher, id.String(), dtoTemplate)
 
 	// Crear la plantilla base
 	base := template_method.NewProcesadorBase()
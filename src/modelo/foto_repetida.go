@@ -0,0 +1,139 @@
+package modelo
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+	"github.com/MetaDandy/Assistense-System/src/modelo/cadena_responsabilidad"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IntentoFotoRepetida registra una asistencia rechazada porque su foto ya se había usado antes
+// Queda pendiente hasta que el docente de la sesión lo revisa
+type IntentoFotoRepetida struct {
+	ID               uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	FechaHora        time.Time `gorm:"not null"`
+	FotoVerificacion string    `gorm:"type:text"`
+	HashExacto       string    `gorm:"type:varchar(64)"`
+	HashPerceptual   int64
+	Exacta           bool
+	Distancia        int
+	Revisado         bool `gorm:"default:false"`
+
+	EstudianteID         uuid.UUID `gorm:"type:uuid;not null;index"`
+	SesionAsistenciaID   uuid.UUID `gorm:"type:uuid;not null;index"`
+	AsistenciaOriginalID uuid.UUID `gorm:"type:uuid;not null"` // Asistencia cuya foto se reutilizó
+
+	Estudiante         Estudiante       `gorm:"foreignKey:EstudianteID"`
+	SesionAsistencia   SesionAsistencia `gorm:"foreignKey:SesionAsistenciaID"`
+	AsistenciaOriginal Asistencia       `gorm:"foreignKey:AsistenciaOriginalID"`
+}
+
+// VentanaFotoRepetida es hasta cuándo atrás se buscan fotos casi idénticas del mismo estudiante
+const VentanaFotoRepetida = 30 * 24 * time.Hour
+
+// buscarFotoRepetida busca una asistencia con la misma foto o, del mismo estudiante, una casi idéntica
+// El mismo archivo se busca entre todas las asistencias con el índice del hash exacto. El perceptual solo
+// se compara con las fotos del estudiante en VentanaFotoRepetida (índice por estudiante y hora): con una
+// cámara fija, las fotos de estudiantes distintos en el mismo lugar tienen dHash parecidos, y la distancia
+// de Hamming no se puede indexar
+func (am *AsistenciaModelo) buscarFotoRepetida(estudianteID uuid.UUID, hashExacto string, hashPerceptual uint64, distanciaMaxima int) (*cadena_responsabilidad.FotoRepetida, error) {
+	var exacta Asistencia
+	err := am.db.Select("id").Where("hash_exacto = ?", hashExacto).First(&exacta).Error
+	if err == nil {
+		return &cadena_responsabilidad.FotoRepetida{AsistenciaID: exacta.ID, Exacta: true}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var huellas []Asistencia
+	if err := am.db.Select("id", "hash_perceptual").
+		Where("estudiante_id = ? AND fecha_hora > ? AND hash_exacto <> ''", estudianteID, time.Now().Add(-VentanaFotoRepetida)).
+		Find(&huellas).Error; err != nil {
+		return nil, err
+	}
+
+	var repetida *cadena_responsabilidad.FotoRepetida
+	for _, h := range huellas {
+		distancia := helper.DistanciaHamming(hashPerceptual, uint64(h.HashPerceptual))
+		if distancia <= distanciaMaxima && (repetida == nil || distancia < repetida.Distancia) {
+			repetida = &cadena_responsabilidad.FotoRepetida{AsistenciaID: h.ID, Distancia: distancia}
+		}
+	}
+	return repetida, nil
+}
+
+// registrarIntentoFotoRepetida guarda el intento rechazado con la foto enviada, para revisión del docente
+func (am *AsistenciaModelo) registrarIntentoFotoRepetida(solicitud *cadena_responsabilidad.SolicitudAsistencia, repetida *cadena_responsabilidad.FotoRepetida) error {
+	intento := &IntentoFotoRepetida{
+		ID:                   uuid.New(),
+		FechaHora:            time.Now(),
+		FotoVerificacion:     solicitud.FotoVerificacion,
+		HashExacto:           solicitud.HashExacto,
+		HashPerceptual:       int64(solicitud.HashPerceptual),
+		Exacta:               repetida.Exacta,
+		Distancia:            repetida.Distancia,
+		EstudianteID:         solicitud.EstudianteID,
+		SesionAsistenciaID:   solicitud.SesionID,
+		AsistenciaOriginalID: repetida.AsistenciaID,
+	}
+	return am.db.Create(intento).Error
+}
+
+// ObtenerIntentosFotoRepetida lista los intentos rechazados por foto repetida en una sesión
+// con el estudiante y la asistencia original (y su estudiante), para compararlas
+func (am *AsistenciaModelo) ObtenerIntentosFotoRepetida(sesionID uuid.UUID) ([]IntentoFotoRepetida, error) {
+	var intentos []IntentoFotoRepetida
+	err := am.db.Preload("Estudiante").Preload("AsistenciaOriginal.Estudiante").Preload("AsistenciaOriginal.SesionAsistencia").
+		Where("sesion_asistencia_id = ?", sesionID).Order("fecha_hora DESC").Find(&intentos).Error
+	return intentos, err
+}
+
+// ObtenerIntentoFotoRepetida obtiene un intento con su sesión, para verificar el acceso del docente
+func (am *AsistenciaModelo) ObtenerIntentoFotoRepetida(id uuid.UUID) (*IntentoFotoRepetida, error) {
+	var intento IntentoFotoRepetida
+	if err := am.db.Preload("SesionAsistencia").First(&intento, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &intento, nil
+}
+
+// MarcarIntentoFotoRepetidaRevisado marca el intento como revisado por el docente
+func (am *AsistenciaModelo) MarcarIntentoFotoRepetidaRevisado(id uuid.UUID) error {
+	return am.db.Model(&IntentoFotoRepetida{}).Where("id = ?", id).Update("revisado", true).Error
+}
+
+// MigrarHuellasAsistencias calcula las huellas de las asistencias registradas antes de la detección de repeticiones
+// Es idempotente: solo procesa las que no tienen hash exacto. Las fotos ilegibles se omiten
+func MigrarHuellasAsistencias(db *gorm.DB) (int, error) {
+	var asistencias []Asistencia
+	if err := db.Select("id", "foto_verificacion").
+		Where("(hash_exacto IS NULL OR hash_exacto = '') AND foto_verificacion <> ''").
+		Find(&asistencias).Error; err != nil {
+		return 0, err
+	}
+
+	migradas := 0
+	for _, a := range asistencias {
+		hashExacto, err := helper.HashExactoImagen(a.FotoVerificacion)
+		if err != nil {
+			continue
+		}
+		hashPerceptual, err := helper.HashPerceptualImagen(a.FotoVerificacion)
+		if err != nil {
+			continue
+		}
+		if err := db.Model(&Asistencia{}).Where("id = ?", a.ID).Updates(map[string]interface{}{
+			"hash_exacto":     hashExacto,
+			"hash_perceptual": int64(hashPerceptual),
+		}).Error; err != nil {
+			return migradas, err
+		}
+		migradas++
+	}
+
+	return migradas, nil
+}
@@ -0,0 +1,135 @@
+package modelo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+	"github.com/google/uuid"
+)
+
+// Errores de la identificación 1:N que el controlador distingue
+var (
+	ErrSinCandidatos          = errors.New("no hay estudiantes inscritos con referencias faciales para identificar")
+	ErrIdentificacionAmbigua  = errors.New("la foto se parece demasiado a más de un estudiante")
+	ErrIdentificacionSinMatch = errors.New("la foto no se parece lo suficiente a ningún estudiante")
+)
+
+// CandidatoIdentificacion es la mejor similitud de un estudiante entre todas sus referencias
+type CandidatoIdentificacion struct {
+	EstudianteID uuid.UUID `json:"estudiante_id"`
+	ReferenciaID uuid.UUID `json:"referencia_id"`
+	Similitud    float64   `json:"similitud"`
+}
+
+// ResultadoIdentificacion es el ranking de la foto contra los estudiantes y, si fue concluyente,
+// la asistencia registrada para el primero
+type ResultadoIdentificacion struct {
+	Mejor   *CandidatoIdentificacion
+	Segundo *CandidatoIdentificacion // nil si hay un solo estudiante con referencias
+	// Margen es la diferencia de similitud entre el primero y el segundo
+	Margen       float64
+	MargenMinimo float64
+
+	Asistencia *Asistencia
+}
+
+type IdentificarAsistenciaDto struct {
+	FotoVerificacion   string    `json:"foto_verificacion" binding:"required"`
+	Fotogramas         []string  `json:"fotogramas"`
+	DesafioID          uuid.UUID `json:"desafio_id"` // Desafío de la sesión, sin estudiante (ver RegistrarAsistenciaDto)
+	SesionAsistenciaID uuid.UUID `json:"sesion_asistencia_id" binding:"required"`
+	RequiereAprobacion bool      `json:"-"` // Ver RegistrarAsistenciaDto
+}
+
+// IdentificarYRegistrarAsistencia busca al estudiante de la foto entre los inscritos en la sesión que tienen
+// referencias y, solo si el primero supera al segundo por el margen configurado, le registra la asistencia con la
+// cadena de validadores completa (vivacidad, similitud, duplicado, etc.)
+// Un estudiante que ya tiene asistencia sigue siendo candidato: así se le informa el duplicado
+// en lugar de confundirlo con el siguiente más parecido
+func (am *AsistenciaModelo) IdentificarYRegistrarAsistencia(dto *IdentificarAsistenciaDto) (*ResultadoIdentificacion, error) {
+	reglas, err := am.sesionModelo.ObtenerReglasValidacion(dto.SesionAsistenciaID)
+	if err != nil {
+		return nil, fmt.Errorf("sesión no encontrada")
+	}
+
+	if err := helper.ValidarImagenBase64(dto.FotoVerificacion); err != nil {
+		return nil, fmt.Errorf("imagen base64 inválida: %w", err)
+	}
+	actual, err := am.matcher.ExtraerCaracteristicas(dto.FotoVerificacion)
+	if err != nil {
+		return nil, fmt.Errorf("error al comparar rostros: %w", err)
+	}
+
+	inscritos, err := am.sesionModelo.ObtenerEstudiantesInscritos(dto.SesionAsistenciaID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, len(inscritos))
+	for i, e := range inscritos {
+		ids[i] = e.ID
+	}
+	referencias, err := am.estudianteModelo.ObtenerCaracteristicasEstudiantes(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mejor de N por estudiante, como en la verificación 1:1
+	porEstudiante := make(map[uuid.UUID]*CandidatoIdentificacion)
+	for _, ref := range referencias {
+		similitud, err := am.matcher.Comparar(ref.Datos, actual)
+		if err != nil {
+			return nil, fmt.Errorf("error al comparar rostros: %v", err)
+		}
+		if c, ok := porEstudiante[ref.EstudianteID]; !ok || similitud > c.Similitud {
+			porEstudiante[ref.EstudianteID] = &CandidatoIdentificacion{
+				EstudianteID: ref.EstudianteID,
+				ReferenciaID: ref.ReferenciaFacialID,
+				Similitud:    similitud,
+			}
+		}
+	}
+	if len(porEstudiante) == 0 {
+		return nil, ErrSinCandidatos
+	}
+
+	candidatos := make([]*CandidatoIdentificacion, 0, len(porEstudiante))
+	for _, c := range porEstudiante {
+		candidatos = append(candidatos, c)
+	}
+	sort.Slice(candidatos, func(i, j int) bool { return candidatos[i].Similitud > candidatos[j].Similitud })
+
+	resultado := &ResultadoIdentificacion{
+		Mejor:        candidatos[0],
+		Margen:       candidatos[0].Similitud,
+		MargenMinimo: am.margenIdentificacion,
+	}
+	if len(candidatos) > 1 {
+		resultado.Segundo = candidatos[1]
+		resultado.Margen = candidatos[0].Similitud - candidatos[1].Similitud
+	}
+
+	// Sin siquiera la similitud de revisión no se intenta registrar: sería un rechazo por rostro
+	// que consume el desafío de la cámara
+	if resultado.Mejor.Similitud < reglas.Umbrales.Revision {
+		return resultado, ErrIdentificacionSinMatch
+	}
+	if resultado.Margen < am.margenIdentificacion {
+		return resultado, ErrIdentificacionAmbigua
+	}
+
+	asistencia, err := am.RegistrarAsistencia(&RegistrarAsistenciaDto{
+		FotoVerificacion:   dto.FotoVerificacion,
+		Fotogramas:         dto.Fotogramas,
+		DesafioID:          dto.DesafioID,
+		EstudianteID:       resultado.Mejor.EstudianteID,
+		SesionAsistenciaID: dto.SesionAsistenciaID,
+		RequiereAprobacion: dto.RequiereAprobacion,
+	})
+	if err != nil {
+		return resultado, err
+	}
+	resultado.Asistencia = asistencia
+	return resultado, nil
+}
@@ -0,0 +1,301 @@
+package modelo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Estados de una justificación de ausencia
+const (
+	JustificacionPendiente = "pendiente"
+	JustificacionAprobada  = "aprobada"
+	JustificacionRechazada = "rechazada"
+)
+
+// Quién envió la justificación
+const (
+	EnviadaPorEstudiante = "estudiante"
+	EnviadaPorDocente    = "docente"
+)
+
+// Justificacion es el motivo por el que un estudiante faltó a una o más sesiones de un mismo docente,
+// con sus documentos de respaldo. Al aprobarla, sus ausencias en esas sesiones pasan a justificadas
+type Justificacion struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	Motivo     string    `gorm:"type:text;not null"`
+	Estado     string    `gorm:"type:varchar(20);not null;default:'pendiente';index"`
+	EnviadaPor string    `gorm:"type:varchar(20);not null"`
+	FechaHora  time.Time `gorm:"not null"`
+
+	// Revisión del docente
+	ComentarioRevision string `gorm:"type:varchar(255)"`
+	RevisadaEn         *time.Time
+
+	EstudianteID uuid.UUID `gorm:"type:uuid;not null;index"`
+	DocenteID    uuid.UUID `gorm:"type:uuid;not null;index"` // Docente de las sesiones, que la revisa
+
+	Estudiante Estudiante               `gorm:"foreignKey:EstudianteID"`
+	Docente    Docente                  `gorm:"foreignKey:DocenteID"`
+	Sesiones   []SesionAsistencia       `gorm:"many2many:justificacion_sesiones;"`
+	Documentos []DocumentoJustificacion `gorm:"foreignKey:JustificacionID"`
+}
+
+// DocumentoJustificacion es un archivo de respaldo (PDF o imagen) de una justificación
+type DocumentoJustificacion struct {
+	ID              uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	Nombre          string    `gorm:"type:varchar(255);not null"`
+	TipoContenido   string    `gorm:"type:varchar(50);not null"`
+	Datos           []byte    `gorm:"type:bytea;not null"`
+	JustificacionID uuid.UUID `gorm:"type:uuid;not null;index"`
+}
+
+type EnviarJustificacionDto struct {
+	EstudianteID uuid.UUID   `json:"estudiante_id" binding:"required"`
+	SesionIDs    []uuid.UUID `json:"sesion_ids" binding:"required"`
+	Motivo       string      `json:"motivo" binding:"required"`
+	Documentos   []helper.Documento
+}
+
+type RevisarJustificacionDto struct {
+	Decision   string `json:"decision" binding:"required"` // aprobar | rechazar
+	Comentario string `json:"comentario"`
+}
+
+type JustificacionModeloInterfaz interface {
+	EnviarJustificacion(dto *EnviarJustificacionDto, docenteID *uuid.UUID) (*Justificacion, error)
+	ObtenerJustificacion(id uuid.UUID) (*Justificacion, error)
+	ObtenerJustificacionesDocente(docenteID uuid.UUID) ([]Justificacion, error)
+	ContarJustificacionesPendientes(docenteID uuid.UUID) (int64, error)
+	ObtenerDocumento(justificacionID, documentoID uuid.UUID) (*DocumentoJustificacion, error)
+	ObtenerSesionesJustificables() ([]SesionAsistencia, error)
+	RevisarJustificacion(id, docenteID uuid.UUID, dto *RevisarJustificacionDto) (*Justificacion, error)
+}
+
+type JustificacionModelo struct {
+	db           *gorm.DB
+	sesionModelo SesionAsistenciaInterfaz
+}
+
+// sesionModelo comprueba que el estudiante esté inscrito en las sesiones que justifica
+func NuevaJustificacionModelo(db *gorm.DB, sesionModelo SesionAsistenciaInterfaz) JustificacionModeloInterfaz {
+	return &JustificacionModelo{db: db, sesionModelo: sesionModelo}
+}
+
+// EnviarJustificacion registra una justificación pendiente. Si la envía un docente (docenteID no nulo),
+// las sesiones tienen que ser suyas; si la envía el estudiante, basta con que sean de un mismo docente
+func (jm *JustificacionModelo) EnviarJustificacion(dto *EnviarJustificacionDto, docenteID *uuid.UUID) (*Justificacion, error) {
+	dto.Motivo = strings.TrimSpace(dto.Motivo)
+	if dto.Motivo == "" {
+		return nil, fmt.Errorf("el motivo es obligatorio")
+	}
+	if len(dto.SesionIDs) == 0 {
+		return nil, fmt.Errorf("seleccione al menos una sesión")
+	}
+
+	var estudiante Estudiante
+	if err := jm.db.Where("id = ?", dto.EstudianteID).First(&estudiante).Error; err != nil {
+		return nil, fmt.Errorf("estudiante no encontrado")
+	}
+
+	// Una sesión repetida en el formulario se justifica una sola vez
+	sesionIDs := make([]uuid.UUID, 0, len(dto.SesionIDs))
+	vistas := make(map[uuid.UUID]bool, len(dto.SesionIDs))
+	for _, id := range dto.SesionIDs {
+		if !vistas[id] {
+			vistas[id] = true
+			sesionIDs = append(sesionIDs, id)
+		}
+	}
+	dto.SesionIDs = sesionIDs
+
+	var sesiones []SesionAsistencia
+	if err := jm.db.Where("id IN ?", dto.SesionIDs).Find(&sesiones).Error; err != nil {
+		return nil, err
+	}
+	if len(sesiones) != len(dto.SesionIDs) {
+		return nil, fmt.Errorf("sesión no encontrada")
+	}
+	for _, s := range sesiones {
+		inscrito, err := jm.sesionModelo.EstaInscrito(s.ID, dto.EstudianteID)
+		if err != nil {
+			return nil, err
+		}
+		if !inscrito {
+			return nil, ErrEstudianteNoInscrito
+		}
+	}
+	revisor := sesiones[0].DocenteID
+	for _, s := range sesiones {
+		if s.DocenteID != revisor {
+			return nil, fmt.Errorf("las sesiones de una justificación deben ser del mismo docente")
+		}
+	}
+	enviadaPor := EnviadaPorEstudiante
+	if docenteID != nil {
+		if revisor != *docenteID {
+			return nil, fmt.Errorf("no tiene acceso a estas sesiones")
+		}
+		enviadaPor = EnviadaPorDocente
+	}
+
+	justificacion := &Justificacion{
+		ID:           uuid.New(),
+		Motivo:       dto.Motivo,
+		Estado:       JustificacionPendiente,
+		EnviadaPor:   enviadaPor,
+		FechaHora:    time.Now(),
+		EstudianteID: dto.EstudianteID,
+		DocenteID:    revisor,
+		Sesiones:     sesiones,
+	}
+	for _, d := range dto.Documentos {
+		justificacion.Documentos = append(justificacion.Documentos, DocumentoJustificacion{
+			ID:            uuid.New(),
+			Nombre:        d.Nombre,
+			TipoContenido: d.Tipo,
+			Datos:         d.Datos,
+		})
+	}
+
+	// Las sesiones ya existen: solo se crea la relación, no se vuelven a guardar
+	if err := jm.db.Omit("Sesiones.*").Create(justificacion).Error; err != nil {
+		return nil, err
+	}
+	return justificacion, nil
+}
+
+// ObtenerJustificacion devuelve la justificación con sus sesiones y documentos (sin el contenido de los archivos)
+func (jm *JustificacionModelo) ObtenerJustificacion(id uuid.UUID) (*Justificacion, error) {
+	var justificacion Justificacion
+	err := jm.db.Preload("Estudiante").Preload("Sesiones").
+		Preload("Documentos", func(db *gorm.DB) *gorm.DB { return db.Select("id", "nombre", "tipo_contenido", "justificacion_id") }).
+		Where("id = ?", id).First(&justificacion).Error
+	if err != nil {
+		return nil, err
+	}
+	return &justificacion, nil
+}
+
+// ObtenerJustificacionesDocente lista las justificaciones de las sesiones del docente: primero las pendientes,
+// de la más antigua a la más nueva, y después las revisadas, de la más nueva a la más antigua
+func (jm *JustificacionModelo) ObtenerJustificacionesDocente(docenteID uuid.UUID) ([]Justificacion, error) {
+	var justificaciones []Justificacion
+	err := jm.db.Preload("Estudiante").
+		Preload("Sesiones", func(db *gorm.DB) *gorm.DB { return db.Order("inicio") }).
+		Preload("Documentos", func(db *gorm.DB) *gorm.DB { return db.Select("id", "nombre", "tipo_contenido", "justificacion_id") }).
+		Where("docente_id = ?", docenteID).
+		Order(fmt.Sprintf("estado = '%s' DESC", JustificacionPendiente)).
+		Order(fmt.Sprintf("CASE WHEN estado = '%s' THEN fecha_hora END ASC", JustificacionPendiente)).
+		Order("fecha_hora DESC").
+		Find(&justificaciones).Error
+	return justificaciones, err
+}
+
+func (jm *JustificacionModelo) ContarJustificacionesPendientes(docenteID uuid.UUID) (int64, error) {
+	var total int64
+	err := jm.db.Model(&Justificacion{}).Where("docente_id = ? AND estado = ?", docenteID, JustificacionPendiente).Count(&total).Error
+	return total, err
+}
+
+func (jm *JustificacionModelo) ObtenerDocumento(justificacionID, documentoID uuid.UUID) (*DocumentoJustificacion, error) {
+	var documento DocumentoJustificacion
+	if err := jm.db.Where("id = ? AND justificacion_id = ?", documentoID, justificacionID).First(&documento).Error; err != nil {
+		return nil, err
+	}
+	return &documento, nil
+}
+
+// ObtenerSesionesJustificables lista las sesiones que un estudiante puede elegir al justificar, las más
+// recientes primero, con su docente
+func (jm *JustificacionModelo) ObtenerSesionesJustificables() ([]SesionAsistencia, error) {
+	var sesiones []SesionAsistencia
+	err := jm.db.Preload("Docente").Order("inicio DESC").Find(&sesiones).Error
+	return sesiones, err
+}
+
+// RevisarJustificacion aprueba o rechaza una justificación pendiente del docente
+// Al aprobarla, las ausencias del estudiante en sus sesiones pasan a justificadas; en las sesiones que ya
+// terminaron y en las que no tiene ningún registro, se crea uno justificado. Las que aún no terminan se
+// resuelven al marcar sus ausencias (ver MarcarAusencias)
+func (jm *JustificacionModelo) RevisarJustificacion(id, docenteID uuid.UUID, dto *RevisarJustificacionDto) (*Justificacion, error) {
+	var nuevoEstado string
+	switch dto.Decision {
+	case DecisionAprobar:
+		nuevoEstado = JustificacionAprobada
+	case DecisionRechazar:
+		nuevoEstado = JustificacionRechazada
+	default:
+		return nil, fmt.Errorf("decisión inválida: %s", dto.Decision)
+	}
+
+	var justificacion Justificacion
+	err := jm.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Sesiones").Where("id = ? AND docente_id = ?", id, docenteID).First(&justificacion).Error; err != nil {
+			return fmt.Errorf("justificación no encontrada")
+		}
+		if justificacion.Estado != JustificacionPendiente {
+			return fmt.Errorf("la justificación ya fue revisada")
+		}
+
+		ahora := time.Now()
+		if err := tx.Model(&justificacion).Updates(map[string]interface{}{
+			"estado":              nuevoEstado,
+			"comentario_revision": strings.TrimSpace(dto.Comentario),
+			"revisada_en":         &ahora,
+		}).Error; err != nil {
+			return err
+		}
+		justificacion.Estado, justificacion.RevisadaEn = nuevoEstado, &ahora
+
+		if nuevoEstado != JustificacionAprobada {
+			return nil
+		}
+		for _, sesion := range justificacion.Sesiones {
+			if err := justificarAusencias(tx, justificacion.EstudianteID, &sesion); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &justificacion, nil
+}
+
+// justificarAusencias pasa a justificadas las ausencias del estudiante en la sesión; si la sesión ya se cerró
+// y el estudiante no tiene ningún registro, crea uno justificado
+func justificarAusencias(tx *gorm.DB, estudianteID uuid.UUID, sesion *SesionAsistencia) error {
+	resultado := tx.Model(&Asistencia{}).
+		Where("estudiante_id = ? AND sesion_asistencia_id = ? AND condicion = ?", estudianteID, sesion.ID, CondicionAusente).
+		Update("condicion", CondicionJustificado)
+	if resultado.Error != nil {
+		return resultado.Error
+	}
+
+	// Si todavía no se cerró, la ausencia justificada se crea al marcar las ausencias (ver MarcarAusencias)
+	if sesion.Estado != EstadoSesionCerrada {
+		return nil
+	}
+	var registros int64
+	if err := tx.Model(&Asistencia{}).Where("estudiante_id = ? AND sesion_asistencia_id = ?", estudianteID, sesion.ID).
+		Count(&registros).Error; err != nil {
+		return err
+	}
+	if registros > 0 {
+		return nil
+	}
+	return tx.Create(&Asistencia{
+		ID:                 uuid.New(),
+		FechaHora:          sesion.Fin,
+		Estado:             EstadoSinVerificacion,
+		Condicion:          CondicionJustificado,
+		EstudianteID:       estudianteID,
+		SesionAsistenciaID: sesion.ID,
+	}).Error
+}
@@ -0,0 +1,260 @@
+package modelo
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+	"github.com/MetaDandy/Assistense-System/src/modelo/template_method"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Acciones y motivos de las actualizaciones automáticas de referencias
+const (
+	AccionReferenciaAgregada   = "agregada"
+	AccionReferenciaDescartada = "descartada"
+
+	MotivoSimilitudAlta = "similitud_alta"
+	MotivoCapacidad     = "capacidad"
+	MotivoAntiguedad    = "antiguedad"
+)
+
+// ErrEstudianteAjeno indica que el docente no tiene al estudiante en ninguna de sus sesiones
+var ErrEstudianteAjeno = errors.New("el estudiante no está inscrito en ninguna sesión del docente")
+
+// PoliticaReferenciasAdaptativas controla cuándo una foto de verificación pasa a ser referencia del estudiante
+// Solo afecta a las referencias automáticas: las cargadas por el docente nunca se descartan solas
+type PoliticaReferenciasAdaptativas struct {
+	Habilitada bool
+	// MargenSimilitud es cuánto por encima del umbral de aceptación debe quedar la similitud
+	MargenSimilitud float64
+	// MaximoAutomaticas es la cantidad máxima de referencias automáticas por estudiante
+	MaximoAutomaticas int
+	// AntiguedadMaxima descarta las referencias automáticas más viejas que esto
+	AntiguedadMaxima time.Duration
+}
+
+// ActualizacionReferencia registra cada cambio automático en las referencias de un estudiante
+// Guarda la foto de las descartadas para que el docente pueda revertirlo
+type ActualizacionReferencia struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primaryKey;"`
+	EstudianteID       uuid.UUID  `gorm:"type:uuid;not null;index"`
+	ReferenciaFacialID uuid.UUID  `gorm:"type:uuid;not null"` // Sin clave foránea: la referencia puede ya no existir
+	AsistenciaID       *uuid.UUID `gorm:"type:uuid"`
+	Accion             string     `gorm:"type:varchar(20);not null"`
+	Motivo             string     `gorm:"type:varchar(20);not null"`
+	Similitud          float64    `gorm:"type:decimal(5,4)"`
+	Foto               string     `gorm:"type:text"` // Solo en las descartadas
+	ReferenciaCreadaEn time.Time
+	FechaHora          time.Time `gorm:"not null"`
+
+	Revertida      bool `gorm:"default:false"`
+	RevertidaEn    *time.Time
+	RevertidaPorID *uuid.UUID `gorm:"type:uuid"` // Docente que la revirtió
+}
+
+// AgregarReferenciaAutomatica suma una foto de verificación como referencia automática del estudiante
+// y aplica la política: descarta las automáticas vencidas y, si se pasa del máximo, las más antiguas
+// La foto debe superar el control de calidad de las referencias; si no, no se agrega y devuelve nil
+func (em *EstudianteModelo) AgregarReferenciaAutomatica(estudianteID, asistenciaID uuid.UUID, foto string, similitud float64, politica PoliticaReferenciasAdaptativas) (*ReferenciaFacial, error) {
+	if err := helper.ValidarCalidadFotoReferencia(foto); err != nil {
+		var errCalidad *helper.ErrorCalidadFoto
+		if errors.As(err, &errCalidad) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ahora := time.Now()
+	referencia := &ReferenciaFacial{
+		ID:           uuid.New(),
+		EstudianteID: estudianteID,
+		Foto:         foto,
+		Descripcion:  fmt.Sprintf("Automática (similitud %.0f%%)", similitud*100),
+		Origen:       OrigenReferenciaAutomatica,
+		AsistenciaID: &asistenciaID,
+		CreadoEn:     ahora,
+	}
+
+	err := em.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(referencia).Error; err != nil {
+			return err
+		}
+		if _, err := template_method.GuardarCaracteristicasReferencia(tx, em.matcher, referencia); err != nil {
+			return err
+		}
+		if err := tx.Create(&ActualizacionReferencia{
+			ID:                 uuid.New(),
+			EstudianteID:       estudianteID,
+			ReferenciaFacialID: referencia.ID,
+			AsistenciaID:       &asistenciaID,
+			Accion:             AccionReferenciaAgregada,
+			Motivo:             MotivoSimilitudAlta,
+			Similitud:          similitud,
+			ReferenciaCreadaEn: referencia.CreadoEn,
+			FechaHora:          ahora,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Automáticas de la más nueva a la más vieja: se conservan las primeras MaximoAutomaticas no vencidas
+		var automaticas []ReferenciaFacial
+		if err := tx.Where("estudiante_id = ? AND origen = ?", estudianteID, OrigenReferenciaAutomatica).
+			Order("creado_en DESC").Find(&automaticas).Error; err != nil {
+			return err
+		}
+
+		for i, auto := range automaticas {
+			motivo := ""
+			switch {
+			case politica.AntiguedadMaxima > 0 && ahora.Sub(auto.CreadoEn) > politica.AntiguedadMaxima:
+				motivo = MotivoAntiguedad
+			case politica.MaximoAutomaticas > 0 && i >= politica.MaximoAutomaticas:
+				motivo = MotivoCapacidad
+			}
+			if motivo == "" || auto.ID == referencia.ID {
+				continue
+			}
+			if err := descartarReferenciaAutomatica(tx, &auto, motivo, ahora); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return referencia, nil
+}
+
+// descartarReferenciaAutomatica elimina la referencia y registra el descarte con su foto, para poder revertirlo
+func descartarReferenciaAutomatica(tx *gorm.DB, referencia *ReferenciaFacial, motivo string, ahora time.Time) error {
+	if err := tx.Create(&ActualizacionReferencia{
+		ID:                 uuid.New(),
+		EstudianteID:       referencia.EstudianteID,
+		ReferenciaFacialID: referencia.ID,
+		AsistenciaID:       referencia.AsistenciaID,
+		Accion:             AccionReferenciaDescartada,
+		Motivo:             motivo,
+		Foto:               referencia.Foto,
+		ReferenciaCreadaEn: referencia.CreadoEn,
+		FechaHora:          ahora,
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("referencia_facial_id = ?", referencia.ID).Delete(&CaracteristicasReferencia{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(referencia).Error
+}
+
+// DescartarReferenciasVencidas descarta las referencias automáticas de todos los estudiantes más viejas que
+// AntiguedadMaxima, aunque el estudiante no haya vuelto a registrar asistencia. Devuelve cuántas descartó
+func (em *EstudianteModelo) DescartarReferenciasVencidas(politica PoliticaReferenciasAdaptativas) (int, error) {
+	if politica.AntiguedadMaxima <= 0 {
+		return 0, nil
+	}
+
+	ahora := time.Now()
+	var vencidas []ReferenciaFacial
+	if err := em.db.Where("origen = ? AND creado_en < ?", OrigenReferenciaAutomatica, ahora.Add(-politica.AntiguedadMaxima)).
+		Find(&vencidas).Error; err != nil {
+		return 0, err
+	}
+
+	for i := range vencidas {
+		if err := em.db.Transaction(func(tx *gorm.DB) error {
+			return descartarReferenciaAutomatica(tx, &vencidas[i], MotivoAntiguedad, ahora)
+		}); err != nil {
+			return i, err
+		}
+	}
+	return len(vencidas), nil
+}
+
+// ProgramarVencimientoReferencias descarta cada hora las referencias automáticas vencidas
+// Pensada para correr en su propia goroutine durante toda la vida del servidor
+func ProgramarVencimientoReferencias(estudiantes EstudianteModeloInterfaz, politica PoliticaReferenciasAdaptativas) {
+	for {
+		descartadas, err := estudiantes.DescartarReferenciasVencidas(politica)
+		if err != nil {
+			log.Printf("Error al descartar referencias automáticas vencidas: %v", err)
+		} else if descartadas > 0 {
+			log.Printf("Descartadas %d referencia(s) automática(s) con más de %v", descartadas, politica.AntiguedadMaxima)
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// ObtenerActualizacionesReferencia lista los cambios automáticos en las referencias del estudiante, el más nuevo primero
+func (em *EstudianteModelo) ObtenerActualizacionesReferencia(estudianteID uuid.UUID) ([]ActualizacionReferencia, error) {
+	var actualizaciones []ActualizacionReferencia
+	err := em.db.Where("estudiante_id = ?", estudianteID).Order("fecha_hora DESC").Find(&actualizaciones).Error
+	return actualizaciones, err
+}
+
+// RevertirActualizacionReferencia deshace un cambio automático: quita la referencia agregada
+// o restaura la descartada (con su fecha original). Cada actualización se revierte una sola vez
+// Solo puede hacerlo un docente que tenga al estudiante inscrito en alguna de sus sesiones
+func (em *EstudianteModelo) RevertirActualizacionReferencia(estudianteID, actualizacionID, docenteID uuid.UUID) error {
+	var inscripciones int64
+	if err := em.db.Table("sesion_estudiantes").
+		Joins("JOIN sesion_asistencias ON sesion_asistencias.id = sesion_estudiantes.sesion_asistencia_id").
+		Where("sesion_estudiantes.estudiante_id = ? AND sesion_asistencias.docente_id = ?", estudianteID, docenteID).
+		Count(&inscripciones).Error; err != nil {
+		return err
+	}
+	if inscripciones == 0 {
+		return ErrEstudianteAjeno
+	}
+
+	var actualizacion ActualizacionReferencia
+	if err := em.db.Where("id = ? AND estudiante_id = ?", actualizacionID, estudianteID).First(&actualizacion).Error; err != nil {
+		return fmt.Errorf("actualización no encontrada")
+	}
+	if actualizacion.Revertida {
+		return fmt.Errorf("la actualización ya fue revertida")
+	}
+
+	return em.db.Transaction(func(tx *gorm.DB) error {
+		switch actualizacion.Accion {
+		case AccionReferenciaAgregada:
+			if err := tx.Where("referencia_facial_id = ?", actualizacion.ReferenciaFacialID).Delete(&CaracteristicasReferencia{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id = ?", actualizacion.ReferenciaFacialID).Delete(&ReferenciaFacial{}).Error; err != nil {
+				return err
+			}
+		case AccionReferenciaDescartada:
+			referencia := &ReferenciaFacial{
+				ID:           actualizacion.ReferenciaFacialID,
+				EstudianteID: actualizacion.EstudianteID,
+				Foto:         actualizacion.Foto,
+				Descripcion:  "Automática (restaurada)",
+				Origen:       OrigenReferenciaAutomatica,
+				AsistenciaID: actualizacion.AsistenciaID,
+				CreadoEn:     actualizacion.ReferenciaCreadaEn,
+			}
+			if err := tx.Create(referencia).Error; err != nil {
+				return err
+			}
+			if _, err := template_method.GuardarCaracteristicasReferencia(tx, em.matcher, referencia); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("acción desconocida: %s", actualizacion.Accion)
+		}
+
+		ahora := time.Now()
+		return tx.Model(&actualizacion).Updates(map[string]interface{}{
+			"revertida":        true,
+			"revertida_en":     ahora,
+			"revertida_por_id": docenteID,
+		}).Error
+	})
+}
@@ -0,0 +1,214 @@
+package modelo
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+	"github.com/MetaDandy/Assistense-System/src/modelo/template_method"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ReferenciaFacial es el alias del modelo en template_method
+type ReferenciaFacial = template_method.ReferenciaFacial
+
+// CaracteristicasReferencia es el alias del modelo en template_method
+type CaracteristicasReferencia = template_method.CaracteristicasReferencia
+
+// Origen de una referencia facial (ver template_method)
+const (
+	OrigenReferenciaManual     = template_method.OrigenReferenciaManual
+	OrigenReferenciaAutomatica = template_method.OrigenReferenciaAutomatica
+)
+
+type AgregarReferenciaFacialDto struct {
+	Foto        string `json:"foto" binding:"required"` // Base64
+	Descripcion string `json:"descripcion"`
+}
+
+// AgregarReferenciaFacial suma una foto de enrolamiento al estudiante y calcula sus características
+// La foto debe superar el mismo control de calidad que la foto de registro
+func (em *EstudianteModelo) AgregarReferenciaFacial(estudianteID uuid.UUID, dto *AgregarReferenciaFacialDto) (*ReferenciaFacial, error) {
+	if dto.Foto == "" {
+		return nil, fmt.Errorf("foto requerida")
+	}
+	if err := helper.ValidarCalidadFotoReferencia(dto.Foto); err != nil {
+		return nil, err
+	}
+
+	if _, err := em.ObtenerEstudiantePorID(estudianteID); err != nil {
+		return nil, fmt.Errorf("estudiante no encontrado")
+	}
+
+	referencia := &ReferenciaFacial{
+		ID:           uuid.New(),
+		EstudianteID: estudianteID,
+		Foto:         dto.Foto,
+		Descripcion:  dto.Descripcion,
+		Origen:       OrigenReferenciaManual,
+		CreadoEn:     time.Now(),
+	}
+
+	err := em.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(referencia).Error; err != nil {
+			return err
+		}
+		_, err := template_method.GuardarCaracteristicasReferencia(tx, em.matcher, referencia)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return referencia, nil
+}
+
+// ObtenerReferenciasFaciales lista las fotos de enrolamiento del estudiante, la principal primero
+func (em *EstudianteModelo) ObtenerReferenciasFaciales(estudianteID uuid.UUID) ([]ReferenciaFacial, error) {
+	var referencias []ReferenciaFacial
+
+	if err := em.db.Where("estudiante_id = ?", estudianteID).
+		Order("principal DESC, creado_en").Find(&referencias).Error; err != nil {
+		return nil, err
+	}
+
+	return referencias, nil
+}
+
+// EliminarReferenciaFacial quita una foto de enrolamiento
+// La principal no se elimina aquí: se reemplaza editando la foto de referencia del estudiante
+func (em *EstudianteModelo) EliminarReferenciaFacial(estudianteID, referenciaID uuid.UUID) error {
+	var referencia ReferenciaFacial
+
+	if err := em.db.Where("id = ? AND estudiante_id = ?", referenciaID, estudianteID).First(&referencia).Error; err != nil {
+		return fmt.Errorf("referencia facial no encontrada")
+	}
+	if referencia.Principal {
+		return fmt.Errorf("la foto principal no se puede eliminar, reemplácela editando al estudiante")
+	}
+
+	return em.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("referencia_facial_id = ?", referencia.ID).Delete(&CaracteristicasReferencia{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&referencia).Error
+	})
+}
+
+// ObtenerCaracteristicasReferencia devuelve las características vigentes de las referencias del estudiante
+// Como ObtenerCaracteristicasEstudiantes, no calcula nada durante la verificación: una referencia sin
+// características del algoritmo y versión actuales (o cuya foto ya no tiene un rostro detectable) queda
+// fuera hasta que PrecalcularCaracteristicas o el backfill la procesen
+// Devuelve una lista vacía cuando el estudiante no tiene referencias utilizables
+func (em *EstudianteModelo) ObtenerCaracteristicasReferencia(estudianteID uuid.UUID) ([]CaracteristicasReferencia, error) {
+	vigentes, err := em.ObtenerCaracteristicasEstudiantes([]uuid.UUID{estudianteID})
+	if err != nil {
+		return nil, err
+	}
+
+	var sinCaracteristicas int64
+	if err := em.db.Model(&ReferenciaFacial{}).Where("estudiante_id = ?", estudianteID).
+		Where("id NOT IN (?)", em.db.Model(&CaracteristicasReferencia{}).Select("referencia_facial_id").
+			Where("estudiante_id = ? AND algoritmo = ? AND version = ?", estudianteID, em.matcher.Algoritmo(), em.matcher.Version())).
+		Count(&sinCaracteristicas).Error; err != nil {
+		return nil, err
+	}
+	if sinCaracteristicas > 0 {
+		log.Printf("Estudiante %s: %d referencia(s) sin características vigentes quedan fuera de la verificación", estudianteID, sinCaracteristicas)
+	}
+
+	return vigentes, nil
+}
+
+// ObtenerCaracteristicasEstudiantes devuelve las características vigentes de las referencias de los estudiantes
+// indicados, para identificarlos 1:N. No calcula nada: las características se guardan al agregar cada referencia
+// y las de otro algoritmo o versión se regeneran al iniciar (PrecalcularCaracteristicas); mientras tanto esa
+// referencia queda fuera de la identificación
+func (em *EstudianteModelo) ObtenerCaracteristicasEstudiantes(estudianteIDs []uuid.UUID) ([]CaracteristicasReferencia, error) {
+	var vigentes []CaracteristicasReferencia
+	if len(estudianteIDs) == 0 {
+		return vigentes, nil
+	}
+	if err := em.db.Where("estudiante_id IN ? AND algoritmo = ? AND version = ?",
+		estudianteIDs, em.matcher.Algoritmo(), em.matcher.Version()).Find(&vigentes).Error; err != nil {
+		return nil, err
+	}
+	return vigentes, nil
+}
+
+// PrecalcularCaracteristicas calcula, fuera de las verificaciones, las características que falten o sean de otro
+// algoritmo o versión; se lanza al iniciar para que la identificación 1:N las encuentre listas
+func PrecalcularCaracteristicas(estudiantes EstudianteModeloInterfaz) {
+	procesados, err := estudiantes.RecalcularCaracteristicas(false)
+	if err != nil {
+		log.Printf("Referencias sin características vigentes: %v", err)
+	}
+	if procesados > 0 {
+		log.Printf("Calculadas las características de %d referencia(s) facial(es)", procesados)
+	}
+}
+
+// RecalcularCaracteristicas genera las características de las referencias faciales existentes
+// Con todos=false solo procesa las que no las tienen o las tienen de otro algoritmo o versión
+// Una foto inválida no detiene el proceso: su error se acumula en el error devuelto
+func (em *EstudianteModelo) RecalcularCaracteristicas(todos bool) (int, error) {
+	consulta := em.db.Model(&ReferenciaFacial{})
+	if !todos {
+		consulta = consulta.Where("id NOT IN (?)", em.db.Model(&CaracteristicasReferencia{}).
+			Select("referencia_facial_id").Where("algoritmo = ? AND version = ?", em.matcher.Algoritmo(), em.matcher.Version()))
+	}
+
+	var ids []uuid.UUID
+	if err := consulta.Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	procesados := 0
+	var fallos []error
+	for _, id := range ids {
+		// Cargar de a una para no tener todas las fotos en memoria
+		var referencia ReferenciaFacial
+		if err := em.db.Preload("Estudiante").First(&referencia, "id = ?", id).Error; err != nil {
+			return procesados, err
+		}
+		if _, err := template_method.GuardarCaracteristicasReferencia(em.db, em.matcher, &referencia); err != nil {
+			fallos = append(fallos, fmt.Errorf("estudiante %s, referencia %s: %w", referencia.Estudiante.Registro, referencia.ID, err))
+			continue
+		}
+		procesados++
+	}
+
+	return procesados, errors.Join(fallos...)
+}
+
+// MigrarFotosReferencia copia la FotoReferencia de los estudiantes que todavía no tienen referencia principal
+// a la tabla de referencias faciales. Es idempotente: se ejecuta en cada migración
+// Las características se calculan luego, al iniciar (PrecalcularCaracteristicas) o con el backfill
+func MigrarFotosReferencia(db *gorm.DB) (int, error) {
+	var estudiantes []Estudiante
+	if err := db.Where("foto_referencia IS NOT NULL AND foto_referencia <> ''").
+		Where("id NOT IN (?)", db.Model(&ReferenciaFacial{}).Select("estudiante_id").Where("principal = ?", true)).
+		Find(&estudiantes).Error; err != nil {
+		return 0, err
+	}
+
+	for _, e := range estudiantes {
+		referencia := ReferenciaFacial{
+			ID:           uuid.New(),
+			EstudianteID: e.ID,
+			Foto:         e.FotoReferencia,
+			Descripcion:  "Foto de registro",
+			Principal:    true,
+			Origen:       OrigenReferenciaManual,
+			CreadoEn:     time.Now(),
+		}
+		if err := db.Create(&referencia).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	return len(estudiantes), nil
+}
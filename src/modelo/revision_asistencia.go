@@ -0,0 +1,117 @@
+package modelo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Decisiones del docente sobre una asistencia pendiente de revisión
+const (
+	DecisionAprobar  = "aprobar"
+	DecisionRechazar = "rechazar"
+)
+
+// RevisionAsistencia registra cada decisión del docente sobre una asistencia pendiente
+type RevisionAsistencia struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	Decision   string    `gorm:"type:varchar(20);not null"`
+	Comentario string    `gorm:"type:varchar(255)"`
+	FechaHora  time.Time `gorm:"not null"`
+
+	AsistenciaID uuid.UUID `gorm:"type:uuid;not null;index"`
+	DocenteID    uuid.UUID `gorm:"type:uuid;not null"`
+
+	Asistencia Asistencia `gorm:"foreignKey:AsistenciaID"`
+	Docente    Docente    `gorm:"foreignKey:DocenteID"`
+}
+
+type RevisarAsistenciasDto struct {
+	AsistenciaIDs []uuid.UUID `json:"asistencia_ids" binding:"required"`
+	Decision      string      `json:"decision" binding:"required"` // aprobar | rechazar
+	Comentario    string      `json:"comentario"`
+}
+
+// ObtenerAsistenciasPendientes lista la cola de revisión de una sesión, de la más antigua a la más nueva
+// Incluye la referencia facial que mejor coincidió para mostrar ambas fotos lado a lado
+func (am *AsistenciaModelo) ObtenerAsistenciasPendientes(sesionID uuid.UUID) ([]Asistencia, error) {
+	var asistencias []Asistencia
+	err := am.db.Preload("Estudiante").Preload("ReferenciaFacial").
+		Where("sesion_asistencia_id = ? AND estado = ?", sesionID, EstadoPendienteRevision).
+		Order("fecha_hora").Find(&asistencias).Error
+	return asistencias, err
+}
+
+// RevisarAsistencias aprueba o rechaza en bloque asistencias pendientes de la sesión y registra cada decisión
+// Las que no son de la sesión o ya no están pendientes se ignoran; devuelve cuántas se revisaron
+func (am *AsistenciaModelo) RevisarAsistencias(sesionID, docenteID uuid.UUID, dto *RevisarAsistenciasDto) (int, error) {
+	var nuevoEstado string
+	switch dto.Decision {
+	case DecisionAprobar:
+		nuevoEstado = EstadoAceptada
+	case DecisionRechazar:
+		nuevoEstado = EstadoRechazada
+	default:
+		return 0, fmt.Errorf("decisión inválida: %s", dto.Decision)
+	}
+	if len(dto.AsistenciaIDs) == 0 {
+		return 0, fmt.Errorf("no se seleccionaron asistencias")
+	}
+
+	// Al aprobar, la condición se decide con la hora en que el estudiante llegó, no con la de la revisión
+	reglas, err := am.sesionModelo.ObtenerReglasValidacion(sesionID)
+	if err != nil {
+		return 0, fmt.Errorf("sesión no encontrada")
+	}
+
+	revisadas := 0
+	err = am.db.Transaction(func(tx *gorm.DB) error {
+		var pendientes []Asistencia
+		if err := tx.Select("id", "fecha_hora").
+			Where("id IN ? AND sesion_asistencia_id = ? AND estado = ?", dto.AsistenciaIDs, sesionID, EstadoPendienteRevision).
+			Find(&pendientes).Error; err != nil {
+			return err
+		}
+		if len(pendientes) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, len(pendientes))
+		for i, a := range pendientes {
+			ids[i] = a.ID
+			// Una rechazada no tiene condición: no cuenta en la sesión y el estudiante puede volver a intentarlo
+			// (o el docente registrarlo a mano) sin quedar a la vez ausente y presente
+			condicion := ""
+			if nuevoEstado == EstadoAceptada {
+				condicion = reglas.CondicionLlegada(a.FechaHora)
+			}
+			if err := tx.Model(&Asistencia{}).Where("id = ? AND estado = ?", a.ID, EstadoPendienteRevision).
+				Updates(map[string]interface{}{"estado": nuevoEstado, "condicion": condicion}).Error; err != nil {
+				return err
+			}
+		}
+
+		ahora := time.Now()
+		revisiones := make([]RevisionAsistencia, len(ids))
+		for i, id := range ids {
+			revisiones[i] = RevisionAsistencia{
+				ID:           uuid.New(),
+				Decision:     dto.Decision,
+				Comentario:   dto.Comentario,
+				FechaHora:    ahora,
+				AsistenciaID: id,
+				DocenteID:    docenteID,
+			}
+		}
+		if err := tx.Create(&revisiones).Error; err != nil {
+			return err
+		}
+
+		revisadas = len(ids)
+		return nil
+	})
+
+	return revisadas, err
+}
@@ -1,64 +1,375 @@
 package modelo
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MetaDandy/Assistense-System/src/modelo/cadena_responsabilidad"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type SesionAsistencia struct {
-	ID         uuid.UUID `gorm:"type:uuid;primaryKey;"`
-	Fecha      string    `gorm:"type:varchar(10);not null"`
-	HoraInicio string    `gorm:"type:varchar(5);not null"`
-	HoraFin    string    `gorm:"type:varchar(5);not null"`
+	ID uuid.UUID `gorm:"type:uuid;primaryKey;"`
+
+	// Inicio y fin de la sesión; el fin cae al día siguiente en las sesiones que cruzan la medianoche
+	Inicio time.Time `gorm:"type:timestamptz;not null;index"`
+	Fin    time.Time `gorm:"type:timestamptz;not null"`
+
+	// Zona horaria en que se programó la sesión (la del docente o la de la institución); su horario se muestra en ella
+	ZonaHoraria string `gorm:"type:varchar(64);not null"`
+
+	// Programada, abierta, en tardanza, suspendida, en gracia, cerrada o cancelada (ver estado_sesion.go)
+	Estado string `gorm:"type:varchar(20);not null;default:'programada';index"`
+
+	// Umbrales de similitud propios de la sesión (por ejemplo, más estrictos en un examen)
+	// Si son nulos se usan los de la institución (UMBRAL_ACEPTACION y UMBRAL_REVISION)
+	UmbralAceptacion *float64 `gorm:"type:decimal(5,4)"`
+	UmbralRevision   *float64 `gorm:"type:decimal(5,4)"`
+
+	// Qué hacer con las fotos cuyos metadatos no corresponden a la sesión (estricto, advertencia o desactivado)
+	// Si es nulo se usa el de la institución (VALIDACION_METADATOS)
+	ModoMetadatos *string `gorm:"type:varchar(20)"`
+
+	// Minutos después de HoraInicio en que la llegada todavía cuenta como presente; después es tarde
+	// Si es nulo se usa el de la institución (TOLERANCIA_TARDANZA)
+	ToleranciaTardanza *int
+
+	// Minutos después de HoraFin en que todavía se reciben registros, pendientes de aprobación del docente
+	// Si es nulo se usa el de la institución (GRACIA_CIERRE)
+	GraciaCierre *int
+
+	// Cuándo se marcaron ausentes a los estudiantes sin asistencia; nulo mientras no se haya hecho
+	AusenciasMarcadasEn *time.Time
+
+	// Estudiantes inscritos: solo ellos pueden registrar asistencia y solo a ellos se les marca la ausencia
+	Estudiantes []Estudiante `gorm:"many2many:sesion_estudiantes;"`
 
 	DocenteID uuid.UUID `gorm:"type:uuid;not null"`
 	Docente   Docente   `gorm:"foreignKey:DocenteID"`
 }
 
+// Umbrales devuelve los umbrales vigentes de la sesión, completando los que no tiene con los de la institución
+func (s *SesionAsistencia) Umbrales(porDefecto UmbralesSimilitud) UmbralesSimilitud {
+	umbrales := porDefecto
+	if s.UmbralAceptacion != nil {
+		umbrales.Aceptacion = *s.UmbralAceptacion
+	}
+	if s.UmbralRevision != nil {
+		umbrales.Revision = *s.UmbralRevision
+	}
+	return umbrales
+}
+
+// ModoMetadatosVigente devuelve el modo de validación de metadatos de la sesión o, si no tiene, el de la institución
+func (s *SesionAsistencia) ModoMetadatosVigente(porDefecto string) string {
+	if s.ModoMetadatos != nil {
+		return *s.ModoMetadatos
+	}
+	return porDefecto
+}
+
+// ToleranciaTardanzaVigente devuelve los minutos de tolerancia de la sesión o, si no tiene, los de la institución
+func (s *SesionAsistencia) ToleranciaTardanzaVigente(porDefecto int) int {
+	if s.ToleranciaTardanza != nil {
+		return *s.ToleranciaTardanza
+	}
+	return porDefecto
+}
+
+// GraciaCierreVigente devuelve los minutos de gracia de la sesión o, si no tiene, los de la institución
+func (s *SesionAsistencia) GraciaCierreVigente(porDefecto int) int {
+	if s.GraciaCierre != nil {
+		return *s.GraciaCierre
+	}
+	return porDefecto
+}
+
+// Ventana devuelve el horario de la sesión con su zona horaria
+func (s *SesionAsistencia) Ventana() VentanaSesion {
+	return VentanaSesion{Inicio: s.Inicio, Fin: s.Fin, Zona: s.Zona()}
+}
+
+// Zona devuelve la zona horaria de la sesión; si no se puede cargar (por ejemplo, falta en la base de zonas
+// del servidor) registra el error y usa la del servidor, para seguir mostrando la sesión
+func (s *SesionAsistencia) Zona() *time.Location {
+	zona, err := CargarZonaHoraria(s.ZonaHoraria)
+	if err != nil {
+		// Una vez por zona: se llama en cada hora que se muestra
+		if _, avisada := zonasInvalidas.LoadOrStore(s.ZonaHoraria, true); !avisada {
+			log.Printf("Sesión %s: %v; se muestra en la hora del servidor (%s)", s.ID, err, time.Local)
+		}
+		return time.Local
+	}
+	return zona
+}
+
+// Fecha devuelve el día en que empieza la sesión, en su zona horaria
+func (s *SesionAsistencia) Fecha() string {
+	return s.Inicio.In(s.Zona()).Format("2006-01-02")
+}
+
+// HoraInicio devuelve la hora de inicio de la sesión, en su zona horaria
+func (s *SesionAsistencia) HoraInicio() string {
+	return s.Inicio.In(s.Zona()).Format("15:04")
+}
+
+// HoraFin devuelve la hora de fin de la sesión, en su zona horaria, avisando si es del día siguiente
+func (s *SesionAsistencia) HoraFin() string {
+	zona := s.Zona()
+	fin := s.Fin.In(zona)
+	if fin.Format("2006-01-02") != s.Inicio.In(zona).Format("2006-01-02") {
+		return fin.Format("15:04") + " (día siguiente)"
+	}
+	return fin.Format("15:04")
+}
+
+// zonasHorarias guarda las zonas ya cargadas: cada sesión que se muestra necesita la suya
+// zonasInvalidas, las que no se pudieron cargar y ya se registraron
+var zonasHorarias, zonasInvalidas sync.Map
+
+// CargarZonaHoraria carga una zona horaria por su nombre IANA (por ejemplo, America/La_Paz)
+// Local es la del servidor; no se acepta el nombre vacío, que time.LoadLocation tomaría como UTC
+func CargarZonaHoraria(nombre string) (*time.Location, error) {
+	if zona, ok := zonasHorarias.Load(nombre); ok {
+		return zona.(*time.Location), nil
+	}
+	if nombre == "" {
+		return nil, fmt.Errorf("zona horaria vacía")
+	}
+	zona, err := time.LoadLocation(nombre)
+	if err != nil {
+		return nil, fmt.Errorf("zona horaria inválida: %q", nombre)
+	}
+	zonasHorarias.Store(nombre, zona)
+	return zona, nil
+}
+
+// horarioSesion convierte la fecha y las horas de inicio y fin ("2006-01-02", "15:04") en instantes de la zona
+// Si el fin es anterior al inicio, la sesión cruza la medianoche y termina al día siguiente
+func horarioSesion(fecha, horaInicio, horaFin string, zona *time.Location) (time.Time, time.Time, error) {
+	inicio, err := time.ParseInLocation("2006-01-02 15:04", fecha+" "+horaInicio, zona)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("fecha u hora de inicio inválida: %s %s", fecha, horaInicio)
+	}
+	fin, err := time.ParseInLocation("2006-01-02 15:04", fecha+" "+horaFin, zona)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("hora de fin inválida: %s", horaFin)
+	}
+	if fin.Before(inicio) {
+		fin = time.Date(fin.Year(), fin.Month(), fin.Day()+1, fin.Hour(), fin.Minute(), 0, 0, zona)
+	}
+	return inicio, fin, nil
+}
+
+// Modos de validación de metadatos de la foto de verificación
+const (
+	ModoMetadatosEstricto    = cadena_responsabilidad.ModoMetadatosEstricto
+	ModoMetadatosAdvertencia = cadena_responsabilidad.ModoMetadatosAdvertencia
+	ModoMetadatosDesactivado = cadena_responsabilidad.ModoMetadatosDesactivado
+)
+
+// VentanaSesion es el alias del horario de la sesión que usa la cadena de validadores
+type VentanaSesion = cadena_responsabilidad.VentanaSesion
+
+// EsModoMetadatosValido indica si modo es uno de los modos de validación de metadatos
+func EsModoMetadatosValido(modo string) bool {
+	return modo == ModoMetadatosEstricto || modo == ModoMetadatosAdvertencia || modo == ModoMetadatosDesactivado
+}
+
+// ReglasValidacionSesion es lo que la cadena de validadores necesita saber de la sesión
+// También el límite de tardanza, para calcular la condición de la asistencia al registrarla
+type ReglasValidacionSesion struct {
+	Umbrales       UmbralesSimilitud
+	ModoMetadatos  string
+	Ventana        VentanaSesion // Hasta el fin de la gracia: esas fotos también corresponden a la sesión
+	LimiteTardanza time.Time     // Inicio más la tolerancia
+}
+
+// CondicionLlegada devuelve presente o tarde según la hora de llegada y el límite de tardanza
+func (r ReglasValidacionSesion) CondicionLlegada(llegada time.Time) string {
+	if r.LimiteTardanza.IsZero() || !llegada.After(r.LimiteTardanza) {
+		return CondicionPresente
+	}
+	return CondicionTarde
+}
+
+// ErrEstudianteNoInscrito indica que el estudiante no está inscrito en la sesión
+var ErrEstudianteNoInscrito = errors.New("el estudiante no está inscrito en esta sesión")
+
+// La fecha y las horas están en la zona horaria del docente; una hora de fin anterior a la de inicio es del día siguiente
 type RegistrarSesionAsistenciaDto struct {
-	Fecha      string    `json:"fecha" binding:"required"`
-	HoraInicio string    `json:"hora_inicio" binding:"required"`
-	HoraFin    string    `json:"hora_fin" binding:"required"`
+	Fecha      string    `json:"fecha" binding:"required"`       // "2006-01-02"
+	HoraInicio string    `json:"hora_inicio" binding:"required"` // "15:04"
+	HoraFin    string    `json:"hora_fin" binding:"required"`    // "15:04"
 	DocenteID  uuid.UUID `json:"docente_id" binding:"required"`
+
+	UmbralAceptacion   *float64 `json:"umbral_aceptacion"`   // Opcional, entre 0 y 1
+	UmbralRevision     *float64 `json:"umbral_revision"`     // Opcional, entre 0 y 1
+	ModoMetadatos      *string  `json:"modo_metadatos"`      // Opcional: estricto, advertencia o desactivado
+	ToleranciaTardanza *int     `json:"tolerancia_tardanza"` // Opcional, en minutos
+	GraciaCierre       *int     `json:"gracia_cierre"`       // Opcional, en minutos
+
+	// Opcional: si no se indica ninguno se inscriben todos los estudiantes registrados al crear la sesión
+	EstudianteIDs []uuid.UUID `json:"estudiante_ids"`
+}
+
+// ActualizarConfiguracionSesionDto cambia la configuración propia de una sesión
+// Los campos nulos no se tocan; los nombrados en Restablecer vuelven al valor de la institución
+type ActualizarConfiguracionSesionDto struct {
+	UmbralAceptacion   *float64 `json:"umbral_aceptacion"`
+	UmbralRevision     *float64 `json:"umbral_revision"`
+	ModoMetadatos      *string  `json:"modo_metadatos"`
+	ToleranciaTardanza *int     `json:"tolerancia_tardanza"`
+	GraciaCierre       *int     `json:"gracia_cierre"`
+
+	// Nombres JSON de los campos a restablecer, por ejemplo ["tolerancia_tardanza"]
+	Restablecer []string `json:"restablecer"`
 }
 
 type SesionAsistenciaInterfaz interface {
 	RegistrarSesionAsistencia(dto *RegistrarSesionAsistenciaDto) (*SesionAsistencia, error)
 	ObtenerSesionAsistencia(id uuid.UUID) (*SesionAsistencia, error)
 	ObtenerSesionesAsistencia(DocenteID uuid.UUID) ([]SesionAsistencia, error)
+	UmbralesPorDefecto() UmbralesSimilitud
+	ModoMetadatosPorDefecto() string
+	ToleranciaTardanzaPorDefecto() int
+	GraciaCierrePorDefecto() int
+	ZonaHorariaDocente(docenteID uuid.UUID) (*time.Location, error)
+	ObtenerReglasValidacion(id uuid.UUID) (ReglasValidacionSesion, error)
+	ObtenerEstudiantesInscritos(id uuid.UUID) ([]Estudiante, error)
+	EstaInscrito(id, estudianteID uuid.UUID) (bool, error)
+	ActualizarConfiguracion(id, docenteID uuid.UUID, dto *ActualizarConfiguracionSesionDto) (*SesionAsistencia, error)
+	CambiarEstado(id, docenteID uuid.UUID, dto *CambiarEstadoSesionDto) (*SesionAsistencia, error)
+	ObtenerTransiciones(id uuid.UUID) ([]TransicionSesion, error)
+	ActualizarEstadosSesiones() error
 }
 
 type SesionAsistenciaModelo struct {
-	db *gorm.DB
+	db                      *gorm.DB
+	umbralesPorDefecto      UmbralesSimilitud
+	modoMetadatosPorDefecto string
+	toleranciaPorDefecto    int
+	graciaPorDefecto        int
+	zonaPorDefecto          *time.Location
 }
 
-func NuevaSesionAsistenciaModelo(db *gorm.DB) SesionAsistenciaInterfaz {
-	return &SesionAsistenciaModelo{db: db}
+// NuevaSesionAsistenciaModelo recibe los umbrales, el modo de validación de metadatos, la tolerancia de
+// tardanza y la gracia de cierre (en minutos) de la institución, que usan las sesiones sin valores propios,
+// y la zona horaria de la institución, en la que se programan las sesiones de los docentes sin zona propia
+func NuevaSesionAsistenciaModelo(db *gorm.DB, umbralesPorDefecto UmbralesSimilitud, modoMetadatosPorDefecto string, toleranciaPorDefecto, graciaPorDefecto int, zonaPorDefecto *time.Location) SesionAsistenciaInterfaz {
+	return &SesionAsistenciaModelo{
+		db:                      db,
+		umbralesPorDefecto:      umbralesPorDefecto,
+		modoMetadatosPorDefecto: modoMetadatosPorDefecto,
+		toleranciaPorDefecto:    toleranciaPorDefecto,
+		graciaPorDefecto:        graciaPorDefecto,
+		zonaPorDefecto:          zonaPorDefecto,
+	}
 }
 
 func (sam *SesionAsistenciaModelo) RegistrarSesionAsistencia(dto *RegistrarSesionAsistenciaDto) (*SesionAsistencia, error) {
 	var sesion SesionAsistencia
 
-	// Guardar directamente como strings sin conversión
+	// El horario se escribe en la zona del docente y se guarda como instantes junto con esa zona
+	zona, err := sam.ZonaHorariaDocente(dto.DocenteID)
+	if err != nil {
+		return nil, err
+	}
+	inicio, fin, err := horarioSesion(dto.Fecha, dto.HoraInicio, dto.HoraFin, zona)
+	if err != nil {
+		return nil, err
+	}
+	if fin.Equal(inicio) {
+		return nil, fmt.Errorf("la hora de fin debe ser distinta de la de inicio")
+	}
+
 	sesion.ID = uuid.New()
-	sesion.Fecha = dto.Fecha
-	sesion.HoraInicio = dto.HoraInicio
-	sesion.HoraFin = dto.HoraFin
+	sesion.Inicio = inicio
+	sesion.Fin = fin
+	sesion.ZonaHoraria = zona.String()
 	sesion.DocenteID = dto.DocenteID
+	sesion.Estado = EstadoSesionProgramada
+	sesion.UmbralAceptacion = dto.UmbralAceptacion
+	sesion.UmbralRevision = dto.UmbralRevision
+	sesion.ModoMetadatos = dto.ModoMetadatos
+	sesion.ToleranciaTardanza = dto.ToleranciaTardanza
+	sesion.GraciaCierre = dto.GraciaCierre
 
-	if err := sam.db.Create(&sesion).Error; err != nil {
+	if err := validarConfiguracionSesion(&sesion, sam.umbralesPorDefecto); err != nil {
 		return nil, err
 	}
 
+	inscritos, err := sam.resolverInscritos(dto.EstudianteIDs)
+	if err != nil {
+		return nil, err
+	}
+	sesion.Estudiantes = inscritos
+
+	// Los estudiantes ya existen: solo se crean las filas de la inscripción
+	if err := sam.db.Omit("Estudiantes.*").Create(&sesion).Error; err != nil {
+		return nil, err
+	}
+	// Una sesión creada durante su horario queda abierta
+	if err := sam.sincronizarEstado(sam.db, &sesion, time.Now()); err != nil {
+		log.Printf("Error al abrir la sesión %s: %v", sesion.ID, err)
+	}
+
 	return &sesion, nil
 }
 
+// resolverInscritos devuelve los estudiantes a inscribir en una sesión nueva: los indicados o, si no hay, todos
+func (sam *SesionAsistenciaModelo) resolverInscritos(ids []uuid.UUID) ([]Estudiante, error) {
+	var estudiantes []Estudiante
+	if len(ids) == 0 {
+		if err := sam.db.Select("id").Find(&estudiantes).Error; err != nil {
+			return nil, err
+		}
+		return estudiantes, nil
+	}
+	if err := sam.db.Select("id").Where("id IN ?", ids).Find(&estudiantes).Error; err != nil {
+		return nil, err
+	}
+	unicos := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		unicos[id] = true
+	}
+	if len(estudiantes) != len(unicos) {
+		return nil, fmt.Errorf("uno o más estudiantes inscritos no existen")
+	}
+	return estudiantes, nil
+}
+
+// ObtenerEstudiantesInscritos devuelve los estudiantes inscritos en la sesión, ordenados por apellidos
+// Sin la foto de referencia, que no se necesita para listarlos y pesa en sesiones grandes
+func (sam *SesionAsistenciaModelo) ObtenerEstudiantesInscritos(id uuid.UUID) ([]Estudiante, error) {
+	var estudiantes []Estudiante
+	err := sam.db.Omit("foto_referencia").Where("id IN (?)", sam.db.Table("sesion_estudiantes").Select("estudiante_id").Where("sesion_asistencia_id = ?", id)).
+		Order("apellidos, nombre").Find(&estudiantes).Error
+	return estudiantes, err
+}
+
+// EstaInscrito indica si el estudiante está inscrito en la sesión
+func (sam *SesionAsistenciaModelo) EstaInscrito(id, estudianteID uuid.UUID) (bool, error) {
+	var cantidad int64
+	err := sam.db.Table("sesion_estudiantes").
+		Where("sesion_asistencia_id = ? AND estudiante_id = ?", id, estudianteID).Count(&cantidad).Error
+	return cantidad > 0, err
+}
+
 func (sam *SesionAsistenciaModelo) ObtenerSesionAsistencia(id uuid.UUID) (*SesionAsistencia, error) {
 	var sesion SesionAsistencia
 
 	if err := sam.db.Where("id = ?", id).First(&sesion).Error; err != nil {
 		return nil, err
 	}
+	if err := sam.sincronizarEstado(sam.db, &sesion, time.Now()); err != nil {
+		return nil, err
+	}
 
 	return &sesion, nil
 }
@@ -69,6 +380,238 @@ func (sam *SesionAsistenciaModelo) ObtenerSesionesAsistencia(DocenteID uuid.UUID
 	if err := sam.db.Where("docente_id = ?", DocenteID).Find(&sesiones).Error; err != nil {
 		return nil, err
 	}
+	sam.sincronizarEstados(sesiones)
 
 	return sesiones, nil
 }
+
+func (sam *SesionAsistenciaModelo) UmbralesPorDefecto() UmbralesSimilitud {
+	return sam.umbralesPorDefecto
+}
+
+func (sam *SesionAsistenciaModelo) ModoMetadatosPorDefecto() string {
+	return sam.modoMetadatosPorDefecto
+}
+
+func (sam *SesionAsistenciaModelo) ToleranciaTardanzaPorDefecto() int {
+	return sam.toleranciaPorDefecto
+}
+
+func (sam *SesionAsistenciaModelo) GraciaCierrePorDefecto() int {
+	return sam.graciaPorDefecto
+}
+
+// ZonaHorariaDocente devuelve la zona horaria del docente o, si no tiene, la de la institución
+func (sam *SesionAsistenciaModelo) ZonaHorariaDocente(docenteID uuid.UUID) (*time.Location, error) {
+	var docente Docente
+	if err := sam.db.Select("id", "zona_horaria").Where("id = ?", docenteID).First(&docente).Error; err != nil {
+		return nil, fmt.Errorf("docente no encontrado")
+	}
+	return docente.ZonaHorariaVigente(sam.zonaPorDefecto), nil
+}
+
+// ObtenerReglasValidacion devuelve los umbrales, el modo de metadatos y el horario vigentes de la sesión
+// Si la sesión no existe devuelve los valores de la institución junto con el error, con la validación
+// de metadatos desactivada porque no hay horario contra el cual comparar
+func (sam *SesionAsistenciaModelo) ObtenerReglasValidacion(id uuid.UUID) (ReglasValidacionSesion, error) {
+	reglas := ReglasValidacionSesion{
+		Umbrales:      sam.umbralesPorDefecto,
+		ModoMetadatos: ModoMetadatosDesactivado,
+	}
+	sesion, err := sam.ObtenerSesionAsistencia(id)
+	if err != nil {
+		return reglas, err
+	}
+	reglas.Umbrales = sesion.Umbrales(sam.umbralesPorDefecto)
+
+	ventana := sesion.Ventana()
+	reglas.Ventana = ventana
+	reglas.Ventana.Fin = ventana.Fin.Add(time.Duration(sesion.GraciaCierreVigente(sam.graciaPorDefecto)) * time.Minute)
+	reglas.LimiteTardanza = ventana.Inicio.Add(time.Duration(sesion.ToleranciaTardanzaVigente(sam.toleranciaPorDefecto)) * time.Minute)
+	reglas.ModoMetadatos = sesion.ModoMetadatosVigente(sam.modoMetadatosPorDefecto)
+	return reglas, nil
+}
+
+// ActualizarConfiguracion cambia umbrales, modo de metadatos, tolerancia y gracia de una sesión del docente
+// Afecta solo a las asistencias que se registren desde ahora
+func (sam *SesionAsistenciaModelo) ActualizarConfiguracion(id, docenteID uuid.UUID, dto *ActualizarConfiguracionSesionDto) (*SesionAsistencia, error) {
+	var sesion SesionAsistencia
+	if err := sam.db.Where("id = ? AND docente_id = ?", id, docenteID).First(&sesion).Error; err != nil {
+		return nil, fmt.Errorf("sesión no encontrada")
+	}
+
+	// Cada campo con su columna; Select guarda también los nulos (volver al valor de la institución)
+	campos := []campoConfiguracion{
+		{"umbral_aceptacion", dto.UmbralAceptacion != nil,
+			func() { sesion.UmbralAceptacion = dto.UmbralAceptacion }, func() { sesion.UmbralAceptacion = nil }},
+		{"umbral_revision", dto.UmbralRevision != nil,
+			func() { sesion.UmbralRevision = dto.UmbralRevision }, func() { sesion.UmbralRevision = nil }},
+		{"modo_metadatos", dto.ModoMetadatos != nil,
+			func() { sesion.ModoMetadatos = dto.ModoMetadatos }, func() { sesion.ModoMetadatos = nil }},
+		{"tolerancia_tardanza", dto.ToleranciaTardanza != nil,
+			func() { sesion.ToleranciaTardanza = dto.ToleranciaTardanza }, func() { sesion.ToleranciaTardanza = nil }},
+		{"gracia_cierre", dto.GraciaCierre != nil,
+			func() { sesion.GraciaCierre = dto.GraciaCierre }, func() { sesion.GraciaCierre = nil }},
+	}
+
+	for _, nombre := range dto.Restablecer {
+		if !slices.ContainsFunc(campos, func(c campoConfiguracion) bool { return c.nombre == nombre }) {
+			return nil, fmt.Errorf("campo desconocido para restablecer: %q", nombre)
+		}
+	}
+
+	var columnas []string
+	for _, campo := range campos {
+		restablecer := slices.Contains(dto.Restablecer, campo.nombre)
+		switch {
+		case campo.presente && restablecer:
+			return nil, fmt.Errorf("%s no puede cambiarse y restablecerse a la vez", campo.nombre)
+		case campo.presente:
+			campo.aplicar()
+		case restablecer:
+			campo.restaurar()
+		default:
+			continue
+		}
+		columnas = append(columnas, campo.nombre)
+	}
+	if len(columnas) == 0 {
+		return nil, fmt.Errorf("no se indicó ningún cambio")
+	}
+
+	if err := validarConfiguracionSesion(&sesion, sam.umbralesPorDefecto); err != nil {
+		return nil, err
+	}
+	if err := sam.db.Model(&sesion).Select(columnas).Updates(&sesion).Error; err != nil {
+		return nil, err
+	}
+	return &sesion, nil
+}
+
+// campoConfiguracion es una columna de la configuración propia de la sesión: aplicar copia el valor
+// enviado y restaurar lo deja nulo
+type campoConfiguracion struct {
+	nombre    string
+	presente  bool
+	aplicar   func()
+	restaurar func()
+}
+
+// validarConfiguracionSesion revisa la configuración propia de una sesión al crearla o cambiarla
+func validarConfiguracionSesion(sesion *SesionAsistencia, umbralesPorDefecto UmbralesSimilitud) error {
+	if err := validarUmbralesSesion(sesion, umbralesPorDefecto); err != nil {
+		return err
+	}
+	if sesion.ModoMetadatos != nil && !EsModoMetadatosValido(*sesion.ModoMetadatos) {
+		return fmt.Errorf("modo de validación de metadatos inválido: %q", *sesion.ModoMetadatos)
+	}
+	if err := validarToleranciaTardanza(sesion.ToleranciaTardanza); err != nil {
+		return err
+	}
+	return validarGraciaCierre(sesion.GraciaCierre)
+}
+
+func validarGraciaCierre(minutos *int) error {
+	if minutos != nil && (*minutos < 0 || *minutos > 24*60) {
+		return fmt.Errorf("la gracia de cierre debe estar entre 0 y %d minutos", 24*60)
+	}
+	return nil
+}
+
+func validarToleranciaTardanza(minutos *int) error {
+	if minutos != nil && (*minutos < 0 || *minutos > 24*60) {
+		return fmt.Errorf("la tolerancia de tardanza debe estar entre 0 y %d minutos", 24*60)
+	}
+	return nil
+}
+
+// validarUmbralesSesion exige umbrales entre 0 y 1 y que, ya combinados con los de la institución,
+// el de revisión no supere al de aceptación
+func validarUmbralesSesion(sesion *SesionAsistencia, porDefecto UmbralesSimilitud) error {
+	for _, umbral := range []*float64{sesion.UmbralAceptacion, sesion.UmbralRevision} {
+		if umbral != nil && (*umbral < 0 || *umbral > 1) {
+			return fmt.Errorf("los umbrales deben estar entre 0 y 1")
+		}
+	}
+
+	umbrales := sesion.Umbrales(porDefecto)
+	if umbrales.Revision > umbrales.Aceptacion {
+		return fmt.Errorf("el umbral de revisión (%.0f%%) no puede ser mayor que el de aceptación (%.0f%%)", umbrales.Revision*100, umbrales.Aceptacion*100)
+	}
+	return nil
+}
+
+// MigrarHorariosSesiones pasa el horario de las sesiones anteriores, guardado como texto (fecha, hora de inicio
+// y de fin en la hora del servidor), a instantes: el texto se lee en time.Local, la zona en que se escribió y se
+// comparaba, y la sesión queda en la zona de la institución, en la que se mostrará. Las que terminaban antes
+// de empezar cruzaban la medianoche. Corre antes de AutoMigrate: con sesiones guardadas no se pueden agregar
+// las columnas nuevas como NOT NULL sin llenarlas primero
+func MigrarHorariosSesiones(db *gorm.DB, zona *time.Location) (int, error) {
+	if !db.Migrator().HasTable(&SesionAsistencia{}) || !db.Migrator().HasColumn(&SesionAsistencia{}, "hora_inicio") {
+		return 0, nil
+	}
+
+	var horarios []struct {
+		ID         uuid.UUID
+		Fecha      string
+		HoraInicio string
+		HoraFin    string
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`ALTER TABLE sesion_asistencias ADD COLUMN IF NOT EXISTS inicio timestamptz,
+			ADD COLUMN IF NOT EXISTS fin timestamptz, ADD COLUMN IF NOT EXISTS zona_horaria varchar(64)`).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("sesion_asistencias").Select("id", "fecha", "hora_inicio", "hora_fin").Find(&horarios).Error; err != nil {
+			return err
+		}
+		for _, h := range horarios {
+			inicio, fin, err := horarioSesion(h.Fecha, h.HoraInicio, h.HoraFin, time.Local)
+			if err != nil {
+				return fmt.Errorf("sesión %s: %v", h.ID, err)
+			}
+			if err := tx.Table("sesion_asistencias").Where("id = ?", h.ID).
+				Updates(map[string]interface{}{"inicio": inicio, "fin": fin, "zona_horaria": zona.String()}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Exec(`ALTER TABLE sesion_asistencias ALTER COLUMN inicio SET NOT NULL, ALTER COLUMN fin SET NOT NULL,
+			ALTER COLUMN zona_horaria SET NOT NULL, DROP COLUMN fecha, DROP COLUMN hora_inicio, DROP COLUMN hora_fin`).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(horarios), nil
+}
+
+// MigrarInscripcionesSesiones inscribe a los estudiantes en las sesiones creadas antes de que existieran las
+// inscripciones. En las que ya terminaron (gracia incluida) se inscribe solo a quienes tienen un registro y se
+// dan por procesadas: no se sabe quiénes debían asistir, y marcar ausente a todos inventaría ausencias,
+// incluso de estudiantes registrados después. Las demás inscriben a todos los estudiantes actuales.
+// Corre solo cuando la tabla de inscripciones se acaba de crear; devuelve cuántas sesiones terminadas procesó
+func MigrarInscripcionesSesiones(db *gorm.DB, graciaPorDefecto int) (int, error) {
+	ahora := time.Now()
+	terminada := "s.fin + make_interval(mins => COALESCE(s.gracia_cierre, ?)) <= ?"
+	var procesadas int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`INSERT INTO sesion_estudiantes (sesion_asistencia_id, estudiante_id)
+			SELECT DISTINCT a.sesion_asistencia_id, a.estudiante_id FROM asistencias a
+			JOIN sesion_asistencias s ON s.id = a.sesion_asistencia_id WHERE `+terminada+`
+			ON CONFLICT DO NOTHING`, graciaPorDefecto, ahora).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`INSERT INTO sesion_estudiantes (sesion_asistencia_id, estudiante_id)
+			SELECT s.id, e.id FROM sesion_asistencias s CROSS JOIN estudiantes e WHERE NOT (`+terminada+`)
+			ON CONFLICT DO NOTHING`, graciaPorDefecto, ahora).Error; err != nil {
+			return err
+		}
+		resultado := tx.Exec(`UPDATE sesion_asistencias s SET ausencias_marcadas_en = ?
+			WHERE ausencias_marcadas_en IS NULL AND `+terminada, ahora, graciaPorDefecto, ahora)
+		procesadas = resultado.RowsAffected
+		return resultado.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(procesadas), nil
+}
@@ -0,0 +1,85 @@
+package modelo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHorarioSesion(t *testing.T) {
+	laPaz := time.FixedZone("BOT", -4*60*60)
+	casos := []struct {
+		nombre                      string
+		fecha, inicio, fin          string
+		inicioEsperado, finEsperado time.Time
+		err                         bool
+	}{
+		{
+			nombre: "mismo día", fecha: "2026-03-10", inicio: "08:00", fin: "10:30",
+			inicioEsperado: time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC),
+			finEsperado:    time.Date(2026, 3, 10, 14, 30, 0, 0, time.UTC),
+		},
+		{
+			nombre: "cruza la medianoche", fecha: "2026-03-10", inicio: "22:00", fin: "01:00",
+			inicioEsperado: time.Date(2026, 3, 11, 2, 0, 0, 0, time.UTC),
+			finEsperado:    time.Date(2026, 3, 11, 5, 0, 0, 0, time.UTC),
+		},
+		{
+			nombre: "cruza fin de mes", fecha: "2026-01-31", inicio: "23:30", fin: "00:15",
+			inicioEsperado: time.Date(2026, 2, 1, 3, 30, 0, 0, time.UTC),
+			finEsperado:    time.Date(2026, 2, 1, 4, 15, 0, 0, time.UTC),
+		},
+		{
+			nombre: "cruza fin de año", fecha: "2026-12-31", inicio: "23:00", fin: "00:30",
+			inicioEsperado: time.Date(2027, 1, 1, 3, 0, 0, 0, time.UTC),
+			finEsperado:    time.Date(2027, 1, 1, 4, 30, 0, 0, time.UTC),
+		},
+		{
+			nombre: "fin igual al inicio", fecha: "2026-03-10", inicio: "08:00", fin: "08:00",
+			inicioEsperado: time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC),
+			finEsperado:    time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC),
+		},
+		{nombre: "fecha inválida", fecha: "10/03/2026", inicio: "08:00", fin: "10:00", err: true},
+		{nombre: "hora de fin inválida", fecha: "2026-03-10", inicio: "08:00", fin: "25:00", err: true},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			inicio, fin, err := horarioSesion(c.fecha, c.inicio, c.fin, laPaz)
+			if c.err {
+				if err == nil {
+					t.Fatalf("se esperaba un error, se obtuvo %v - %v", inicio, fin)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !inicio.Equal(c.inicioEsperado) || !fin.Equal(c.finEsperado) {
+				t.Errorf("horario %v - %v, se esperaba %v - %v", inicio, fin, c.inicioEsperado, c.finEsperado)
+			}
+		})
+	}
+}
+
+func TestCondicionLlegada(t *testing.T) {
+	limite := time.Date(2026, 3, 10, 8, 10, 0, 0, time.UTC)
+	casos := []struct {
+		nombre    string
+		limite    time.Time
+		llegada   time.Time
+		condicion string
+	}{
+		{"antes del límite", limite, limite.Add(-5 * time.Minute), CondicionPresente},
+		{"justo en el límite", limite, limite, CondicionPresente},
+		{"un segundo después", limite, limite.Add(time.Second), CondicionTarde},
+		{"mucho después", limite, limite.Add(2 * time.Hour), CondicionTarde},
+		{"sin límite", time.Time{}, limite.Add(2 * time.Hour), CondicionPresente},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			reglas := ReglasValidacionSesion{LimiteTardanza: c.limite}
+			if got := reglas.CondicionLlegada(c.llegada); got != c.condicion {
+				t.Errorf("CondicionLlegada = %s, se esperaba %s", got, c.condicion)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package modelo
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Orígenes de una sospecha de suplantación
+const (
+	// OrigenSospechaAsistencia: la foto se parece más a la de otra asistencia de la misma sesión
+	OrigenSospechaAsistencia = "asistencia"
+	// OrigenSospechaReferencia: la foto se parece más a las referencias de otro estudiante
+	OrigenSospechaReferencia = "referencia"
+)
+
+// SospechaSuplantacion marca una asistencia aceptada cuya foto se parece más a otra persona que al estudiante
+// No invalida la asistencia: queda para que el docente la revise
+type SospechaSuplantacion struct {
+	ID              uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	Origen          string    `gorm:"type:varchar(20);not null"`
+	Similitud       float64   `gorm:"type:decimal(5,4)"` // Similitud con la otra persona
+	SimilitudPropia float64   `gorm:"type:decimal(5,4)"` // Similitud con las referencias del propio estudiante
+	CreadoEn        time.Time `gorm:"not null"`
+
+	AsistenciaID       uuid.UUID `gorm:"type:uuid;not null;index"`
+	SesionAsistenciaID uuid.UUID `gorm:"type:uuid;not null;index"`
+	// EstudianteSimilarID es el estudiante al que se parece la foto
+	EstudianteSimilarID uuid.UUID `gorm:"type:uuid;not null"`
+	// AsistenciaRelacionadaID es la otra asistencia de la sesión (solo con origen "asistencia")
+	AsistenciaRelacionadaID *uuid.UUID `gorm:"type:uuid"`
+
+	Asistencia        Asistencia `gorm:"foreignKey:AsistenciaID"`
+	EstudianteSimilar Estudiante `gorm:"foreignKey:EstudianteSimilarID"`
+}
+
+// detectarSuplantacion compara la foto de una asistencia recién aceptada con las demás asistencias de la sesión
+// y con las referencias de los otros estudiantes inscritos en ella. Si se parece más a otra persona que al propio estudiante,
+// registra una sospecha por cada persona (la mejor coincidencia de cada una). Indica si hubo alguna
+func (am *AsistenciaModelo) detectarSuplantacion(asistencia *Asistencia) (bool, error) {
+	if len(asistencia.CaracteristicasVerificacion) == 0 {
+		return false, nil
+	}
+
+	var sospechas []SospechaSuplantacion
+	nuevaSospecha := func(origen string, estudianteID uuid.UUID, similitud float64) SospechaSuplantacion {
+		return SospechaSuplantacion{
+			ID:                  uuid.New(),
+			Origen:              origen,
+			Similitud:           similitud,
+			SimilitudPropia:     asistencia.Similitud,
+			CreadoEn:            time.Now(),
+			AsistenciaID:        asistencia.ID,
+			SesionAsistenciaID:  asistencia.SesionAsistenciaID,
+			EstudianteSimilarID: estudianteID,
+		}
+	}
+
+	// Otras asistencias de la misma sesión: una misma cara registrando a varios compañeros
+	// Solo se comparan las generadas por el mismo algoritmo y versión
+	var otras []Asistencia
+	if err := am.db.Select("id", "estudiante_id", "caracteristicas_verificacion").
+		Where("sesion_asistencia_id = ? AND id <> ? AND estudiante_id <> ?", asistencia.SesionAsistenciaID, asistencia.ID, asistencia.EstudianteID).
+		Where("algoritmo = ? AND version = ?", asistencia.Algoritmo, asistencia.Version).
+		Find(&otras).Error; err != nil {
+		return false, err
+	}
+
+	for _, otra := range otras {
+		similitud, err := am.matcher.Comparar(otra.CaracteristicasVerificacion, asistencia.CaracteristicasVerificacion)
+		if err != nil {
+			return false, err
+		}
+		if similitud > asistencia.Similitud {
+			sospecha := nuevaSospecha(OrigenSospechaAsistencia, otra.EstudianteID, similitud)
+			sospecha.AsistenciaRelacionadaID = &otra.ID
+			sospechas = append(sospechas, sospecha)
+		}
+	}
+
+	// Referencias de los demás inscritos en la sesión: solo las características ya precalculadas
+	var referencias []CaracteristicasReferencia
+	if err := am.db.Select("estudiante_id", "datos").
+		Where("estudiante_id <> ? AND algoritmo = ? AND version = ?", asistencia.EstudianteID, asistencia.Algoritmo, asistencia.Version).
+		Where("estudiante_id IN (?)", am.db.Table("sesion_estudiantes").Select("estudiante_id").
+			Where("sesion_asistencia_id = ?", asistencia.SesionAsistenciaID)).
+		Find(&referencias).Error; err != nil {
+		return false, err
+	}
+
+	mejorPorEstudiante := make(map[uuid.UUID]float64)
+	for _, ref := range referencias {
+		similitud, err := am.matcher.Comparar(ref.Datos, asistencia.CaracteristicasVerificacion)
+		if err != nil {
+			return false, err
+		}
+		if similitud > mejorPorEstudiante[ref.EstudianteID] {
+			mejorPorEstudiante[ref.EstudianteID] = similitud
+		}
+	}
+	for estudianteID, similitud := range mejorPorEstudiante {
+		if similitud > asistencia.Similitud {
+			sospechas = append(sospechas, nuevaSospecha(OrigenSospechaReferencia, estudianteID, similitud))
+		}
+	}
+
+	if len(sospechas) == 0 {
+		return false, nil
+	}
+	return true, am.db.Create(&sospechas).Error
+}
+
+// ObtenerSospechasPorSesion lista las sospechas de suplantación de una sesión, de mayor a menor similitud
+// Incluye el estudiante al que se parece cada foto
+func (am *AsistenciaModelo) ObtenerSospechasPorSesion(sesionID uuid.UUID) ([]SospechaSuplantacion, error) {
+	var sospechas []SospechaSuplantacion
+	err := am.db.Preload("EstudianteSimilar").Preload("Asistencia.Estudiante").
+		Where("sesion_asistencia_id = ?", sesionID).Order("similitud DESC").Find(&sospechas).Error
+	return sospechas, err
+}
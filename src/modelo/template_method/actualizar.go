@@ -12,15 +12,18 @@ import (
 // ProcesadorActualizar implementa ProcesadorEstudiante para ACTUALIZAR estudiantes
 type ProcesadorActualizar struct {
 	db               *gorm.DB
+	matcher          helper.FaceMatcher
 	estudianteID     string
 	datosEntrada     *ActualizarEstudianteDto
 	estudianteResult *Estudiante
+	fotoAnterior     string
 }
 
 // NewProcesadorActualizar crea una instancia
-func NewProcesadorActualizar(db *gorm.DB, estudianteID string, datos *ActualizarEstudianteDto) *ProcesadorActualizar {
+func NewProcesadorActualizar(db *gorm.DB, matcher helper.FaceMatcher, estudianteID string, datos *ActualizarEstudianteDto) *ProcesadorActualizar {
 	return &ProcesadorActualizar{
 		db:               db,
+		matcher:          matcher,
 		estudianteID:     estudianteID,
 		datosEntrada:     datos,
 		estudianteResult: nil,
@@ -46,7 +49,7 @@ func (a *ProcesadorActualizar) ValidarEntrada() error {
 // VerificarPrecondicion: Para ACTUALIZAR, verificar que SÍ existe
 func (a *ProcesadorActualizar) VerificarPrecondicion() error {
 	a.estudianteResult = &Estudiante{}
-	result := a.db.First(a.estudianteResult, a.estudianteID)
+	result := a.db.First(a.estudianteResult, "id = ?", a.estudianteID)
 
 	if result.Error == gorm.ErrRecordNotFound {
 		return errors.New("estudiante no encontrado")
@@ -56,6 +59,9 @@ func (a *ProcesadorActualizar) VerificarPrecondicion() error {
 		return result.Error
 	}
 
+	// Recordar la foto actual para saber luego si cambió
+	a.fotoAnterior = a.estudianteResult.FotoReferencia
+
 	return nil
 }
 
@@ -74,26 +80,37 @@ func (a *ProcesadorActualizar) PrepararEstudiante() error {
 	return nil
 }
 
-// ValidarFotoReferencia: Validar que la foto sea base64 válido (si se actualiza)
+// ValidarFotoReferencia: Validar que la foto sea base64 válido y supere el control de calidad (si se actualiza)
+// Si falla, el error es *helper.ErrorCalidadFoto con todos los controles que no pasó
 func (a *ProcesadorActualizar) ValidarFotoReferencia() error {
 	if a.estudianteResult == nil {
 		return errors.New("estudiante no preparado")
 	}
 
-	// Solo validar si la foto se actualiza
-	if a.datosEntrada.FotoReferencia != nil && *a.datosEntrada.FotoReferencia != "" {
-		return helper.ValidarImagenBase64(*a.datosEntrada.FotoReferencia)
+	// Solo validar si la foto cambia: el formulario de edición reenvía la foto actual
+	if a.datosEntrada.FotoReferencia != nil && *a.datosEntrada.FotoReferencia != "" &&
+		*a.datosEntrada.FotoReferencia != a.fotoAnterior {
+		return helper.ValidarCalidadFotoReferencia(*a.datosEntrada.FotoReferencia)
 	}
 
 	return nil
 }
 
 // GuardarEnBD: Actualizar estudiante en BD (SAVE para ACTUALIZAR)
+// Si la foto cambió, se reemplaza la referencia facial principal y sus características
 func (a *ProcesadorActualizar) GuardarEnBD() error {
 	if a.estudianteResult == nil {
 		return errors.New("estudiante no preparado")
 	}
-	return a.db.Save(a.estudianteResult).Error
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(a.estudianteResult).Error; err != nil {
+			return err
+		}
+		if a.estudianteResult.FotoReferencia == "" || a.estudianteResult.FotoReferencia == a.fotoAnterior {
+			return nil
+		}
+		return GuardarReferenciaPrincipal(tx, a.matcher, a.estudianteResult.ID, a.estudianteResult.FotoReferencia)
+	})
 }
 
 // ObtenerResultado: Retornar el estudiante actualizado
@@ -0,0 +1,70 @@
+package template_method
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GuardarCaracteristicasReferencia extrae las características de la foto de una referencia y las guarda (o reemplaza)
+// Lo usan los procesadores al guardar la foto, el alta de referencias y el backfill de las existentes
+func GuardarCaracteristicasReferencia(db *gorm.DB, matcher helper.FaceMatcher, referencia *ReferenciaFacial) (*CaracteristicasReferencia, error) {
+	datos, err := matcher.ExtraerCaracteristicas(referencia.Foto)
+	if err != nil {
+		return nil, fmt.Errorf("error al extraer características de la foto de referencia: %w", err)
+	}
+
+	registro := &CaracteristicasReferencia{
+		ID:                 uuid.New(),
+		ReferenciaFacialID: referencia.ID,
+		EstudianteID:       referencia.EstudianteID,
+		Algoritmo:          matcher.Algoritmo(),
+		Version:            matcher.Version(),
+		Datos:              datos,
+		ActualizadoEn:      time.Now(),
+	}
+
+	// Un único registro por referencia: si ya existe se reemplaza
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "referencia_facial_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"algoritmo", "version", "datos", "actualizado_en"}),
+	}).Create(registro).Error; err != nil {
+		return nil, err
+	}
+
+	return registro, nil
+}
+
+// GuardarReferenciaPrincipal crea o reemplaza la referencia principal del estudiante (la de FotoReferencia)
+// y calcula sus características
+func GuardarReferenciaPrincipal(db *gorm.DB, matcher helper.FaceMatcher, estudianteID uuid.UUID, foto string) error {
+	var referencia ReferenciaFacial
+
+	err := db.Where("estudiante_id = ? AND principal = ?", estudianteID, true).First(&referencia).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		referencia = ReferenciaFacial{
+			ID:           uuid.New(),
+			EstudianteID: estudianteID,
+			Descripcion:  "Foto de registro",
+			Principal:    true,
+			Origen:       OrigenReferenciaManual,
+		}
+	} else if err != nil {
+		return err
+	}
+
+	referencia.Foto = foto
+	referencia.CreadoEn = time.Now()
+
+	if err := db.Save(&referencia).Error; err != nil {
+		return err
+	}
+
+	_, err = GuardarCaracteristicasReferencia(db, matcher, &referencia)
+	return err
+}
@@ -13,14 +13,16 @@ import (
 // ProcesadorRegistrar implementa ProcesadorEstudiante para REGISTRAR estudiantes
 type ProcesadorRegistrar struct {
 	db               *gorm.DB
+	matcher          helper.FaceMatcher
 	datosEntrada     *RegistrarEstudianteDto
 	estudianteResult *Estudiante
 }
 
 // NewProcesadorRegistrar crea una instancia
-func NewProcesadorRegistrar(db *gorm.DB, datos *RegistrarEstudianteDto) *ProcesadorRegistrar {
+func NewProcesadorRegistrar(db *gorm.DB, matcher helper.FaceMatcher, datos *RegistrarEstudianteDto) *ProcesadorRegistrar {
 	return &ProcesadorRegistrar{
 		db:               db,
+		matcher:          matcher,
 		datosEntrada:     datos,
 		estudianteResult: nil,
 	}
@@ -75,7 +77,8 @@ func (r *ProcesadorRegistrar) PrepararEstudiante() error {
 	return nil
 }
 
-// ValidarFotoReferencia: Validar que la foto sea base64 válido
+// ValidarFotoReferencia: Validar que la foto sea base64 válido y supere el control de calidad
+// Si falla, el error es *helper.ErrorCalidadFoto con todos los controles que no pasó
 func (r *ProcesadorRegistrar) ValidarFotoReferencia() error {
 	if r.estudianteResult == nil {
 		return errors.New("estudiante no preparado")
@@ -83,15 +86,24 @@ func (r *ProcesadorRegistrar) ValidarFotoReferencia() error {
 	if r.estudianteResult.FotoReferencia == "" {
 		return nil
 	}
-	return helper.ValidarImagenBase64(r.estudianteResult.FotoReferencia)
+	return helper.ValidarCalidadFotoReferencia(r.estudianteResult.FotoReferencia)
 }
 
 // GuardarEnBD: Crear estudiante en BD (CREATE para REGISTRAR)
+// Junto con el estudiante se guarda su foto como referencia facial principal, con sus características
 func (r *ProcesadorRegistrar) GuardarEnBD() error {
 	if r.estudianteResult == nil {
 		return errors.New("estudiante no preparado")
 	}
-	return r.db.Create(r.estudianteResult).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(r.estudianteResult).Error; err != nil {
+			return err
+		}
+		if r.estudianteResult.FotoReferencia == "" {
+			return nil
+		}
+		return GuardarReferenciaPrincipal(tx, r.matcher, r.estudianteResult.ID, r.estudianteResult.FotoReferencia)
+	})
 }
 
 // ObtenerResultado: Retornar el estudiante creado
@@ -1,6 +1,10 @@
 package template_method
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // Estudiante es el modelo de la base de datos
 type Estudiante struct {
@@ -11,6 +15,42 @@ type Estudiante struct {
 	FotoReferencia string    `gorm:"type:text"`
 }
 
+// Origen de una referencia facial
+const (
+	OrigenReferenciaManual     = "manual"
+	OrigenReferenciaAutomatica = "automatica"
+)
+
+// ReferenciaFacial es una foto de enrolamiento del estudiante
+// Un estudiante puede tener varias (distinta luz, con y sin lentes, etc.); la Principal es la de FotoReferencia
+// Las de origen "automatica" son fotos de verificación con similitud alta que se agregaron solas
+type ReferenciaFacial struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;"`
+	EstudianteID uuid.UUID  `gorm:"type:uuid;index;not null"`
+	Foto         string     `gorm:"type:text;not null"`
+	Descripcion  string     `gorm:"type:varchar(100)"`
+	Principal    bool       `gorm:"not null;default:false"`
+	Origen       string     `gorm:"type:varchar(20);not null;default:'manual'"`
+	AsistenciaID *uuid.UUID `gorm:"type:uuid"` // Asistencia de la que salió la foto (solo automáticas)
+	CreadoEn     time.Time  `gorm:"not null"`
+
+	Estudiante Estudiante `gorm:"foreignKey:EstudianteID"`
+}
+
+// CaracteristicasReferencia guarda las características faciales precalculadas de una referencia facial
+// Se generan al guardar la foto, así la verificación no necesita decodificarla en cada asistencia
+type CaracteristicasReferencia struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	ReferenciaFacialID uuid.UUID `gorm:"type:uuid;uniqueIndex;not null"`
+	EstudianteID       uuid.UUID `gorm:"type:uuid;index;not null"`
+	Algoritmo          string    `gorm:"type:varchar(100);not null;default:'histograma-rgb'"`
+	Version            string    `gorm:"type:varchar(50);not null"`
+	Datos              []byte    `gorm:"type:bytea;not null"`
+	ActualizadoEn      time.Time `gorm:"not null"`
+
+	ReferenciaFacial ReferenciaFacial `gorm:"foreignKey:ReferenciaFacialID;constraint:OnDelete:CASCADE"`
+}
+
 // RegistrarEstudianteDto DTO para registrar un estudiante
 type RegistrarEstudianteDto struct {
 	Nombre         string `json:"nombre" binding:"required"`
@@ -0,0 +1,92 @@
+package modelo
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/MetaDandy/Assistense-System/helper"
+	"github.com/MetaDandy/Assistense-System/src/modelo/cadena_responsabilidad"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Modos de la prueba de vivacidad (ver cadena_responsabilidad.ValidadorVivacidad)
+const (
+	ModoVivacidadObligatoria = cadena_responsabilidad.ModoVivacidadObligatoria
+	ModoVivacidadOpcional    = cadena_responsabilidad.ModoVivacidadOpcional
+	ModoVivacidadDesactivada = cadena_responsabilidad.ModoVivacidadDesactivada
+)
+
+// EsModoVivacidadValido indica si modo es uno de los modos de la prueba de vivacidad
+func EsModoVivacidadValido(modo string) bool {
+	return modo == ModoVivacidadObligatoria || modo == ModoVivacidadOpcional || modo == ModoVivacidadDesactivada
+}
+
+// VigenciaDesafio es el tiempo que tiene el estudiante para responder un desafío de vivacidad
+const VigenciaDesafio = 60 * time.Second
+
+// DesafioVivacidad es un nonce de un solo uso que liga los fotogramas de una asistencia a una acción pedida
+type DesafioVivacidad struct {
+	ID       uuid.UUID `gorm:"type:uuid;primaryKey;"` // Es el nonce que envía el cliente
+	Accion   string    `gorm:"type:varchar(30);not null"`
+	CreadoEn time.Time `gorm:"not null"`
+	ExpiraEn time.Time `gorm:"not null"`
+	Usado    bool      `gorm:"default:false"`
+
+	EstudianteID       uuid.UUID `gorm:"type:uuid;not null"`
+	SesionAsistenciaID uuid.UUID `gorm:"type:uuid;not null"`
+}
+
+// CrearDesafioVivacidad emite un desafío con una acción al azar para el estudiante en la sesión
+// Con estudianteID uuid.Nil el desafío es de la sesión y lo puede usar cualquier estudiante:
+// es el de la identificación 1:N, donde todavía no se sabe quién está frente a la cámara
+func (am *AsistenciaModelo) CrearDesafioVivacidad(estudianteID, sesionID uuid.UUID) (*DesafioVivacidad, error) {
+	if estudianteID != uuid.Nil {
+		if _, err := am.estudianteModelo.ObtenerEstudiantePorID(estudianteID); err != nil {
+			return nil, errors.New("estudiante no encontrado")
+		}
+	}
+	if _, err := am.sesionModelo.ObtenerSesionAsistencia(sesionID); err != nil {
+		return nil, errors.New("sesión no encontrada")
+	}
+
+	ahora := time.Now()
+	desafio := &DesafioVivacidad{
+		ID:                 uuid.New(),
+		Accion:             helper.AccionesVivacidad[rand.Intn(len(helper.AccionesVivacidad))],
+		CreadoEn:           ahora,
+		ExpiraEn:           ahora.Add(VigenciaDesafio),
+		EstudianteID:       estudianteID,
+		SesionAsistenciaID: sesionID,
+	}
+
+	if err := am.db.Create(desafio).Error; err != nil {
+		return nil, err
+	}
+	return desafio, nil
+}
+
+// consumirDesafio marca el desafío como usado en una sola sentencia, para que dos envíos concurrentes
+// con el mismo nonce no puedan pasar ambos
+func (am *AsistenciaModelo) consumirDesafio(desafioID, estudianteID, sesionID uuid.UUID) (string, error) {
+	resultado := am.db.Model(&DesafioVivacidad{}).
+		Where("id = ? AND estudiante_id IN (?, ?) AND sesion_asistencia_id = ?", desafioID, estudianteID, uuid.Nil, sesionID).
+		Where("usado = ? AND expira_en > ?", false, time.Now()).
+		Update("usado", true)
+	if resultado.Error != nil {
+		return "", resultado.Error
+	}
+	if resultado.RowsAffected == 0 {
+		return "", cadena_responsabilidad.ErrDesafioInvalido
+	}
+
+	var desafio DesafioVivacidad
+	if err := am.db.Select("accion").First(&desafio, "id = ?", desafioID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", cadena_responsabilidad.ErrDesafioInvalido
+		}
+		return "", err
+	}
+	return desafio.Accion, nil
+}
@@ -13,20 +13,51 @@ func SetupRoutes() *mux.Router {
 
 	docenteModelo := modelo.NuevoDocenteModelo(config.DB)
 	docenteVista := vista.NuevoDocenteVistaHTML()
-	docenteControlador := controlador.NuevoDocenteControlador(docenteModelo, docenteVista)
 
-	estudianteModelo := modelo.NuevoEstudianteModelo(config.DB)
+	estudianteModelo := modelo.NuevoEstudianteModelo(config.DB, config.FaceMatcher)
 	estudianteVista := vista.NuevaEstudianteVistaHTML()
 	estudianteControlador := controlador.NuevoEstudianteControlador(estudianteModelo, estudianteVista)
 
-	sesionModelo := modelo.NuevaSesionAsistenciaModelo(config.DB)
+	sesionModelo := modelo.NuevaSesionAsistenciaModelo(config.DB,
+		modelo.UmbralesSimilitud{Aceptacion: config.UmbralAceptacion, Revision: config.UmbralRevision},
+		config.ModoMetadatos, config.ToleranciaTardanza, config.GraciaCierre, config.ZonaHoraria)
 	sesionVista := vista.NuevaSesionAsistenciaVistaHTML()
-	asistenciaModelo := modelo.NuevoAsistenciaModelo(config.DB, estudianteModelo, sesionModelo)
+	politicaReferencias := modelo.PoliticaReferenciasAdaptativas{
+		Habilitada:        config.ReferenciasAdaptativas,
+		MargenSimilitud:   config.MargenReferencias,
+		MaximoAutomaticas: config.MaximoReferencias,
+		AntiguedadMaxima:  config.VigenciaReferencias,
+	}
+	asistenciaModelo := modelo.NuevoAsistenciaModelo(config.DB, config.FaceMatcher, politicaReferencias,
+		config.MargenIdentificacion, config.ModoVivacidad, estudianteModelo, sesionModelo)
 	sesionControlador := controlador.NuevoSesionAsistenciaControlador(sesionModelo, estudianteModelo, sesionVista)
 
+	justificacionModelo := modelo.NuevaJustificacionModelo(config.DB, sesionModelo)
+	docenteControlador := controlador.NuevoDocenteControlador(docenteModelo, justificacionModelo, docenteVista)
+
+	// Características de las referencias que falten, para no calcularlas durante una identificación
+	go modelo.PrecalcularCaracteristicas(estudianteModelo)
+
+	// Apertura y cierre de las sesiones por su horario
+	go modelo.ProgramarEstadosSesiones(sesionModelo)
+
+	// Marcado de ausencias de las sesiones que se cierran
+	if config.IntervaloAusencias > 0 {
+		go modelo.ProgramarMarcadoAusencias(asistenciaModelo, config.IntervaloAusencias)
+	}
+
+	// Retención de las fotos de los intentos rechazados
+	go modelo.ProgramarPurgaIntentosRechazados(asistenciaModelo, config.RetencionIntentos)
+
+	// Vencimiento de las referencias automáticas de estudiantes que no vuelven a registrar asistencia
+	go modelo.ProgramarVencimientoReferencias(estudianteModelo, politicaReferencias)
+
 	asistenciaVista := vista.NuevaAsistenciaVistaHTML()
 	asistenciaControlador := controlador.NuevoAsistenciaControlador(asistenciaModelo, estudianteModelo, sesionModelo, asistenciaVista)
 
+	justificacionVista := vista.NuevaJustificacionVistaHTML()
+	justificacionControlador := controlador.NuevoJustificacionControlador(justificacionModelo, estudianteModelo, sesionModelo, justificacionVista)
+
 	// Página principal
 	r.HandleFunc("/", docenteControlador.MostrarInicio).Methods("GET")
 
@@ -38,6 +69,7 @@ func SetupRoutes() *mux.Router {
 	r.HandleFunc("/registro", docenteControlador.ProcesarRegistro).Methods("POST")
 	r.HandleFunc("/login", docenteControlador.ProcesarLogin).Methods("POST")
 	r.HandleFunc("/panel-docente", docenteControlador.MostrarPanelDocente).Methods("GET")
+	r.HandleFunc("/api/docente/zona-horaria", docenteControlador.ActualizarZonaHoraria).Methods("POST")
 
 	// Rutas para sesiones de asistencia
 	r.HandleFunc("/sesion-asistencia/registrar", sesionControlador.MostrarRegistrar).Methods("GET")
@@ -48,10 +80,23 @@ func SetupRoutes() *mux.Router {
 	r.HandleFunc("/sesion-asistencia/{id}/registrar", sesionControlador.ProcesarSeleccionEstudiante).Methods("POST")
 	r.HandleFunc("/sesion-asistencia/{id}/estudiante/{estudiante_id}/foto", sesionControlador.MostrarFormularioFoto).Methods("GET")
 	r.HandleFunc("/sesion-asistencia/{id}/listar", asistenciaControlador.MostrarListarAsistencias).Methods("GET")
+	r.HandleFunc("/sesion-asistencia/{id}/revision", asistenciaControlador.MostrarRevisionAsistencias).Methods("GET")
+	r.HandleFunc("/api/sesion-asistencia/{id}/revision", asistenciaControlador.ProcesarRevisionAsistencias).Methods("POST")
+	r.HandleFunc("/sesion-asistencia/{id}/apelaciones", asistenciaControlador.MostrarApelaciones).Methods("GET")
+	r.HandleFunc("/api/sesion-asistencia/{id}/apelaciones/{apelacion_id}/revision", asistenciaControlador.RevisarApelacion).Methods("POST")
+	r.HandleFunc("/api/asistencia/{id}", asistenciaControlador.ObtenerAsistencia).Methods("GET")
+	r.HandleFunc("/api/asistencia/{id}", asistenciaControlador.ModificarAsistenciaManual).Methods("PUT")
+	r.HandleFunc("/api/asistencia/{id}", asistenciaControlador.EliminarAsistenciaManual).Methods("DELETE")
+	r.HandleFunc("/api/sesion-asistencia/{id}/asistencias-manuales", asistenciaControlador.CrearAsistenciaManual).Methods("POST")
+	r.HandleFunc("/api/sesion-asistencia/{id}/marcar-ausencias", asistenciaControlador.MarcarAusencias).Methods("POST")
+	r.HandleFunc("/api/sesion-asistencia/{id}/sospechas", asistenciaControlador.ListarSospechasSuplantacion).Methods("GET")
+	r.HandleFunc("/api/intentos-foto-repetida/{id}/revisado", asistenciaControlador.MarcarIntentoFotoRepetidaRevisado).Methods("POST")
 
 	// Nueva ruta para gestionar sesiones (formulario + lista en una vista)
 	r.HandleFunc("/gestionar-sesiones", sesionControlador.MostrarGestionarSesiones).Methods("GET")
 	r.HandleFunc("/gestionar-sesiones", sesionControlador.ProcesarGestionarSesiones).Methods("POST")
+	r.HandleFunc("/api/sesion-asistencia/{id}/configuracion", sesionControlador.ActualizarConfiguracion).Methods("POST")
+	r.HandleFunc("/api/sesion-asistencia/{id}/estado", sesionControlador.CambiarEstado).Methods("POST")
 
 	// Rutas para gestionar estudiantes
 	r.HandleFunc("/gestionar-alumnos", estudianteControlador.MostrarGestionarEstudiantes).Methods("GET")
@@ -60,9 +105,26 @@ func SetupRoutes() *mux.Router {
 	r.HandleFunc("/editar-estudiante/{id}", estudianteControlador.MostrarEditarEstudiante).Methods("GET")
 	r.HandleFunc("/editar-estudiante/{id}", estudianteControlador.ProcesarEditarEstudiante).Methods("POST")
 
+	// Rutas para las fotos de referencia (varias por estudiante)
+	r.HandleFunc("/estudiante/{id}/referencias", estudianteControlador.MostrarReferenciasFaciales).Methods("GET")
+	r.HandleFunc("/api/estudiante/{id}/referencias", estudianteControlador.ListarReferenciasFaciales).Methods("GET")
+	r.HandleFunc("/api/estudiante/{id}/referencias", estudianteControlador.AgregarReferenciaFacial).Methods("POST")
+	r.HandleFunc("/api/estudiante/{id}/referencias/{referencia_id}", estudianteControlador.EliminarReferenciaFacial).Methods("DELETE")
+	r.HandleFunc("/api/estudiante/{id}/actualizaciones-referencia/{actualizacion_id}/revertir", estudianteControlador.RevertirActualizacionReferencia).Methods("POST")
+
 	// Rutas para asistencia (escaneo de QR)
 	r.HandleFunc("/asistencia/confirmar", asistenciaControlador.MostrarConfirmarAsistencia).Methods("GET")
 	r.HandleFunc("/api/registrar-asistencia", asistenciaControlador.ProcesarRegistrarAsistencia).Methods("POST")
+	r.HandleFunc("/api/sesion-asistencia/{id}/identificar", asistenciaControlador.IdentificarAsistencia).Methods("POST")
+	r.HandleFunc("/api/desafio-vivacidad", asistenciaControlador.CrearDesafioVivacidad).Methods("POST")
+	r.HandleFunc("/api/intentos-rechazados/{id}/apelacion", asistenciaControlador.ApelarIntento).Methods("POST")
+
+	// Rutas para justificaciones de ausencias
+	r.HandleFunc("/justificaciones/nueva", justificacionControlador.MostrarNuevaJustificacion).Methods("GET")
+	r.HandleFunc("/api/justificaciones", justificacionControlador.EnviarJustificacion).Methods("POST")
+	r.HandleFunc("/justificaciones", justificacionControlador.MostrarJustificaciones).Methods("GET")
+	r.HandleFunc("/justificaciones/{id}/documentos/{documento_id}", justificacionControlador.DescargarDocumento).Methods("GET")
+	r.HandleFunc("/api/justificaciones/{id}/revision", justificacionControlador.RevisarJustificacion).Methods("POST")
 
 	// Ruta para captura de foto (reconocimiento facial)
 	r.HandleFunc("/capturar-foto", asistenciaControlador.MostrarCapturarFoto).Methods("GET")
@@ -31,3 +31,15 @@ func (v *AsistenciaVistaHTML) RenderizarListarAsistencias(w http.ResponseWriter,
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+func (v *AsistenciaVistaHTML) RenderizarRevisionAsistencias(w http.ResponseWriter, data interface{}) {
+	if err := v.tmpl.ExecuteTemplate(w, "revision_asistencias.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (v *AsistenciaVistaHTML) RenderizarApelaciones(w http.ResponseWriter, data interface{}) {
+	if err := v.tmpl.ExecuteTemplate(w, "apelaciones.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -27,3 +27,10 @@ func (ev *EstudianteVistaHTML) RenderizarEditarEstudiante(w http.ResponseWriter,
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// RenderizarReferenciasFaciales renderiza la vista de fotos de referencia de un estudiante
+func (ev *EstudianteVistaHTML) RenderizarReferenciasFaciales(w http.ResponseWriter, data interface{}) {
+	if err := ev.tmpl.ExecuteTemplate(w, "referencias_faciales.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,27 @@
+package vista
+
+import (
+	"html/template"
+	"net/http"
+)
+
+type JustificacionVistaHTML struct {
+	tmpl *template.Template
+}
+
+func NuevaJustificacionVistaHTML() *JustificacionVistaHTML {
+	t := template.Must(template.ParseFS(TemplatesFS, "templates/*.html"))
+	return &JustificacionVistaHTML{tmpl: t}
+}
+
+func (v *JustificacionVistaHTML) RenderizarNuevaJustificacion(w http.ResponseWriter, data interface{}) {
+	if err := v.tmpl.ExecuteTemplate(w, "nueva_justificacion.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (v *JustificacionVistaHTML) RenderizarJustificaciones(w http.ResponseWriter, data interface{}) {
+	if err := v.tmpl.ExecuteTemplate(w, "justificaciones.html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}